@@ -2,24 +2,438 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
+	bolt "go.etcd.io/bbolt"
 
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/analytics"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/api"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/dashboard"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geocode"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/graph"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/reports"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/rules"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/scheduler"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
 )
 
-func main() {
+type callStore interface {
+	saved_calls.Client
+	saved_calls.Reader
+}
+
+type stores struct {
+	calls     callStore
+	incidents saved_incidents.Client
+	close     func()
+}
+
+func openStores(store string, dbPath string) (stores, error) {
+	clock := func() time.Time { return time.Now().UTC() }
+
+	switch store {
+	case "dynamodb":
+		cfg, err := config.LoadDefaultConfig(context.TODO())
+		if err != nil {
+			return stores{}, fmt.Errorf("unable to load aws config: %w", err)
+		}
+		return stores{
+			calls:     saved_calls.New(cfg),
+			incidents: saved_incidents.New(cfg),
+			close:     func() {},
+		}, nil
+	case "memory":
+		return stores{
+			calls:     saved_calls.NewInMemory(clock),
+			incidents: saved_incidents.NewInMemory(clock),
+			close:     func() {},
+		}, nil
+	case "bolt":
+		db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return stores{}, err
+		}
+		callDao, err := saved_calls.NewBolt(db, clock)
+		if err != nil {
+			db.Close()
+			return stores{}, err
+		}
+		incidentDao, err := saved_incidents.NewBolt(db, clock)
+		if err != nil {
+			db.Close()
+			return stores{}, err
+		}
+		return stores{
+			calls:     callDao,
+			incidents: incidentDao,
+			close:     func() { db.Close() },
+		}, nil
+	default:
+		return stores{}, fmt.Errorf("unknown store: %s", store)
+	}
+}
+
+func newHarvester(opened stores, addressRanges string, guard harvester.Guard) (*harvester.Harvester, error) {
 	policeApiKey := os.Getenv("CPD_API_KEY")
 	fireApiKey := os.Getenv("CFD_API_KEY")
+	apiClient := chesterfield.New(policeApiKey, fireApiKey)
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	var geocoder geocode.Provider
+	if addressRanges != "" {
+		ranges, err := geocode.LoadRanges(addressRanges)
+		if err != nil {
+			return nil, err
+		}
+		geocoder = geocode.NewCached(ranges)
+	}
+	// a tripped guard is logged, there's nowhere to send metrics locally
+	return harvester.NewWithClients(apiClient, opened.calls, opened.incidents, geocoder).WithGuard(guard, nil), nil
+}
+
+func harvestAll(harvesterInstance *harvester.Harvester) scheduler.Task {
+	return func(ctx context.Context) error {
+		_, callErr := harvesterInstance.Harvest(ctx)
+		incidentErr := harvesterInstance.HarvestIncidents(ctx)
+		return errors.Join(callErr, incidentErr)
+	}
+}
+
+// notifyAfter lets open dashboards refresh once a harvest is stored, even
+// a partly failed one
+func notifyAfter(task scheduler.Task, broadcaster *dashboard.Broadcaster) scheduler.Task {
+	return func(ctx context.Context) error {
+		err := task(ctx)
+		broadcaster.Notify()
+		return err
+	}
+}
+
+func storeFlags(flags *flag.FlagSet) (*string, *string) {
+	store := flags.String("store", "dynamodb", "where calls are saved: dynamodb, bolt or memory")
+	dbPath := flags.String("db", "harvest.db", "database file used by the bolt store")
+	return store, dbPath
+}
+
+func geocodeFlag(flags *flag.FlagSet) *string {
+	return flags.String("address-ranges", "", "TIGER/Line style address range CSV used to place calls on a map")
+}
+
+func guardFlags(flags *flag.FlagSet) *harvester.Guard {
+	guard := harvester.DefaultGuard
+	flags.IntVar(&guard.GraceHarvests, "grace-harvests", guard.GraceHarvests, "harvests in a row a call must be missing from before it is resolved")
+	flags.Float64Var(&guard.MaxResolvedShare, "max-resolved-share", guard.MaxResolvedShare, "largest share of an agency's calls that may resolve in one harvest, 0 for no limit")
+	flags.IntVar(&guard.MinCalls, "guard-min-calls", guard.MinCalls, "agencies with fewer saved calls than this aren't limited")
+	return &guard
+}
+
+func runOnce(args []string) {
+	flags := flag.NewFlagSet("harvest", flag.ExitOnError)
+	store, dbPath := storeFlags(flags)
+	addressRanges := geocodeFlag(flags)
+	guard := guardFlags(flags)
+	flags.Parse(args)
+
+	opened, err := openStores(*store, *dbPath)
 	if err != nil {
-		panic("unable to load aws config")
+		panic(err)
+	}
+	defer opened.close()
+
+	harvesterInstance, err := newHarvester(opened, *addressRanges, *guard)
+	if err != nil {
+		panic(err)
 	}
-	harvesterInstance := harvester.New(policeApiKey, fireApiKey, cfg)
-	err = harvesterInstance.Harvest(context.TODO())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = harvestAll(harvesterInstance)(ctx)
 	if err != nil {
 		panic(err)
 	}
 }
+
+func serve(args []string) {
+	flags := flag.NewFlagSet("harvest serve", flag.ExitOnError)
+	store, dbPath := storeFlags(flags)
+	interval := flags.Duration("interval", 5*time.Minute, "time between harvests")
+	jitter := flags.Duration("jitter", 30*time.Second, "maximum random delay added to each interval")
+	maxBackoff := flags.Duration("max-backoff", 30*time.Minute, "longest wait between harvests after repeated failures")
+	listen := flags.String("listen", "", "also serve the call api on this address, e.g. :8080")
+	addressRanges := geocodeFlag(flags)
+	guard := guardFlags(flags)
+	flags.Parse(args)
+	if *maxBackoff < *interval {
+		log.Fatal("-max-backoff can't be shorter than -interval")
+	}
+
+	opened, err := openStores(*store, *dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer opened.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	harvesterInstance, err := newHarvester(opened, *addressRanges, *guard)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	task := harvestAll(harvesterInstance)
+	if *listen != "" {
+		// the memory and bolt stores can only be read from this process, so
+		// the api is served next to the harvester rather than on its own
+		server, err := newApi(opened)
+		if err != nil {
+			log.Fatal(err)
+		}
+		broadcaster := dashboard.NewBroadcaster()
+		server.Mount("GET /events", broadcaster)
+		task = notifyAfter(task, broadcaster)
+		go listenAndServe(ctx, *listen, server)
+	}
+
+	log.Printf("Harvesting every %v into %s\n", *interval, *store)
+	scheduler.New(scheduler.Config{
+		Interval:   *interval,
+		Jitter:     *jitter,
+		MaxBackoff: *maxBackoff,
+	}, task).Run(ctx)
+}
+
+func newApi(opened stores) (*api.Server, error) {
+	schema, err := graph.NewSchema(opened.calls, opened.incidents)
+	if err != nil {
+		return nil, err
+	}
+	server := api.New(opened.calls)
+	server.Mount("/graphql", graph.NewHandler(schema))
+	server.Mount("/", dashboard.Handler())
+	return server, nil
+}
+
+func listenAndServe(ctx context.Context, address string, handler http.Handler) {
+	server := &http.Server{Addr: address, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("Serving the call api on %s\n", address)
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+func serveApi(args []string) {
+	flags := flag.NewFlagSet("harvest api", flag.ExitOnError)
+	store, dbPath := storeFlags(flags)
+	listen := flags.String("listen", ":8080", "address to serve the call api on")
+	flags.Parse(args)
+
+	opened, err := openStores(*store, *dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer opened.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handler, err := newApi(opened)
+	if err != nil {
+		log.Fatal(err)
+	}
+	listenAndServe(ctx, *listen, handler)
+}
+
+func dateFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(analytics.DateFormat, value, chesterfield.LocalTime)
+}
+
+func reportResponseTimes(args []string) {
+	flags := flag.NewFlagSet("harvest analytics", flag.ExitOnError)
+	store, dbPath := storeFlags(flags)
+	fromFlag := flags.String("from", "", "first day to report on, e.g. 2022-03-01, defaults to 30 days before -to")
+	toFlag := flags.String("to", "", "last day to report on, defaults to today")
+	street := flags.String("street", "", "only calls on this street")
+	callType := flags.String("type", "", "only police or fire calls")
+	format := flags.String("format", "csv", "output format: csv or json")
+	flags.Parse(args)
+
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("unknown format: %s", *format)
+	}
+	from, err := dateFlag(*fromFlag)
+	if err != nil {
+		log.Fatal("-from must be a date like 2022-03-23")
+	}
+	to, err := dateFlag(*toFlag)
+	if err != nil {
+		log.Fatal("-to must be a date like 2022-03-23")
+	}
+	from, to, err = analytics.DateRange(from, to, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opened, err := openStores(*store, *dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer opened.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := analytics.Analyze(ctx, opened.calls, saved_calls.CallQuery{
+		StreetName: address.NormalizeStreet(*street),
+		From:       from,
+		To:         to,
+		CallType:   *callType,
+		Limit:      500,
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteCSV(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// a comma separated flag, empty for none
+func listFlag(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func printActivityReport(args []string) {
+	flags := flag.NewFlagSet("harvest report", flag.ExitOnError)
+	store, dbPath := storeFlags(flags)
+	period := flags.String("period", subscriptions.ReportDaily, "daily for yesterday or weekly for the last seven days")
+	streets := flags.String("street", "", "comma separated streets to report on")
+	areas := flags.String("area", "", "comma separated areas to report on, along with the streets")
+	format := flags.String("format", "markdown", "output format: markdown, html or text")
+	flags.Parse(args)
+
+	if *period != subscriptions.ReportDaily && *period != subscriptions.ReportWeekly {
+		log.Fatalf("unknown period: %s", *period)
+	}
+	if *format != "markdown" && *format != "html" && *format != "text" {
+		log.Fatalf("unknown format: %s", *format)
+	}
+
+	// a call on any of the streets or in any of the areas
+	subscription := subscriptions.Subscription{}
+	if street := listFlag(*streets); len(street) > 0 {
+		subscription.Filters = append(subscription.Filters, rules.Rule{StreetNames: street})
+	}
+	if area := listFlag(*areas); len(area) > 0 {
+		subscription.Filters = append(subscription.Filters, rules.Rule{Areas: area})
+	}
+
+	opened, err := openStores(*store, *dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer opened.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := reports.Build(ctx, opened.calls, subscription, *period, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rendered string
+	switch *format {
+	case "html":
+		rendered, err = report.HTML()
+	case "text":
+		rendered = report.Summary() + "\n"
+	default:
+		rendered, err = report.Markdown()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(rendered)
+}
+
+// backfillStreets moves calls saved before street names were normalized to
+// the key they'd be saved under now. Only the dynamodb table has calls that
+// old.
+func backfillStreets(args []string) {
+	flags := flag.NewFlagSet("harvest backfill-streets", flag.ExitOnError)
+	flags.Parse(args)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("unable to load aws config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	moved, err := saved_calls.New(cfg).BackfillStreets(ctx, harvester.Locate)
+	log.Printf("Moved %d calls\n", moved)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "api":
+			serveApi(os.Args[2:])
+			return
+		case "analytics":
+			reportResponseTimes(os.Args[2:])
+			return
+		case "report":
+			printActivityReport(os.Args[2:])
+			return
+		case "backfill-streets":
+			backfillStreets(os.Args[2:])
+			return
+		}
+	}
+	runOnce(os.Args[1:])
+}
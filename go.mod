@@ -12,11 +12,13 @@ require (
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression v1.7.82
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.43.1
 	github.com/go-resty/resty/v2 v2.16.5
+	github.com/graphql-go/graphql v0.8.1
 	github.com/jarcoal/httpmock v1.3.1
 	github.com/onsi/ginkgo/v2 v2.22.2
 	github.com/onsi/gomega v1.36.2
 	github.com/stretchr/testify v1.10.0
 	github.com/twilio/twilio-go v1.23.11
+	go.etcd.io/bbolt v1.4.3
 )
 
 require (
@@ -0,0 +1,229 @@
+package address
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ParsedLocation is a call location split into the parts that identify a
+// street, so "22XX FAKE ROAD" and "22XX FAKE RD" land on the same street
+type ParsedLocation struct {
+	// the masked hundred block, e.g. "22XX"
+	Block           string
+	Directional     string
+	Street          string
+	Suffix          string
+	PostDirectional string
+	Unit            string
+	// the normalized street name of the other side of an intersection
+	CrossStreet string
+}
+
+var blockRegex = regexp.MustCompile(`^\d*XX$|^\d+$`)
+
+// intersections are written a few ways
+var intersectionRegex = regexp.MustCompile(`\s+AT\s+|\s*/\s*|\s*&\s*`)
+
+var unitRegex = regexp.MustCompile(`\s+(?:(APT|APARTMENT|UNIT|STE|SUITE|LOT|BLDG|BUILDING|RM|ROOM)\s+|#\s*)(\S+)$`)
+
+var punctuationRegex = regexp.MustCompile(`[.,]`)
+
+var unitTypes = map[string]string{
+	"APARTMENT": "APT",
+	"SUITE":     "STE",
+	"BUILDING":  "BLDG",
+	"ROOM":      "RM",
+}
+
+var directionals = map[string]string{
+	"NORTH":     "N",
+	"SOUTH":     "S",
+	"EAST":      "E",
+	"WEST":      "W",
+	"NORTHEAST": "NE",
+	"NORTHWEST": "NW",
+	"SOUTHEAST": "SE",
+	"SOUTHWEST": "SW",
+	"N":         "N",
+	"S":         "S",
+	"E":         "E",
+	"W":         "W",
+	"NE":        "NE",
+	"NW":        "NW",
+	"SE":        "SE",
+	"SW":        "SW",
+}
+
+// the USPS abbreviations for the suffixes seen in the county, keyed by every
+// spelling that shows up
+var suffixes = map[string]string{
+	"ALLEY":     "ALY",
+	"ALY":       "ALY",
+	"AVENUE":    "AVE",
+	"AVE":       "AVE",
+	"AV":        "AVE",
+	"BOULEVARD": "BLVD",
+	"BLVD":      "BLVD",
+	"CIRCLE":    "CIR",
+	"CIR":       "CIR",
+	"COURT":     "CT",
+	"CT":        "CT",
+	"COVE":      "CV",
+	"CV":        "CV",
+	"CROSSING":  "XING",
+	"XING":      "XING",
+	"DRIVE":     "DR",
+	"DR":        "DR",
+	"HIGHWAY":   "HWY",
+	"HWY":       "HWY",
+	"LANE":      "LN",
+	"LN":        "LN",
+	"LOOP":      "LOOP",
+	"PARKWAY":   "PKWY",
+	"PKWY":      "PKWY",
+	"PKY":       "PKWY",
+	"PIKE":      "PIKE",
+	"PLACE":     "PL",
+	"PL":        "PL",
+	"POINT":     "PT",
+	"PT":        "PT",
+	"RIDGE":     "RDG",
+	"RDG":       "RDG",
+	"ROAD":      "RD",
+	"RD":        "RD",
+	"RUN":       "RUN",
+	"SQUARE":    "SQ",
+	"SQ":        "SQ",
+	"STREET":    "ST",
+	"ST":        "ST",
+	"TERRACE":   "TER",
+	"TERR":      "TER",
+	"TER":       "TER",
+	"TRAIL":     "TRL",
+	"TRL":       "TRL",
+	"TURNPIKE":  "TPKE",
+	"TPKE":      "TPKE",
+	"TPK":       "TPKE",
+	"WAY":       "WAY",
+}
+
+// numbered routes keep their number as the name
+var routePrefixes = map[string]string{
+	"ROUTE":      "RT",
+	"RTE":        "RT",
+	"RT":         "RT",
+	"INTERSTATE": "I",
+	"I":          "I",
+}
+
+func tokenize(value string) []string {
+	value = punctuationRegex.ReplaceAllString(strings.ToUpper(value), "")
+	// I-95 and I 95 are the same road
+	value = strings.ReplaceAll(value, "-", " ")
+	return strings.Fields(value)
+}
+
+// parseStreet normalizes one street name, without a block or unit
+func parseStreet(value string, location *ParsedLocation) {
+	tokens := tokenize(value)
+
+	if len(tokens) > 1 {
+		if directional, ok := directionals[tokens[0]]; ok {
+			location.Directional = directional
+			tokens = tokens[1:]
+		}
+	}
+	if len(tokens) > 1 {
+		if directional, ok := directionals[tokens[len(tokens)-1]]; ok {
+			location.PostDirectional = directional
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+
+	// "STATE RTE 10" is still route 10
+	if len(tokens) > 2 && tokens[0] == "STATE" {
+		if _, ok := routePrefixes[tokens[1]]; ok {
+			tokens = tokens[1:]
+		}
+	}
+	if len(tokens) > 1 {
+		if route, ok := routePrefixes[tokens[0]]; ok {
+			tokens[0] = route
+		}
+	}
+
+	for i, token := range tokens {
+		if i == 0 && len(tokens) > 1 {
+			// a name that starts with a suffix word, e.g. "COURT HOUSE RD", is
+			// left alone
+			continue
+		}
+		if suffix, ok := suffixes[token]; ok {
+			tokens[i] = suffix
+		}
+	}
+	if len(tokens) > 1 {
+		last := tokens[len(tokens)-1]
+		if _, ok := suffixes[last]; ok {
+			location.Suffix = last
+			tokens = tokens[:len(tokens)-1]
+		}
+	}
+
+	location.Street = strings.Join(tokens, " ")
+}
+
+// Parse splits a location as the county writes it. Anything it doesn't
+// recognize is kept in the street name, so no location is ever dropped.
+func Parse(location string) ParsedLocation {
+	parsed := ParsedLocation{}
+	location = strings.Join(strings.Fields(strings.ToUpper(location)), " ")
+
+	parts := intersectionRegex.Split(location, 2)
+	primary := parts[0]
+	if len(parts) == 2 {
+		cross := ParsedLocation{}
+		parseStreet(parts[1], &cross)
+		parsed.CrossStreet = cross.StreetName()
+	}
+
+	if match := unitRegex.FindStringSubmatch(primary); match != nil {
+		unitType := match[1]
+		if canonical, ok := unitTypes[unitType]; ok {
+			unitType = canonical
+		}
+		if unitType == "" {
+			parsed.Unit = "#" + match[2]
+		} else {
+			parsed.Unit = unitType + " " + match[2]
+		}
+		primary = primary[:len(primary)-len(match[0])]
+	}
+
+	if first, rest, ok := strings.Cut(primary, " "); ok && blockRegex.MatchString(first) {
+		parsed.Block = first
+		primary = rest
+	}
+
+	parseStreet(primary, &parsed)
+	return parsed
+}
+
+// StreetName is the normalized name used as the street's key, e.g. "N MAIN ST"
+func (location ParsedLocation) StreetName() string {
+	parts := []string{}
+	for _, part := range []string{location.Directional, location.Street, location.Suffix, location.PostDirectional} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// NormalizeStreet brings a street name typed by hand, e.g. in a rule, in
+// line with the names calls are stored under
+func NormalizeStreet(streetName string) string {
+	parsed := ParsedLocation{}
+	parseStreet(streetName, &parsed)
+	return parsed.StreetName()
+}
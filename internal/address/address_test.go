@@ -0,0 +1,88 @@
+package address_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+)
+
+var _ = Describe("Address", func() {
+	DescribeTable("Parse()",
+		func(location string, expected address.ParsedLocation, streetName string) {
+			parsed := address.Parse(location)
+
+			Expect(parsed).To(Equal(expected))
+			Expect(parsed.StreetName()).To(Equal(streetName))
+		},
+		Entry("masked block", "22XX FAKE RD",
+			address.ParsedLocation{Block: "22XX", Street: "FAKE", Suffix: "RD"}, "FAKE RD"),
+		Entry("long block", "123XX DIFFERENT ST",
+			address.ParsedLocation{Block: "123XX", Street: "DIFFERENT", Suffix: "ST"}, "DIFFERENT ST"),
+		Entry("first block", "XX MAIN ST",
+			address.ParsedLocation{Block: "XX", Street: "MAIN", Suffix: "ST"}, "MAIN ST"),
+		Entry("spelled out suffix", "22XX FAKE ROAD",
+			address.ParsedLocation{Block: "22XX", Street: "FAKE", Suffix: "RD"}, "FAKE RD"),
+		Entry("suffix variant", "43XX EXAMPLE AV",
+			address.ParsedLocation{Block: "43XX", Street: "EXAMPLE", Suffix: "AVE"}, "EXAMPLE AVE"),
+		Entry("lowercase and extra spaces", " 22xx  fake   rd ",
+			address.ParsedLocation{Block: "22XX", Street: "FAKE", Suffix: "RD"}, "FAKE RD"),
+		Entry("punctuation", "22XX FAKE RD.",
+			address.ParsedLocation{Block: "22XX", Street: "FAKE", Suffix: "RD"}, "FAKE RD"),
+		Entry("suffix inside the name", "138XX HULL STREET RD",
+			address.ParsedLocation{Block: "138XX", Street: "HULL ST", Suffix: "RD"}, "HULL ST RD"),
+		Entry("name that starts with a suffix word", "COURT HOUSE RD",
+			address.ParsedLocation{Street: "COURT HOUSE", Suffix: "RD"}, "COURT HOUSE RD"),
+		Entry("directional", "31XX W HUNDRED RD",
+			address.ParsedLocation{Block: "31XX", Directional: "W", Street: "HUNDRED", Suffix: "RD"}, "W HUNDRED RD"),
+		Entry("spelled out directional", "SOUTH PROVIDENCE ROAD",
+			address.ParsedLocation{Directional: "S", Street: "PROVIDENCE", Suffix: "RD"}, "S PROVIDENCE RD"),
+		Entry("post directional", "I-95 N",
+			address.ParsedLocation{Street: "I 95", PostDirectional: "N"}, "I 95 N"),
+		Entry("directional alone is a name", "NORTH",
+			address.ParsedLocation{Street: "NORTH"}, "NORTH"),
+		Entry("turnpike", "114XX MIDLOTHIAN TURNPIKE",
+			address.ParsedLocation{Block: "114XX", Street: "MIDLOTHIAN", Suffix: "TPKE"}, "MIDLOTHIAN TPKE"),
+		Entry("parkway", "CHIPPENHAM PKY",
+			address.ParsedLocation{Street: "CHIPPENHAM", Suffix: "PKWY"}, "CHIPPENHAM PKWY"),
+		Entry("state route", "STATE ROUTE 10",
+			address.ParsedLocation{Street: "RT 10"}, "RT 10"),
+		Entry("route", "RTE 288",
+			address.ParsedLocation{Street: "RT 288"}, "RT 288"),
+		Entry("interstate", "INTERSTATE 95",
+			address.ParsedLocation{Street: "I 95"}, "I 95"),
+		Entry("apartment", "43XX EXAMPLE CT APT 4",
+			address.ParsedLocation{Block: "43XX", Street: "EXAMPLE", Suffix: "CT", Unit: "APT 4"}, "EXAMPLE CT"),
+		Entry("suite", "100XX IRON BRIDGE RD SUITE 200",
+			address.ParsedLocation{Block: "100XX", Street: "IRON BRIDGE", Suffix: "RD", Unit: "STE 200"}, "IRON BRIDGE RD"),
+		Entry("unit number", "43XX EXAMPLE CT #4B",
+			address.ParsedLocation{Block: "43XX", Street: "EXAMPLE", Suffix: "CT", Unit: "#4B"}, "EXAMPLE CT"),
+		Entry("lot", "22XX FAKE RD LOT 12",
+			address.ParsedLocation{Block: "22XX", Street: "FAKE", Suffix: "RD", Unit: "LOT 12"}, "FAKE RD"),
+		Entry("intersection with AT", "HULL ST RD AT COALFIELD RD",
+			address.ParsedLocation{Street: "HULL ST", Suffix: "RD", CrossStreet: "COALFIELD RD"}, "HULL ST RD"),
+		Entry("intersection with a route", "RT 288 AT MIDLOTHIAN TPKE",
+			address.ParsedLocation{Street: "RT 288", CrossStreet: "MIDLOTHIAN TPKE"}, "RT 288"),
+		Entry("intersection with a slash", "MAIN ST / OAK AVE",
+			address.ParsedLocation{Street: "MAIN", Suffix: "ST", CrossStreet: "OAK AVE"}, "MAIN ST"),
+		Entry("intersection without spaces", "MIDLOTHIAN TURNPIKE/WOOLRIDGE ROAD",
+			address.ParsedLocation{Street: "MIDLOTHIAN", Suffix: "TPKE", CrossStreet: "WOOLRIDGE RD"}, "MIDLOTHIAN TPKE"),
+		Entry("intersection with an ampersand", "CHIPPENHAM PKWY & JAHNKE RD",
+			address.ParsedLocation{Street: "CHIPPENHAM", Suffix: "PKWY", CrossStreet: "JAHNKE RD"}, "CHIPPENHAM PKWY"),
+		Entry("intersection with directionals", "N MAIN STREET AT W OAK AVENUE",
+			address.ParsedLocation{Directional: "N", Street: "MAIN", Suffix: "ST", CrossStreet: "W OAK AVE"}, "N MAIN ST"),
+		Entry("place name", "CHESTERFIELD TOWNE CENTER",
+			address.ParsedLocation{Street: "CHESTERFIELD TOWNE CENTER"}, "CHESTERFIELD TOWNE CENTER"),
+		Entry("empty", "",
+			address.ParsedLocation{}, ""),
+	)
+
+	DescribeTable("NormalizeStreet()",
+		func(streetName string, expected string) {
+			Expect(address.NormalizeStreet(streetName)).To(Equal(expected))
+		},
+		Entry("already normal", "FAKE RD", "FAKE RD"),
+		Entry("spelled out", "Fake Road", "FAKE RD"),
+		Entry("directional", "north main street", "N MAIN ST"),
+	)
+})
@@ -0,0 +1,238 @@
+package analytics
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+const (
+	DateFormat = "2006-01-02"
+	// how far back a range goes when it has no start
+	DefaultDays = 30
+)
+
+// Distribution summarizes how long something took, in seconds
+type Distribution struct {
+	Count  int     `json:"count"`
+	Median float64 `json:"medianSeconds"`
+	P90    float64 `json:"p90Seconds"`
+}
+
+// Group is the response and resolution times of the calls sharing a key,
+// e.g. one area
+type Group struct {
+	Key   string `json:"key"`
+	Calls int    `json:"calls"`
+	// from the call being received to units arriving on scene. The county
+	// doesn't publish when units were dispatched, so this includes the time
+	// the call waited for one.
+	Arrival Distribution `json:"arrival"`
+	// from the call being received to it being resolved
+	Resolution Distribution `json:"resolution"`
+}
+
+type Report struct {
+	// set when the page limit was reached before the range was read, the
+	// report only covers the calls read until then
+	Partial    bool    `json:"partial,omitempty"`
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Overall    Group   `json:"overall"`
+	ByArea     []Group `json:"byArea"`
+	ByType     []Group `json:"byType"`
+	ByPriority []Group `json:"byPriority"`
+}
+
+var ErrInvalidRange = errors.New("from must not be after to")
+
+// DateRange fills in the ends of a range that weren't given, it ends today
+// and starts DefaultDays before its end
+func DateRange(from time.Time, to time.Time, now time.Time) (time.Time, time.Time, error) {
+	if to.IsZero() {
+		local := now.In(chesterfield.LocalTime)
+		to = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, chesterfield.LocalTime)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -DefaultDays)
+	}
+	if from.After(to) {
+		return from, to, ErrInvalidRange
+	}
+	return from, to, nil
+}
+
+// Analyze reads the calls the query matches, at most maxPages pages of
+// them or all of them when maxPages is 0, and reports their arrival and
+// resolution times. The times are stamped by the harvester when it sees a
+// status change, so they are only as precise as the harvest interval.
+func Analyze(ctx context.Context, reader saved_calls.Reader, query saved_calls.CallQuery, maxPages int) (Report, error) {
+	calls := []saved_calls.SavedCall{}
+	partial := false
+	for pages := 1; ; pages++ {
+		page, err := reader.FindCalls(ctx, query)
+		if err != nil {
+			return Report{}, err
+		}
+		calls = append(calls, page.Calls...)
+		if page.Cursor == "" {
+			break
+		}
+		if maxPages > 0 && pages >= maxPages {
+			partial = true
+			break
+		}
+		query.Cursor = page.Cursor
+	}
+
+	report := Summarize(calls)
+	report.Partial = partial
+	report.From = query.From.In(chesterfield.LocalTime).Format(DateFormat)
+	report.To = query.To.In(chesterfield.LocalTime).Format(DateFormat)
+	return report, nil
+}
+
+type samples struct {
+	calls      int
+	arrival    []time.Duration
+	resolution []time.Duration
+}
+
+func (group *samples) add(call saved_calls.SavedCall) {
+	group.calls++
+	if elapsed, ok := since(call.CallReceived, call.CallArrival); ok {
+		group.arrival = append(group.arrival, elapsed)
+	}
+	if elapsed, ok := since(call.CallReceived, call.CallResolved); ok {
+		group.resolution = append(group.resolution, elapsed)
+	}
+}
+
+// a call that was already on scene or resolved when it was first harvested
+// has a time before it was received, which says nothing about how long it took
+func since(start time.Time, end time.Time) (time.Duration, bool) {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0, false
+	}
+	return end.Sub(start), true
+}
+
+func (group *samples) summarize(key string) Group {
+	return Group{
+		Key:        key,
+		Calls:      group.calls,
+		Arrival:    distribution(group.arrival),
+		Resolution: distribution(group.resolution),
+	}
+}
+
+func distribution(durations []time.Duration) Distribution {
+	if len(durations) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return Distribution{
+		Count:  len(sorted),
+		Median: percentile(sorted, 50).Seconds(),
+		P90:    percentile(sorted, 90).Seconds(),
+	}
+}
+
+// nearest rank, so the result is always one of the samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func groupBy(calls []saved_calls.SavedCall, key func(saved_calls.SavedCall) string) []Group {
+	groups := map[string]*samples{}
+	for _, call := range calls {
+		group, ok := groups[key(call)]
+		if !ok {
+			group = &samples{}
+			groups[key(call)] = group
+		}
+		group.add(call)
+	}
+
+	result := make([]Group, 0, len(groups))
+	for key, group := range groups {
+		result = append(result, group.summarize(key))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Calls != result[j].Calls {
+			return result[i].Calls > result[j].Calls
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// Summarize reports on calls that were already read
+func Summarize(calls []saved_calls.SavedCall) Report {
+	overall := &samples{}
+	for _, call := range calls {
+		overall.add(call)
+	}
+
+	return Report{
+		Overall:    overall.summarize(""),
+		ByArea:     groupBy(calls, func(call saved_calls.SavedCall) string { return call.Area }),
+		ByType:     groupBy(calls, func(call saved_calls.SavedCall) string { return call.CallType }),
+		ByPriority: groupBy(calls, func(call saved_calls.SavedCall) string { return call.Priority }),
+	}
+}
+
+var csvHeader = []string{
+	"grouping", "key", "calls",
+	"arrival_count", "arrival_median_seconds", "arrival_p90_seconds",
+	"resolution_count", "resolution_median_seconds", "resolution_p90_seconds",
+}
+
+// WriteCSV writes a row for the overall times and one for every group
+func (report Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	seconds := func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }
+	row := func(grouping string, group Group) []string {
+		return []string{
+			grouping, group.Key, strconv.Itoa(group.Calls),
+			strconv.Itoa(group.Arrival.Count), seconds(group.Arrival.Median), seconds(group.Arrival.P90),
+			strconv.Itoa(group.Resolution.Count), seconds(group.Resolution.Median), seconds(group.Resolution.P90),
+		}
+	}
+
+	rows := [][]string{row("overall", report.Overall)}
+	for _, grouping := range []struct {
+		name   string
+		groups []Group
+	}{
+		{"area", report.ByArea},
+		{"type", report.ByType},
+		{"priority", report.ByPriority},
+	} {
+		for _, group := range grouping.groups {
+			rows = append(rows, row(grouping.name, group))
+		}
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
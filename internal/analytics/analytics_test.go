@@ -0,0 +1,189 @@
+package analytics_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/analytics"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+var _ = Describe("Analytics", func() {
+	received := time.Date(2022, 3, 23, 23, 0, 0, 0, chesterfield.LocalTime)
+
+	// a call that units reached after arrival minutes and that was resolved
+	// after resolved minutes, zero for neither yet
+	call := func(id string, area string, callType string, arrival int, resolved int) saved_calls.SavedCall {
+		call := saved_calls.SavedCall{
+			ID:           id,
+			CallType:     callType,
+			Area:         area,
+			StreetName:   "FAKE RD",
+			Priority:     "3",
+			CallReceived: received,
+		}
+		if arrival > 0 {
+			call.CallArrival = received.Add(time.Duration(arrival) * time.Minute)
+		}
+		if resolved > 0 {
+			call.CallResolved = received.Add(time.Duration(resolved) * time.Minute)
+		}
+		return call
+	}
+
+	Describe("Summarize()", func() {
+		It("reports the median and 90th percentile", func() {
+			calls := []saved_calls.SavedCall{}
+			for minutes := 1; minutes <= 10; minutes++ {
+				calls = append(calls, call("0123", "11", "police", minutes, minutes*10))
+			}
+
+			report := analytics.Summarize(calls)
+
+			Expect(report.Overall.Calls).To(Equal(10))
+			Expect(report.Overall.Arrival).To(Equal(analytics.Distribution{Count: 10, Median: 300, P90: 540}))
+			Expect(report.Overall.Resolution).To(Equal(analytics.Distribution{Count: 10, Median: 3000, P90: 5400}))
+		})
+
+		It("groups by area, type and priority", func() {
+			report := analytics.Summarize([]saved_calls.SavedCall{
+				call("0123", "11", "police", 4, 30),
+				call("0124", "11", "police", 6, 0),
+				call("1234", "F20", "fire", 8, 40),
+			})
+
+			Expect(report.ByArea).To(Equal([]analytics.Group{
+				{Key: "11", Calls: 2, Arrival: analytics.Distribution{Count: 2, Median: 240, P90: 360}, Resolution: analytics.Distribution{Count: 1, Median: 1800, P90: 1800}},
+				{Key: "F20", Calls: 1, Arrival: analytics.Distribution{Count: 1, Median: 480, P90: 480}, Resolution: analytics.Distribution{Count: 1, Median: 2400, P90: 2400}},
+			}))
+			Expect(report.ByType[0].Key).To(Equal("police"))
+			Expect(report.ByType[1].Key).To(Equal("fire"))
+			Expect(report.ByPriority).To(HaveLen(1))
+			Expect(report.ByPriority[0].Calls).To(Equal(3))
+		})
+
+		It("leaves out times that aren't known", func() {
+			stale := call("0125", "11", "police", 0, 0)
+			// already on scene when it was first harvested
+			stale.CallArrival = received.Add(-time.Minute)
+
+			report := analytics.Summarize([]saved_calls.SavedCall{stale, call("0126", "11", "police", 0, 0)})
+
+			Expect(report.Overall.Calls).To(Equal(2))
+			Expect(report.Overall.Arrival).To(Equal(analytics.Distribution{}))
+			Expect(report.Overall.Resolution).To(Equal(analytics.Distribution{}))
+		})
+
+		It("handles no calls", func() {
+			report := analytics.Summarize(nil)
+
+			Expect(report.Overall.Calls).To(Equal(0))
+			Expect(report.ByArea).To(BeEmpty())
+		})
+	})
+
+	Describe("Analyze()", func() {
+		var (
+			ctx   context.Context
+			store *saved_calls.InMemoryDataAccess
+		)
+
+		BeforeEach(func() {
+			ctx = context.TODO()
+			now := received
+			store = saved_calls.NewInMemory(func() time.Time { return now })
+			for i, id := range []string{"0123", "0124", "0125"} {
+				saved := call(id, "11", "police", 0, 0)
+				saved.CallReceived = received.AddDate(0, 0, i)
+				saved.LastKnownStatus = "dispatched"
+				Expect(store.SaveCall(ctx, saved)).To(Succeed())
+
+				now = saved.CallReceived.Add(12 * time.Minute)
+				saved.LastKnownStatus = "on scene"
+				Expect(store.UpdateStatus(ctx, saved)).To(Succeed())
+			}
+		})
+
+		It("reads every page of the range", func() {
+			report, err := analytics.Analyze(ctx, store, saved_calls.CallQuery{
+				From:  received,
+				To:    received.AddDate(0, 0, 1),
+				Limit: 1,
+			}, 0)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Partial).To(BeFalse())
+			Expect(report.From).To(Equal("2022-03-23"))
+			Expect(report.To).To(Equal("2022-03-24"))
+			Expect(report.Overall.Calls).To(Equal(2))
+			Expect(report.Overall.Arrival).To(Equal(analytics.Distribution{Count: 2, Median: 720, P90: 720}))
+		})
+
+		It("stops at the page limit", func() {
+			report, err := analytics.Analyze(ctx, store, saved_calls.CallQuery{
+				From:  received,
+				To:    received.AddDate(0, 0, 2),
+				Limit: 1,
+			}, 2)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Partial).To(BeTrue())
+			Expect(report.Overall.Calls).To(Equal(2))
+		})
+	})
+
+	Describe("DateRange()", func() {
+		now := time.Date(2022, 3, 24, 2, 0, 0, 0, time.UTC)
+
+		It("ends today and starts 30 days earlier", func() {
+			from, to, err := analytics.DateRange(time.Time{}, time.Time{}, now)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(to).To(Equal(time.Date(2022, 3, 23, 0, 0, 0, 0, chesterfield.LocalTime)))
+			Expect(from).To(Equal(time.Date(2022, 2, 21, 0, 0, 0, 0, chesterfield.LocalTime)))
+		})
+
+		It("keeps the ends that were given", func() {
+			start := time.Date(2022, 3, 1, 0, 0, 0, 0, chesterfield.LocalTime)
+
+			from, _, err := analytics.DateRange(start, time.Time{}, now)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(from).To(Equal(start))
+		})
+
+		It("rejects a backwards range", func() {
+			_, _, err := analytics.DateRange(received, received.AddDate(0, 0, -1), now)
+
+			Expect(err).To(MatchError(analytics.ErrInvalidRange))
+		})
+	})
+
+	Describe("WriteCSV()", func() {
+		It("writes a row per group", func() {
+			report := analytics.Summarize([]saved_calls.SavedCall{
+				call("0123", "11", "police", 4, 30),
+				call("1234", "F20", "fire", 0, 0),
+			})
+			output := &strings.Builder{}
+
+			Expect(report.WriteCSV(output)).To(Succeed())
+
+			Expect(output.String()).To(Equal(strings.Join([]string{
+				"grouping,key,calls,arrival_count,arrival_median_seconds,arrival_p90_seconds,resolution_count,resolution_median_seconds,resolution_p90_seconds",
+				"overall,,2,1,240,240,1,1800,1800",
+				"area,11,1,1,240,240,1,1800,1800",
+				"area,F20,1,0,0,0,0,0,0",
+				"type,fire,1,0,0,0,0,0,0",
+				"type,police,1,1,240,240,1,1800,1800",
+				"priority,3,2,1,240,240,1,1800,1800",
+				"",
+			}, "\n")))
+		})
+	})
+})
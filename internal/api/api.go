@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/analytics"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+const (
+	dateFormat = "2006-01-02"
+	// the analytics read every page of their range, on a public url that has
+	// to stay well inside the table's read capacity and the function timeout
+	maxAnalyticsPages = 10
+	// without a street the range is scanned, so it has to be short
+	maxScanDays = 31
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type activeCallsResponse struct {
+	Calls []saved_calls.SavedCall `json:"calls"`
+}
+
+type Server struct {
+	reader saved_calls.Reader
+	mux    *http.ServeMux
+}
+
+// New serves saved calls as JSON:
+//
+//	GET /calls?street=&from=&to=&type=&id=&limit=&cursor=
+//	GET /calls/active
+//	GET /calls/{id}
+//	GET /analytics/response-times?from=&to=&street=&type=&format=json|csv
+func New(reader saved_calls.Reader) *Server {
+	server := &Server{
+		reader: reader,
+		mux:    http.NewServeMux(),
+	}
+	server.mux.HandleFunc("GET /calls", server.findCalls)
+	server.mux.HandleFunc("GET /calls/active", server.activeCalls)
+	server.mux.HandleFunc("GET /calls/{id}", server.getCall)
+	server.mux.HandleFunc("GET /analytics/response-times", server.responseTimes)
+	return server
+}
+
+// Mount serves another handler, like the graphql endpoint, next to the
+// call routes
+func (server *Server) Mount(pattern string, handler http.Handler) {
+	server.mux.Handle(pattern, handler)
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, saved_calls.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{err.Error()})
+	case errors.Is(err, saved_calls.ErrInvalidCursor):
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+	default:
+		log.Printf("Error reading calls: %v\n", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal error"})
+	}
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(dateFormat, value, chesterfield.LocalTime)
+}
+
+func parseCallQuery(r *http.Request) (saved_calls.CallQuery, error) {
+	params := r.URL.Query()
+	query := saved_calls.CallQuery{
+		StreetName: address.NormalizeStreet(params.Get("street")),
+		CallType:   params.Get("type"),
+		ID:         params.Get("id"),
+		Cursor:     params.Get("cursor"),
+	}
+
+	var err error
+	query.From, err = parseDate(params.Get("from"))
+	if err != nil {
+		return query, errors.New("from must be a date like 2022-03-23")
+	}
+	query.To, err = parseDate(params.Get("to"))
+	if err != nil {
+		return query, errors.New("to must be a date like 2022-03-23")
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil || parsed < 1 {
+			return query, errors.New("limit must be a positive number")
+		}
+		query.Limit = int32(parsed)
+	}
+	return query, nil
+}
+
+func (server *Server) findCalls(w http.ResponseWriter, r *http.Request) {
+	query, err := parseCallQuery(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+
+	page, err := server.reader.FindCalls(r.Context(), query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (server *Server) activeCalls(w http.ResponseWriter, r *http.Request) {
+	calls, err := server.reader.GetActiveCalls(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if calls == nil {
+		calls = []saved_calls.SavedCall{}
+	}
+	writeJSON(w, http.StatusOK, activeCallsResponse{calls})
+}
+
+func (server *Server) getCall(w http.ResponseWriter, r *http.Request) {
+	call, err := server.reader.GetCall(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, call)
+}
+
+func parseAnalyticsQuery(r *http.Request, now time.Time) (saved_calls.CallQuery, error) {
+	params := r.URL.Query()
+	query := saved_calls.CallQuery{
+		StreetName: address.NormalizeStreet(params.Get("street")),
+		CallType:   params.Get("type"),
+		// every page is read anyway, so read the largest ones
+		Limit: 500,
+	}
+
+	from, err := parseDate(params.Get("from"))
+	if err != nil {
+		return query, errors.New("from must be a date like 2022-03-23")
+	}
+	to, err := parseDate(params.Get("to"))
+	if err != nil {
+		return query, errors.New("to must be a date like 2022-03-23")
+	}
+	query.From, query.To, err = analytics.DateRange(from, to, now)
+	if err != nil {
+		return query, err
+	}
+	// counted in calendar days, a range across a daylight saving change is
+	// an hour off a multiple of 24
+	if query.StreetName == "" && query.From.AddDate(0, 0, maxScanDays).Before(query.To) {
+		return query, fmt.Errorf("without a street the range can be at most %d days", maxScanDays)
+	}
+	return query, nil
+}
+
+// at most maxAnalyticsPages pages of the range are read, a report that
+// stopped short is marked partial
+func (server *Server) responseTimes(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"format must be json or csv"})
+		return
+	}
+	query, err := parseAnalyticsQuery(r, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+
+	report, err := analytics.Analyze(r.Context(), server.reader, query, maxAnalyticsPages)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	if report.Partial {
+		w.Header().Set("X-Partial-Results", "true")
+	}
+	w.WriteHeader(http.StatusOK)
+	err = report.WriteCSV(w)
+	if err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
@@ -0,0 +1,192 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/api"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+type failingReader struct {
+	saved_calls.Reader
+}
+
+func (failingReader) GetActiveCalls(ctx context.Context) ([]saved_calls.SavedCall, error) {
+	return nil, errors.New("table unavailable")
+}
+
+var _ = Describe("Call API", func() {
+	var ctx context.Context
+	var store *saved_calls.InMemoryDataAccess
+	var server *api.Server
+
+	get := func(target string) (*httptest.ResponseRecorder, map[string]any) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		body := map[string]any{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+		return recorder, body
+	}
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		store = saved_calls.NewInMemory(time.Now)
+		server = api.New(store)
+
+		received := time.Date(2022, 3, 23, 23, 22, 39, 0, chesterfield.LocalTime)
+		for i, call := range []saved_calls.SavedCall{
+			{ID: "0123", CallType: "police", StreetName: "FAKE RD", LastKnownStatus: "dispatched"},
+			{ID: "0124", CallType: "fire", StreetName: "FAKE RD", LastKnownStatus: "dispatched"},
+			{ID: "0125", CallType: "police", StreetName: "EXAMPLE CT", LastKnownStatus: "dispatched"},
+		} {
+			call.CallReceived = received.AddDate(0, 0, i)
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+		}
+	})
+
+	It("filters history by street and call type", func() {
+		recorder, body := get("/calls?street=fake+road&type=police")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(body["calls"]).To(HaveLen(1))
+		Expect(body["calls"].([]any)[0].(map[string]any)["id"]).To(Equal("0123"))
+		Expect(body).ToNot(HaveKey("cursor"))
+	})
+
+	It("filters history by date", func() {
+		_, body := get("/calls?from=2022-03-24&to=2022-03-24")
+
+		Expect(body["calls"]).To(HaveLen(1))
+		Expect(body["calls"].([]any)[0].(map[string]any)["id"]).To(Equal("0124"))
+	})
+
+	It("pages through results with a cursor", func() {
+		_, first := get("/calls?limit=2")
+		Expect(first["calls"]).To(HaveLen(2))
+		Expect(first["cursor"]).ToNot(BeEmpty())
+
+		_, second := get("/calls?limit=2&cursor=" + first["cursor"].(string))
+		Expect(second["calls"]).To(HaveLen(1))
+		Expect(second["calls"].([]any)[0].(map[string]any)["id"]).To(Equal("0123"))
+	})
+
+	It("lists active calls", func() {
+		recorder, body := get("/calls/active")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(body["calls"]).To(HaveLen(3))
+	})
+
+	It("gets a call by id", func() {
+		recorder, body := get("/calls/0125")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(body["streetName"]).To(Equal("EXAMPLE CT"))
+	})
+
+	DescribeTable("rejects bad requests",
+		func(target string, status int, message string) {
+			recorder, body := get(target)
+
+			Expect(recorder.Code).To(Equal(status))
+			Expect(body["error"]).To(Equal(message))
+		},
+		Entry("unknown call", "/calls/9999", http.StatusNotFound, "call not found"),
+		Entry("bad date", "/calls?from=03/23/2022", http.StatusBadRequest, "from must be a date like 2022-03-23"),
+		Entry("bad limit", "/calls?limit=0", http.StatusBadRequest, "limit must be a positive number"),
+		Entry("bad cursor", "/calls?cursor=abc", http.StatusBadRequest, "invalid cursor"),
+		Entry("backwards range", "/analytics/response-times?from=2022-03-24&to=2022-03-23", http.StatusBadRequest, "from must not be after to"),
+		Entry("bad format", "/analytics/response-times?format=xml", http.StatusBadRequest, "format must be json or csv"),
+		Entry("long scan", "/analytics/response-times?from=2022-01-01&to=2022-03-23", http.StatusBadRequest, "without a street the range can be at most 31 days"),
+	)
+
+	It("reports response times", func() {
+		recorder, body := get("/analytics/response-times?from=2022-03-23&to=2022-03-24&type=police")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(body["from"]).To(Equal("2022-03-23"))
+		Expect(body["to"]).To(Equal("2022-03-24"))
+		Expect(body["overall"].(map[string]any)["calls"]).To(BeEquivalentTo(1))
+		Expect(body["byType"]).To(HaveLen(1))
+		Expect(body).ToNot(HaveKey("partial"))
+	})
+
+	It("scans a month across the end of daylight saving time", func() {
+		recorder, _ := get("/analytics/response-times?from=2022-10-15&to=2022-11-15")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("reads a street's range of any length", func() {
+		recorder, body := get("/analytics/response-times?from=2022-01-01&to=2022-03-31&street=fake+road")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(body["overall"].(map[string]any)["calls"]).To(BeEquivalentTo(2))
+	})
+
+	It("reports response times as csv", func() {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/analytics/response-times?from=2022-03-23&to=2022-03-25&format=csv", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+		Expect(recorder.Body.String()).To(HavePrefix("grouping,key,calls,"))
+		Expect(recorder.Body.String()).To(ContainSubstring("\noverall,,3,0,0,0,0,0,0\n"))
+	})
+
+	It("hides store errors", func() {
+		server = api.New(failingReader{})
+
+		recorder, body := get("/calls/active")
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(body["error"]).To(Equal("internal error"))
+	})
+
+	Describe("HandleFunctionURL()", func() {
+		It("routes function url requests", func() {
+			request := events.LambdaFunctionURLRequest{
+				RawPath:        "/calls",
+				RawQueryString: "street=EXAMPLE%20CT",
+				RequestContext: events.LambdaFunctionURLRequestContext{
+					HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{Method: "GET"},
+				},
+			}
+
+			response, err := api.HandleFunctionURL(ctx, server, request)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(response.Headers["Content-Type"]).To(Equal("application/json"))
+			page := saved_calls.CallPage{}
+			Expect(json.Unmarshal([]byte(response.Body), &page)).To(Succeed())
+			Expect(page.Calls).To(HaveLen(1))
+			Expect(page.Calls[0].ID).To(Equal("0125"))
+		})
+
+		It("rejects other methods", func() {
+			request := events.LambdaFunctionURLRequest{
+				RawPath: "/calls",
+				RequestContext: events.LambdaFunctionURLRequestContext{
+					HTTP: events.LambdaFunctionURLRequestContextHTTPDescription{Method: "DELETE"},
+				},
+			}
+
+			response, err := api.HandleFunctionURL(ctx, server, request)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
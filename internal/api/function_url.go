@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// responseRecorder collects a handler's response so it can be returned
+// to the function url in one piece
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (recorder *responseRecorder) Header() http.Header {
+	return recorder.header
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return recorder.body.Write(data)
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+}
+
+// HandleFunctionURL runs a lambda function url request through an http
+// handler, so the same routes work behind the url and a local server
+func HandleFunctionURL(ctx context.Context, handler http.Handler, request events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return events.LambdaFunctionURLResponse{StatusCode: http.StatusBadRequest}, nil
+		}
+		body = decoded
+	}
+
+	target := request.RawPath
+	if request.RawQueryString != "" {
+		target += "?" + request.RawQueryString
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, request.RequestContext.HTTP.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return events.LambdaFunctionURLResponse{}, err
+	}
+	for key, value := range request.Headers {
+		httpRequest.Header.Set(key, value)
+	}
+	for _, cookie := range request.Cookies {
+		httpRequest.Header.Add("Cookie", cookie)
+	}
+	httpRequest.RemoteAddr = request.RequestContext.HTTP.SourceIP
+
+	recorder := &responseRecorder{header: http.Header{}}
+	handler.ServeHTTP(recorder, httpRequest)
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+
+	headers := map[string]string{}
+	for key, values := range recorder.header {
+		headers[key] = strings.Join(values, ",")
+	}
+	return events.LambdaFunctionURLResponse{
+		StatusCode: recorder.status,
+		Headers:    headers,
+		Body:       recorder.body.String(),
+	}, nil
+}
@@ -1,22 +1,32 @@
 package chesterfield
 
 import (
+	"context"
+	"time"
+
 	"github.com/go-resty/resty/v2"
 )
 
 const (
 	baseURL = "https://api.chesterfield.gov/api"
+
+	defaultRequestTimeout = 10 * time.Second
+	// time kept back from the caller's deadline so what was already fetched
+	// can still be saved
+	deadlineMargin = 2 * time.Second
 )
 
 type ChesterfieldAPIClient struct {
-	RestClient   *resty.Client
-	policeApiKey string
-	fireApiKey   string
+	RestClient     *resty.Client
+	RequestTimeout time.Duration
+	policeApiKey   string
+	fireApiKey     string
 }
 
 type Client interface {
-	GetPoliceCalls() (CallForService, error)
-	GetFireCalls() (CallForService, error)
+	GetPoliceCalls(ctx context.Context) (CallForService, error)
+	GetFireCalls(ctx context.Context) (CallForService, error)
+	GetTrafficIncidents(ctx context.Context, jurisdiction string) (TrafficIncident, error)
 }
 
 func New(policeApiKey string, fireApiKey string) *ChesterfieldAPIClient {
@@ -26,8 +36,20 @@ func New(policeApiKey string, fireApiKey string) *ChesterfieldAPIClient {
 		SetRetryCount(1)
 
 	return &ChesterfieldAPIClient{
-		RestClient:   restClient,
-		policeApiKey: policeApiKey,
-		fireApiKey:   fireApiKey,
+		RestClient:     restClient,
+		RequestTimeout: defaultRequestTimeout,
+		policeApiKey:   policeApiKey,
+		fireApiKey:     fireApiKey,
+	}
+}
+
+// requestContext bounds a single request (retries included) by the request
+// timeout, or by the caller's deadline less a margin when that comes first,
+// e.g. the end of a lambda invocation
+func (client *ChesterfieldAPIClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(client.RequestTimeout)
+	if callerDeadline, ok := ctx.Deadline(); ok && callerDeadline.Add(-deadlineMargin).Before(deadline) {
+		deadline = callerDeadline.Add(-deadlineMargin)
 	}
+	return context.WithDeadline(ctx, deadline)
 }
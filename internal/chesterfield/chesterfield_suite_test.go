@@ -1,6 +1,7 @@
 package chesterfield_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -10,6 +11,7 @@ import (
 )
 
 var subject *chesterfield.ChesterfieldAPIClient
+var ctx = context.TODO()
 
 var _ = BeforeSuite(func() {
 	subject = chesterfield.New("testPoliceKey", "testFireKey")
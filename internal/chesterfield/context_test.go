@@ -0,0 +1,71 @@
+package chesterfield_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request Cancellation", func() {
+	var release chan struct{}
+
+	BeforeEach(func() {
+		// the county api never answers, only the context can end the request
+		release = make(chan struct{})
+		DeferCleanup(func() { close(release) })
+		hang := func(req *http.Request) (*http.Response, error) {
+			<-release
+			return httpmock.NewStringResponse(200, "[]"), nil
+		}
+		httpmock.RegisterResponder("GET", policeCallUrl, hang)
+		httpmock.RegisterResponder("GET", fireCallUrl, hang)
+		httpmock.RegisterResponder("GET", "https://api.chesterfield.gov/api/Police/V1.0/Traffic", hang)
+	})
+
+	It("aborts a fetch when the context is canceled", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		result, err := subject.GetPoliceCalls(canceled)
+
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(result).To(BeNil())
+	})
+
+	It("aborts every kind of fetch", func() {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := subject.GetFireCalls(canceled)
+		Expect(err).To(MatchError(context.Canceled))
+
+		_, err = subject.GetTrafficIncidents(canceled, "Chesterfield")
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("gives up ahead of the caller's deadline", func() {
+		// the same as a lambda with a little over two seconds left
+		deadline := time.Now().Add(2*time.Second + 50*time.Millisecond)
+		limited, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+
+		_, err := subject.GetFireCalls(limited)
+
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(time.Now()).To(BeTemporally("<", deadline))
+	})
+
+	It("times out a single request", func() {
+		defaultTimeout := subject.RequestTimeout
+		subject.RequestTimeout = 20 * time.Millisecond
+		DeferCleanup(func() { subject.RequestTimeout = defaultTimeout })
+
+		_, err := subject.GetPoliceCalls(ctx)
+
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+	})
+})
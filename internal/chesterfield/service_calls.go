@@ -1,6 +1,7 @@
 package chesterfield
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -15,9 +16,13 @@ type CallForService []struct {
 	CallReceivedFormatted string     `json:"callReceivedFormatted,omitempty"`
 }
 
-func (client *ChesterfieldAPIClient) getServiceCalls(service string, version string, authHeaderKey string, authHeaderValue string) (CallForService, error) {
+func (client *ChesterfieldAPIClient) getServiceCalls(ctx context.Context, service string, version string, authHeaderKey string, authHeaderValue string) (CallForService, error) {
+	ctx, cancel := client.requestContext(ctx)
+	defer cancel()
+
 	var result CallForService
 	response, err := client.RestClient.R().
+		SetContext(ctx).
 		SetResult(&result).
 		SetHeader(authHeaderKey, authHeaderValue).
 		SetPathParams(map[string]string{
@@ -39,11 +44,11 @@ func (client *ChesterfieldAPIClient) getServiceCalls(service string, version str
 }
 
 // GET https://api.chesterfield.gov/api/Police/V1.1/Calls/CallsForService
-func (client *ChesterfieldAPIClient) GetPoliceCalls() (CallForService, error) {
-	return client.getServiceCalls("Police", "V1.1", "X-Apikey", client.policeApiKey)
+func (client *ChesterfieldAPIClient) GetPoliceCalls(ctx context.Context) (CallForService, error) {
+	return client.getServiceCalls(ctx, "Police", "V1.1", "X-Apikey", client.policeApiKey)
 }
 
 // GET https://api.chesterfield.gov/api/Fire/V1.0/Calls/CallsForService
-func (client *ChesterfieldAPIClient) GetFireCalls() (CallForService, error) {
-	return client.getServiceCalls("Fire", "V1.0", "X-Apikey", client.fireApiKey)
+func (client *ChesterfieldAPIClient) GetFireCalls(ctx context.Context) (CallForService, error) {
+	return client.getServiceCalls(ctx, "Fire", "V1.0", "X-Apikey", client.fireApiKey)
 }
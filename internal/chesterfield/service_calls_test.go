@@ -22,7 +22,7 @@ var _ = Describe("Chesterfield API Client", func() {
 		responder, _ := httpmock.NewJsonResponder(200, httpmock.File("sample_responses/police_calls.json"))
 		httpmock.RegisterResponder("GET", policeCallUrl, responder)
 
-		result, err := subject.GetPoliceCalls()
+		result, err := subject.GetPoliceCalls(ctx)
 
 		Expect(err).ShouldNot(HaveOccurred())
 		Expect(result).ShouldNot(BeNil())
@@ -54,7 +54,7 @@ var _ = Describe("Chesterfield API Client", func() {
 		responder, _ := httpmock.NewJsonResponder(200, httpmock.File("sample_responses/fire_calls.json"))
 		httpmock.RegisterResponder("GET", fireCallUrl, responder)
 
-		result, err := subject.GetFireCalls()
+		result, err := subject.GetFireCalls(ctx)
 
 		Expect(err).ShouldNot(HaveOccurred())
 		Expect(result).ShouldNot(BeNil())
@@ -87,7 +87,7 @@ var _ = Describe("Chesterfield API Client", func() {
 			},
 		)
 
-		result, err := subject.GetPoliceCalls()
+		result, err := subject.GetPoliceCalls(ctx)
 
 		Expect(err).ShouldNot(HaveOccurred())
 		Expect(result).ShouldNot(BeNil())
@@ -108,7 +108,7 @@ var _ = Describe("Chesterfield API Client", func() {
 			},
 		)
 
-		result, err := subject.GetFireCalls()
+		result, err := subject.GetFireCalls(ctx)
 
 		Expect(err).ShouldNot(HaveOccurred())
 		Expect(result).ShouldNot(BeNil())
@@ -118,7 +118,7 @@ var _ = Describe("Chesterfield API Client", func() {
 		responder := httpmock.NewStringResponder(500, "")
 		httpmock.RegisterResponder("GET", policeCallUrl, responder)
 
-		result, err := subject.GetPoliceCalls()
+		result, err := subject.GetPoliceCalls(ctx)
 
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("received invalid status code: 500"))
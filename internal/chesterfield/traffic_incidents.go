@@ -1,5 +1,22 @@
 package chesterfield
 
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	JurisdictionChesterfield = "Chesterfield"
+	JurisdictionHenrico      = "Henrico"
+	JurisdictionRichmond     = "Richmond"
+)
+
+var Jurisdictions = []string{
+	JurisdictionChesterfield,
+	JurisdictionHenrico,
+	JurisdictionRichmond,
+}
+
 // GET https://api.chesterfield.gov/api/Police/V1.0/Traffic
 // GET https://api.chesterfield.gov/api/Police/V1.0/Traffic/Henrico
 // GET https://api.chesterfield.gov/api/Police/V1.0/Traffic/Richmond
@@ -12,3 +29,42 @@ type TrafficIncident []struct {
 	Lon       string `json:"Lon"`
 	Lat       string `json:"Lat"`
 }
+
+func trafficPath(jurisdiction string) (string, error) {
+	switch jurisdiction {
+	case JurisdictionChesterfield:
+		return "Traffic", nil
+	case JurisdictionHenrico, JurisdictionRichmond:
+		return "Traffic/" + jurisdiction, nil
+	default:
+		return "", fmt.Errorf("unknown jurisdiction: %s", jurisdiction)
+	}
+}
+
+func (client *ChesterfieldAPIClient) GetTrafficIncidents(ctx context.Context, jurisdiction string) (TrafficIncident, error) {
+	path, err := trafficPath(jurisdiction)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := client.requestContext(ctx)
+	defer cancel()
+
+	var result TrafficIncident
+	response, err := client.RestClient.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetHeader("X-Apikey", client.policeApiKey).
+		Get("Police/V1.0/" + path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.IsError() {
+		return nil, fmt.Errorf("received invalid status code: %d", response.StatusCode())
+	}
+
+	slice := response.Result().(*TrafficIncident)
+	return *slice, nil
+}
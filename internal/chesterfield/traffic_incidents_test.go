@@ -0,0 +1,89 @@
+package chesterfield_test
+
+import (
+	"net/http"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	chesterfieldTrafficUrl = "https://api.chesterfield.gov/api/Police/V1.0/Traffic"
+	henricoTrafficUrl      = "https://api.chesterfield.gov/api/Police/V1.0/Traffic/Henrico"
+	richmondTrafficUrl     = "https://api.chesterfield.gov/api/Police/V1.0/Traffic/Richmond"
+)
+
+var _ = Describe("Traffic Incidents", func() {
+	It("returns a list of chesterfield traffic incidents", func() {
+		responder, _ := httpmock.NewJsonResponder(200, httpmock.File("sample_responses/traffic_incidents.json"))
+		httpmock.RegisterResponder("GET", chesterfieldTrafficUrl, responder)
+
+		result, err := subject.GetTrafficIncidents(ctx, chesterfield.JurisdictionChesterfield)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result).ShouldNot(BeNil())
+		Expect(len(result)).To(Equal(2))
+		Expect(result[0].Location).To(Equal("HULL ST RD AT COALFIELD RD"))
+		Expect(result[0].Direction).To(Equal("EB"))
+		Expect(result[0].Status).To(Equal("Active"))
+		Expect(result[0].Incident).To(Equal("Vehicle Crash"))
+		Expect(result[0].Type).To(Equal("Crash"))
+		Expect(result[0].Lon).To(Equal("-77.6512"))
+		Expect(result[0].Lat).To(Equal("37.4521"))
+		Expect(result[1].Location).To(Equal("RT 288 AT MIDLOTHIAN TPKE"))
+	})
+	It("requests neighboring jurisdictions", func() {
+		responder, _ := httpmock.NewJsonResponder(200, httpmock.File("sample_responses/traffic_incidents.json"))
+		httpmock.RegisterResponder("GET", henricoTrafficUrl, responder)
+		httpmock.RegisterResponder("GET", richmondTrafficUrl, responder)
+
+		result, err := subject.GetTrafficIncidents(ctx, chesterfield.JurisdictionHenrico)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(result)).To(Equal(2))
+
+		result, err = subject.GetTrafficIncidents(ctx, chesterfield.JurisdictionRichmond)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(result)).To(Equal(2))
+
+		Expect(httpmock.GetCallCountInfo()["GET "+henricoTrafficUrl]).To(Equal(1))
+		Expect(httpmock.GetCallCountInfo()["GET "+richmondTrafficUrl]).To(Equal(1))
+	})
+	It("passes correct headers", func() {
+		httpmock.RegisterResponder("GET", chesterfieldTrafficUrl,
+			func(req *http.Request) (*http.Response, error) {
+				resp, err := httpmock.NewJsonResponse(200, httpmock.File("sample_responses/traffic_incidents.json"))
+				if err != nil {
+					return nil, err
+				}
+
+				Expect(req.Header["X-Apikey"][0]).To(Equal("testPoliceKey"))
+				Expect(req.Header["Referer"][0]).To(Equal("https://www.chesterfield.gov/"))
+
+				return resp, nil
+			},
+		)
+
+		_, err := subject.GetTrafficIncidents(ctx, chesterfield.JurisdictionChesterfield)
+
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+	It("rejects unknown jurisdictions", func() {
+		result, err := subject.GetTrafficIncidents(ctx, "Hanover")
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("unknown jurisdiction: Hanover"))
+		Expect(result).To(BeNil())
+	})
+	It("returns error on non-successful status code", func() {
+		responder := httpmock.NewStringResponder(500, "")
+		httpmock.RegisterResponder("GET", chesterfieldTrafficUrl, responder)
+
+		result, err := subject.GetTrafficIncidents(ctx, chesterfield.JurisdictionChesterfield)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("received invalid status code: 500"))
+		Expect(result).To(BeNil())
+	})
+})
@@ -0,0 +1,69 @@
+package dashboard_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/dashboard"
+)
+
+var _ = Describe("Dashboard", func() {
+	DescribeTable("serves the embedded page",
+		func(target string, contentType string, content string) {
+			recorder := httptest.NewRecorder()
+			dashboard.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(HavePrefix(contentType))
+			Expect(recorder.Body.String()).To(ContainSubstring(content))
+		},
+		Entry("index", "/", "text/html", `<table id="active">`),
+		Entry("script", "/app.js", "text/javascript", `fetch("calls/active")`),
+		Entry("styles", "/style.css", "text/css", "#map"),
+	)
+
+	It("has no event stream of its own", func() {
+		recorder := httptest.NewRecorder()
+		dashboard.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	Describe("Broadcaster", func() {
+		It("sends an event to open streams on every harvest", func() {
+			broadcaster := dashboard.NewBroadcaster()
+			server := httptest.NewServer(broadcaster)
+			DeferCleanup(server.Close)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			DeferCleanup(cancel)
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			response, err := http.DefaultClient.Do(request)
+			Expect(err).ShouldNot(HaveOccurred())
+			DeferCleanup(response.Body.Close)
+			Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+			lines := make(chan string)
+			go func() {
+				defer GinkgoRecover()
+				scanner := bufio.NewScanner(response.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+				close(lines)
+			}()
+
+			broadcaster.Notify()
+
+			Eventually(lines).Should(Receive(Equal("event: harvest")))
+			Eventually(lines).Should(Receive(Equal("data: {}")))
+		})
+	})
+})
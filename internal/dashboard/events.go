@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Broadcaster tells open dashboards that a harvest finished, over server
+// sent events. Only a long running process can hold the connections, so
+// behind lambda the page falls back to polling.
+type Broadcaster struct {
+	mutex     sync.Mutex
+	listeners map[chan struct{}]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		listeners: map[chan struct{}]struct{}{},
+	}
+}
+
+func (broadcaster *Broadcaster) Notify() {
+	broadcaster.mutex.Lock()
+	defer broadcaster.mutex.Unlock()
+
+	for listener := range broadcaster.listeners {
+		// a listener that hasn't caught up yet will refresh anyway
+		select {
+		case listener <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (broadcaster *Broadcaster) subscribe() chan struct{} {
+	broadcaster.mutex.Lock()
+	defer broadcaster.mutex.Unlock()
+
+	listener := make(chan struct{}, 1)
+	broadcaster.listeners[listener] = struct{}{}
+	return listener
+}
+
+func (broadcaster *Broadcaster) unsubscribe(listener chan struct{}) {
+	broadcaster.mutex.Lock()
+	defer broadcaster.mutex.Unlock()
+
+	delete(broadcaster.listeners, listener)
+}
+
+func (broadcaster *Broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	listener := broadcaster.subscribe()
+	defer broadcaster.unsubscribe(listener)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-listener:
+			fmt.Fprint(w, "event: harvest\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
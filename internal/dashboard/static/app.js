@@ -0,0 +1,178 @@
+"use strict";
+
+// harvests run every five minutes, polling is only used when the server
+// can't push an event after each one
+const POLL_INTERVAL = 5 * 60 * 1000;
+const CHESTERFIELD = [37.38, -77.58];
+
+const map = L.map("map").setView(CHESTERFIELD, 11);
+L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+  maxZoom: 19,
+  attribution: "&copy; OpenStreetMap contributors",
+}).addTo(map);
+const markers = L.layerGroup().addTo(map);
+
+let activeCalls = [];
+let sort = { key: "callReceived", direction: -1 };
+let history = { street: "", cursor: "" };
+
+function formatTime(value) {
+  return value ? new Date(value).toLocaleString() : "";
+}
+
+function cell(row, text) {
+  const td = document.createElement("td");
+  td.textContent = text || "";
+  row.appendChild(td);
+  return td;
+}
+
+function streetCell(row, call) {
+  const td = cell(row, "");
+  const link = document.createElement("a");
+  link.href = "#";
+  link.className = "street";
+  link.textContent = call.location || call.streetName;
+  link.addEventListener("click", (event) => {
+    event.preventDefault();
+    showHistory(call.streetName);
+  });
+  td.appendChild(link);
+}
+
+function renderActive() {
+  const sorted = [...activeCalls].sort((a, b) => {
+    const left = a[sort.key] || "";
+    const right = b[sort.key] || "";
+    return left.localeCompare(right, undefined, { numeric: true }) * sort.direction;
+  });
+
+  const body = document.querySelector("#active tbody");
+  body.replaceChildren();
+  for (const call of sorted) {
+    const row = document.createElement("tr");
+    cell(row, formatTime(call.callReceived));
+    cell(row, call.callType);
+    cell(row, call.callReason);
+    cell(row, call.lastKnownStatus);
+    streetCell(row, call);
+    cell(row, call.area);
+    cell(row, call.priority);
+    body.appendChild(row);
+  }
+
+  for (const th of document.querySelectorAll("#active th")) {
+    th.classList.toggle("asc", th.dataset.key === sort.key && sort.direction > 0);
+    th.classList.toggle("desc", th.dataset.key === sort.key && sort.direction < 0);
+  }
+}
+
+// the county's text goes in as text, a popup given a string renders it as html
+function popup(lines) {
+  const content = document.createElement("div");
+  lines.forEach((line, i) => {
+    if (i > 0) {
+      content.appendChild(document.createElement("br"));
+    }
+    content.appendChild(document.createTextNode(line || ""));
+  });
+  return content;
+}
+
+function renderMap(incidents) {
+  markers.clearLayers();
+  for (const call of activeCalls) {
+    if (call.latitude && call.longitude) {
+      L.circleMarker([call.latitude, call.longitude], { color: call.callType === "fire" ? "#c0392b" : "#1f5fa8" })
+        .bindPopup(popup([call.callReason, call.location, call.lastKnownStatus]))
+        .addTo(markers);
+    }
+  }
+  for (const incident of incidents) {
+    if (incident.latitude && incident.longitude) {
+      L.marker([incident.latitude, incident.longitude])
+        .bindPopup(popup([incident.incident, incident.location, incident.jurisdiction]))
+        .addTo(markers);
+    }
+  }
+}
+
+async function fetchIncidents() {
+  const response = await fetch("graphql", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ query: "{ activeIncidents { incident location jurisdiction latitude longitude } }" }),
+  });
+  const result = await response.json();
+  return (result.data && result.data.activeIncidents) || [];
+}
+
+async function refresh() {
+  try {
+    const [calls, incidents] = await Promise.all([
+      fetch("calls/active").then((response) => response.json()),
+      fetchIncidents(),
+    ]);
+    activeCalls = calls.calls;
+    renderActive();
+    renderMap(incidents);
+    document.getElementById("updated").textContent = `Updated ${new Date().toLocaleTimeString()}`;
+  } catch (error) {
+    document.getElementById("updated").textContent = `Update failed: ${error}`;
+  }
+}
+
+async function loadHistory() {
+  const params = new URLSearchParams({ street: history.street, limit: "25" });
+  if (history.cursor) {
+    params.set("cursor", history.cursor);
+  }
+  const page = await fetch(`calls?${params}`).then((response) => response.json());
+
+  const body = document.querySelector("#history tbody");
+  for (const call of page.calls) {
+    const row = document.createElement("tr");
+    cell(row, formatTime(call.callReceived));
+    cell(row, call.callType);
+    cell(row, call.callReason);
+    cell(row, call.lastKnownStatus);
+    cell(row, call.location);
+    cell(row, formatTime(call.callResolved));
+    body.appendChild(row);
+  }
+
+  history.cursor = page.cursor || "";
+  document.getElementById("history-more").hidden = !history.cursor;
+}
+
+function showHistory(street) {
+  history = { street, cursor: "" };
+  document.getElementById("history-street").textContent = street;
+  document.querySelector("#history tbody").replaceChildren();
+  document.getElementById("history").hidden = false;
+  loadHistory();
+}
+
+for (const th of document.querySelectorAll("#active th")) {
+  th.addEventListener("click", () => {
+    const direction = sort.key === th.dataset.key ? -sort.direction : 1;
+    sort = { key: th.dataset.key, direction };
+    renderActive();
+  });
+}
+document.getElementById("history-more").addEventListener("click", loadHistory);
+
+function listen() {
+  const source = new EventSource("events");
+  source.addEventListener("harvest", refresh);
+  source.onerror = () => {
+    // a dropped stream reconnects by itself, but lambda can't stream at all
+    // and answers with a 404 that closes the source for good
+    if (source.readyState === EventSource.CLOSED) {
+      setInterval(refresh, POLL_INTERVAL);
+    }
+  };
+}
+
+refresh();
+listen();
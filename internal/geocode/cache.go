@@ -0,0 +1,65 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type cacheEntry struct {
+	coordinates Coordinates
+	found       bool
+}
+
+// CachedProvider remembers answers, including misses, so a call seen on
+// every harvest is only looked up once per process
+type CachedProvider struct {
+	provider Provider
+	mutex    sync.Mutex
+	entries  map[string]cacheEntry
+}
+
+func NewCached(provider Provider) *CachedProvider {
+	return &CachedProvider{
+		provider: provider,
+		entries:  map[string]cacheEntry{},
+	}
+}
+
+func (cache *CachedProvider) Geocode(ctx context.Context, houseNumber string, streetName string) (Coordinates, error) {
+	key := houseNumber + "\x00" + normalizeStreet(streetName)
+	return cache.lookup(key, func() (Coordinates, error) {
+		return cache.provider.Geocode(ctx, houseNumber, streetName)
+	})
+}
+
+func (cache *CachedProvider) GeocodeIntersection(ctx context.Context, streetName string, crossStreet string) (Coordinates, error) {
+	// a block never has a slash, so this can't collide with one
+	key := "/\x00" + normalizeStreet(streetName) + "\x00" + normalizeStreet(crossStreet)
+	return cache.lookup(key, func() (Coordinates, error) {
+		return cache.provider.GeocodeIntersection(ctx, streetName, crossStreet)
+	})
+}
+
+func (cache *CachedProvider) lookup(key string, geocode func() (Coordinates, error)) (Coordinates, error) {
+	cache.mutex.Lock()
+	entry, ok := cache.entries[key]
+	cache.mutex.Unlock()
+	if ok {
+		if !entry.found {
+			return Coordinates{}, ErrNotFound
+		}
+		return entry.coordinates, nil
+	}
+
+	coordinates, err := geocode()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		// anything else might work next time
+		return Coordinates{}, err
+	}
+
+	cache.mutex.Lock()
+	cache.entries[key] = cacheEntry{coordinates: coordinates, found: err == nil}
+	cache.mutex.Unlock()
+	return coordinates, err
+}
@@ -0,0 +1,59 @@
+package geocode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+)
+
+var ErrNotFound = errors.New("location not found")
+
+var blockRegex = regexp.MustCompile(`^(\d*)XX$`)
+
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Provider turns a masked hundred-block, e.g. "22XX", and a street name into
+// an approximate position. An empty house number means anywhere on the
+// street. Calls at an intersection have no block, they are placed where the
+// two streets meet.
+type Provider interface {
+	Geocode(ctx context.Context, houseNumber string, streetName string) (Coordinates, error)
+	GeocodeIntersection(ctx context.Context, streetName string, crossStreet string) (Coordinates, error)
+}
+
+// Block is the range of house numbers a masked house number can stand for
+type Block struct {
+	From int
+	To   int
+}
+
+// ParseBlock reads "22XX" as 2200-2299 and "XX" as 0-99
+func ParseBlock(houseNumber string) (Block, error) {
+	match := blockRegex.FindStringSubmatch(strings.ToUpper(houseNumber))
+	if match == nil {
+		number, err := strconv.Atoi(houseNumber)
+		if err != nil {
+			return Block{}, fmt.Errorf("invalid house number: %s", houseNumber)
+		}
+		return Block{From: number, To: number}, nil
+	}
+
+	hundreds := 0
+	if match[1] != "" {
+		hundreds, _ = strconv.Atoi(match[1])
+	}
+	return Block{From: hundreds * 100, To: hundreds*100 + 99}, nil
+}
+
+// TIGER/Line names are spelled like "Fake Rd", calls like "FAKE RD"
+func normalizeStreet(streetName string) string {
+	return address.NormalizeStreet(streetName)
+}
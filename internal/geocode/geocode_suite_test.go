@@ -0,0 +1,13 @@
+package geocode_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGeocode(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Geocode Suite")
+}
@@ -0,0 +1,168 @@
+package geocode_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geocode"
+)
+
+type countingProvider struct {
+	calls int
+	err   error
+}
+
+func (provider *countingProvider) Geocode(ctx context.Context, houseNumber string, streetName string) (geocode.Coordinates, error) {
+	provider.calls++
+	return geocode.Coordinates{Latitude: 1, Longitude: 2}, provider.err
+}
+
+func (provider *countingProvider) GeocodeIntersection(ctx context.Context, streetName string, crossStreet string) (geocode.Coordinates, error) {
+	provider.calls++
+	return geocode.Coordinates{Latitude: 3, Longitude: 4}, provider.err
+}
+
+var _ = Describe("Geocoding", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+	})
+
+	DescribeTable("ParseBlock()",
+		func(houseNumber string, expected geocode.Block) {
+			Expect(geocode.ParseBlock(houseNumber)).To(Equal(expected))
+		},
+		Entry("hundred block", "22XX", geocode.Block{From: 2200, To: 2299}),
+		Entry("lowercase", "22xx", geocode.Block{From: 2200, To: 2299}),
+		Entry("first block", "XX", geocode.Block{From: 0, To: 99}),
+		Entry("exact number", "2215", geocode.Block{From: 2215, To: 2215}),
+	)
+
+	It("rejects house numbers it can't read", func() {
+		_, err := geocode.ParseBlock("A1")
+
+		Expect(err).To(MatchError("invalid house number: A1"))
+	})
+
+	Describe("RangeProvider", func() {
+		var provider *geocode.RangeProvider
+
+		BeforeEach(func() {
+			var err error
+			provider, err = geocode.LoadRanges("testdata/ranges.csv")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		DescribeTable("interpolates blocks along segments",
+			func(houseNumber string, streetName string, latitude float64, longitude float64) {
+				result, err := provider.Geocode(ctx, houseNumber, streetName)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Latitude).To(BeNumerically("~", latitude, 0.0001))
+				Expect(result.Longitude).To(BeNumerically("~", longitude, 0.0001))
+			},
+			Entry("block on the second segment", "22XX", "FAKE RD", 37.4150, -77.6),
+			Entry("street names ignore case and spacing", "20XX", "fake  rd", 37.4025, -77.6),
+			Entry("ranges running backwards", "43XX", "EXAMPLE CT", 37.5, -77.4950),
+		)
+
+		DescribeTable("misses",
+			func(houseNumber string, streetName string) {
+				_, err := provider.Geocode(ctx, houseNumber, streetName)
+
+				Expect(err).To(Equal(geocode.ErrNotFound))
+			},
+			Entry("unknown street", "22XX", "NOWHERE LN"),
+			Entry("block off the end of the street", "99XX", "FAKE RD"),
+			Entry("street without addresses", "1XX", "SHORT ST"),
+			Entry("no house number", "", "FAKE RD"),
+		)
+
+		It("places an intersection where the streets' segments meet", func() {
+			result, err := provider.GeocodeIntersection(ctx, "FAKE RD", "OTHER ST")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(Equal(geocode.Coordinates{Latitude: 37.41, Longitude: -77.6}))
+		})
+
+		It("misses streets that don't meet", func() {
+			_, err := provider.GeocodeIntersection(ctx, "FAKE RD", "EXAMPLE CT")
+
+			Expect(err).To(Equal(geocode.ErrNotFound))
+		})
+
+		It("reports malformed rows", func() {
+			_, err := geocode.ReadRanges(strings.NewReader("FULLNAME,LFROMHN,LTOHN,RFROMHN,RTOHN,FROMLAT,FROMLON,TOLAT,TOLON\nFake Rd,1,99,,,north,-77,37,-77\n"))
+
+			Expect(err).To(MatchError("address ranges line 2: invalid FROMLAT: north"))
+		})
+
+		It("requires the range columns", func() {
+			_, err := geocode.ReadRanges(strings.NewReader("FULLNAME,FROMLAT\n"))
+
+			Expect(err).To(MatchError("address ranges missing column: LFROMHN"))
+		})
+	})
+
+	Describe("CachedProvider", func() {
+		It("looks each block up once", func() {
+			inner := &countingProvider{}
+			cache := geocode.NewCached(inner)
+
+			for range 3 {
+				result, err := cache.Geocode(ctx, "22XX", "FAKE RD")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Latitude).To(Equal(1.0))
+			}
+			_, err := cache.Geocode(ctx, "22XX", "fake rd")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(inner.calls).To(Equal(1))
+		})
+
+		It("keeps intersections apart from blocks", func() {
+			inner := &countingProvider{}
+			cache := geocode.NewCached(inner)
+
+			block, err := cache.Geocode(ctx, "", "FAKE RD")
+			Expect(err).ShouldNot(HaveOccurred())
+			intersection, err := cache.GeocodeIntersection(ctx, "FAKE RD", "OTHER ST")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = cache.GeocodeIntersection(ctx, "fake rd", "other st")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(block.Latitude).To(Equal(1.0))
+			Expect(intersection.Latitude).To(Equal(3.0))
+			Expect(inner.calls).To(Equal(2))
+		})
+
+		It("remembers misses", func() {
+			inner := &countingProvider{err: geocode.ErrNotFound}
+			cache := geocode.NewCached(inner)
+
+			_, err := cache.Geocode(ctx, "22XX", "FAKE RD")
+			Expect(err).To(Equal(geocode.ErrNotFound))
+			_, err = cache.Geocode(ctx, "22XX", "FAKE RD")
+			Expect(err).To(Equal(geocode.ErrNotFound))
+
+			Expect(inner.calls).To(Equal(1))
+		})
+
+		It("retries other errors", func() {
+			inner := &countingProvider{err: errors.New("unavailable")}
+			cache := geocode.NewCached(inner)
+
+			_, err := cache.Geocode(ctx, "22XX", "FAKE RD")
+			Expect(err).To(HaveOccurred())
+			_, err = cache.Geocode(ctx, "22XX", "FAKE RD")
+			Expect(err).To(HaveOccurred())
+
+			Expect(inner.calls).To(Equal(2))
+		})
+	})
+})
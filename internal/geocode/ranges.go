@@ -0,0 +1,199 @@
+package geocode
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// segment ends closer than this, about a meter, are the same node
+const sameNode = 0.00001
+
+// the columns read from an address range file, named after the TIGER/Line
+// address range feature fields plus the ends of each edge
+var rangeColumns = []string{"FULLNAME", "LFROMHN", "LTOHN", "RFROMHN", "RTOHN", "FROMLAT", "FROMLON", "TOLAT", "TOLON"}
+
+type addressRange struct {
+	from int
+	to   int
+}
+
+type segment struct {
+	sides []addressRange
+	start Coordinates
+	end   Coordinates
+}
+
+// RangeProvider interpolates a block along the street segment whose
+// address range covers it, the way TIGER/Line address ranges are meant to
+// be used. Everything is held in memory, nothing goes over the network.
+type RangeProvider struct {
+	streets map[string][]segment
+}
+
+func LoadRanges(path string) (*RangeProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadRanges(file)
+}
+
+func ReadRanges(reader io.Reader) (*RangeProvider, error) {
+	records := csv.NewReader(reader)
+	header, err := records.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read address range header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range rangeColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("address ranges missing column: %s", name)
+		}
+	}
+
+	provider := &RangeProvider{streets: map[string][]segment{}}
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := records.FieldPos(0)
+		parsed, err := parseSegment(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("address ranges line %d: %w", line, err)
+		}
+		street := normalizeStreet(record[columns["FULLNAME"]])
+		provider.streets[street] = append(provider.streets[street], parsed)
+	}
+	return provider, nil
+}
+
+func parseSegment(record []string, columns map[string]int) (segment, error) {
+	coordinates := map[string]float64{}
+	for _, name := range []string{"FROMLAT", "FROMLON", "TOLAT", "TOLON"} {
+		value, err := strconv.ParseFloat(record[columns[name]], 64)
+		if err != nil {
+			return segment{}, fmt.Errorf("invalid %s: %s", name, record[columns[name]])
+		}
+		coordinates[name] = value
+	}
+
+	parsed := segment{
+		start: Coordinates{Latitude: coordinates["FROMLAT"], Longitude: coordinates["FROMLON"]},
+		end:   Coordinates{Latitude: coordinates["TOLAT"], Longitude: coordinates["TOLON"]},
+	}
+	// either side of a street may have no addresses
+	for _, side := range [][2]string{{"LFROMHN", "LTOHN"}, {"RFROMHN", "RTOHN"}} {
+		fromValue, toValue := record[columns[side[0]]], record[columns[side[1]]]
+		if fromValue == "" || toValue == "" {
+			continue
+		}
+		from, err := strconv.Atoi(fromValue)
+		if err != nil {
+			return segment{}, fmt.Errorf("invalid %s: %s", side[0], fromValue)
+		}
+		to, err := strconv.Atoi(toValue)
+		if err != nil {
+			return segment{}, fmt.Errorf("invalid %s: %s", side[1], toValue)
+		}
+		parsed.sides = append(parsed.sides, addressRange{from: from, to: to})
+	}
+	return parsed, nil
+}
+
+func interpolate(start Coordinates, end Coordinates, fraction float64) Coordinates {
+	return Coordinates{
+		Latitude:  start.Latitude + (end.Latitude-start.Latitude)*fraction,
+		Longitude: start.Longitude + (end.Longitude-start.Longitude)*fraction,
+	}
+}
+
+// locate finds where the block falls on one side of a segment. Ranges can
+// run in either direction, so the fraction is measured from the range's
+// own start.
+func (side addressRange) locate(block Block) (float64, int, bool) {
+	low, high := min(side.from, side.to), max(side.from, side.to)
+	overlapLow, overlapHigh := max(low, block.From), min(high, block.To)
+	if overlapLow > overlapHigh {
+		return 0, 0, false
+	}
+	if side.from == side.to {
+		return 0.5, 1, true
+	}
+
+	middle := float64(overlapLow+overlapHigh) / 2
+	fraction := (middle - float64(side.from)) / float64(side.to-side.from)
+	return fraction, overlapHigh - overlapLow + 1, true
+}
+
+func (provider *RangeProvider) Geocode(ctx context.Context, houseNumber string, streetName string) (Coordinates, error) {
+	segments := provider.streets[normalizeStreet(streetName)]
+	if len(segments) == 0 {
+		return Coordinates{}, ErrNotFound
+	}
+
+	if houseNumber == "" {
+		// without a block any point on the street could be miles off, so
+		// the call is left off the map
+		return Coordinates{}, ErrNotFound
+	}
+
+	block, err := ParseBlock(houseNumber)
+	if err != nil {
+		return Coordinates{}, err
+	}
+
+	// a block can span several short segments, use the one that covers
+	// the most of it
+	var best Coordinates
+	bestOverlap := 0
+	for _, candidate := range segments {
+		for _, side := range candidate.sides {
+			fraction, overlap, ok := side.locate(block)
+			if ok && overlap > bestOverlap {
+				best = interpolate(candidate.start, candidate.end, fraction)
+				bestOverlap = overlap
+			}
+		}
+	}
+	if bestOverlap == 0 {
+		return Coordinates{}, ErrNotFound
+	}
+	return best, nil
+}
+
+func sameNodeAs(a Coordinates, b Coordinates) bool {
+	return math.Abs(a.Latitude-b.Latitude) < sameNode && math.Abs(a.Longitude-b.Longitude) < sameNode
+}
+
+// GeocodeIntersection finds a node both streets have a segment ending at.
+// Segments without addresses count too, a street can meet another where
+// nobody lives.
+func (provider *RangeProvider) GeocodeIntersection(ctx context.Context, streetName string, crossStreet string) (Coordinates, error) {
+	crossSegments := provider.streets[normalizeStreet(crossStreet)]
+	for _, candidate := range provider.streets[normalizeStreet(streetName)] {
+		for _, node := range []Coordinates{candidate.start, candidate.end} {
+			for _, cross := range crossSegments {
+				if sameNodeAs(node, cross.start) || sameNodeAs(node, cross.end) {
+					return node, nil
+				}
+			}
+		}
+	}
+	return Coordinates{}, ErrNotFound
+}
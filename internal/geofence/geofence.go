@@ -0,0 +1,140 @@
+package geofence
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// mean radius, which keeps haversine within about half a percent
+const earthRadiusMeters = 6371008.8
+
+type Point struct {
+	Latitude  float64 `dynamodbav:"latitude" json:"latitude"`
+	Longitude float64 `dynamodbav:"longitude" json:"longitude"`
+}
+
+// Polygon is a GeoJSON Polygon geometry. Positions are [longitude, latitude]
+// as in GeoJSON, the first ring is the outline and any others are holes.
+type Polygon struct {
+	Type        string        `dynamodbav:"type" json:"type"`
+	Coordinates [][][]float64 `dynamodbav:"coordinates" json:"coordinates"`
+}
+
+// Fence is either a circle around a point, e.g. a home address, or an
+// arbitrary polygon like a neighborhood
+type Fence struct {
+	Center       *Point   `dynamodbav:"center,omitempty" json:"center,omitempty"`
+	RadiusMeters float64  `dynamodbav:"radiusMeters,omitempty" json:"radiusMeters,omitempty"`
+	Polygon      *Polygon `dynamodbav:"polygon,omitempty" json:"polygon,omitempty"`
+}
+
+func validatePoint(latitude float64, longitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("invalid coordinates: %v, %v", latitude, longitude)
+	}
+	return nil
+}
+
+func (polygon *Polygon) Validate() error {
+	if polygon.Type != "Polygon" {
+		return fmt.Errorf("unsupported geometry type: %s", polygon.Type)
+	}
+	if len(polygon.Coordinates) == 0 {
+		return errors.New("polygon has no rings")
+	}
+	for _, ring := range polygon.Coordinates {
+		if len(ring) < 4 {
+			return errors.New("polygon rings need at least four positions")
+		}
+		for _, position := range ring {
+			if len(position) < 2 {
+				return errors.New("polygon positions need a longitude and latitude")
+			}
+			if err := validatePoint(position[1], position[0]); err != nil {
+				return err
+			}
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return errors.New("polygon rings must end where they start")
+		}
+	}
+	return nil
+}
+
+func (fence *Fence) Validate() error {
+	switch {
+	case fence.Center != nil && fence.Polygon != nil:
+		return errors.New("a fence has either a center or a polygon, not both")
+	case fence.Center != nil:
+		if fence.RadiusMeters <= 0 {
+			return errors.New("a fence around a point needs a positive radius")
+		}
+		return validatePoint(fence.Center.Latitude, fence.Center.Longitude)
+	case fence.Polygon != nil:
+		return fence.Polygon.Validate()
+	default:
+		return errors.New("a fence needs a center or a polygon")
+	}
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Distance is the great circle distance between two points, in meters
+func Distance(from Point, to Point) float64 {
+	deltaLatitude := radians(to.Latitude - from.Latitude)
+	deltaLongitude := radians(to.Longitude - from.Longitude)
+	a := math.Pow(math.Sin(deltaLatitude/2), 2) +
+		math.Cos(radians(from.Latitude))*math.Cos(radians(to.Latitude))*math.Pow(math.Sin(deltaLongitude/2), 2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ray casting, a county is small enough to treat degrees as flat. A ring
+// with a position short of a longitude and latitude contains nothing.
+func ringContains(ring [][]float64, point Point) bool {
+	for _, position := range ring {
+		if len(position) < 2 {
+			return false
+		}
+	}
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > point.Latitude) != (yj > point.Latitude) &&
+			point.Longitude < (xj-xi)*(point.Latitude-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (polygon *Polygon) Contains(point Point) bool {
+	if len(polygon.Coordinates) == 0 || !ringContains(polygon.Coordinates[0], point) {
+		return false
+	}
+	for _, hole := range polygon.Coordinates[1:] {
+		if ringContains(hole, point) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether the point is inside the fence. Calls that
+// couldn't be geocoded have no coordinates, and are never inside.
+func (fence *Fence) Contains(point Point) bool {
+	if point.Latitude == 0 && point.Longitude == 0 {
+		return false
+	}
+	if fence.Center != nil {
+		return Distance(*fence.Center, point) <= fence.RadiusMeters
+	}
+	if fence.Polygon != nil {
+		return fence.Polygon.Contains(point)
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package geofence_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geofence"
+)
+
+// a square around the county government complex with a hole in the middle
+const squareGeoJSON = `{
+	"type": "Polygon",
+	"coordinates": [
+		[[-77.53, 37.37], [-77.51, 37.37], [-77.51, 37.39], [-77.53, 37.39], [-77.53, 37.37]],
+		[[-77.521, 37.379], [-77.519, 37.379], [-77.519, 37.381], [-77.521, 37.381], [-77.521, 37.379]]
+	]
+}`
+
+var home = geofence.Point{Latitude: 37.3771, Longitude: -77.5050}
+
+var _ = Describe("Geofence", func() {
+	var square *geofence.Polygon
+
+	BeforeEach(func() {
+		square = &geofence.Polygon{}
+		Expect(json.Unmarshal([]byte(squareGeoJSON), square)).To(Succeed())
+	})
+
+	It("measures great circle distance", func() {
+		// one minute of latitude is a nautical mile
+		north := geofence.Point{Latitude: home.Latitude + 1.0/60, Longitude: home.Longitude}
+
+		Expect(geofence.Distance(home, north)).To(BeNumerically("~", 1853, 2))
+		Expect(geofence.Distance(home, home)).To(BeZero())
+	})
+
+	DescribeTable("circles",
+		func(point geofence.Point, expected bool) {
+			fence := geofence.Fence{Center: &home, RadiusMeters: 500}
+
+			Expect(fence.Contains(point)).To(Equal(expected))
+		},
+		Entry("the center", home, true),
+		Entry("around the corner", geofence.Point{Latitude: 37.3790, Longitude: -77.5030}, true),
+		Entry("a mile away", geofence.Point{Latitude: 37.3916, Longitude: -77.5050}, false),
+		Entry("no coordinates", geofence.Point{}, false),
+	)
+
+	DescribeTable("polygons",
+		func(point geofence.Point, expected bool) {
+			fence := geofence.Fence{Polygon: square}
+
+			Expect(fence.Contains(point)).To(Equal(expected))
+		},
+		Entry("inside", geofence.Point{Latitude: 37.375, Longitude: -77.525}, true),
+		Entry("outside", geofence.Point{Latitude: 37.40, Longitude: -77.525}, false),
+		Entry("in the hole", geofence.Point{Latitude: 37.380, Longitude: -77.520}, false),
+		Entry("no coordinates", geofence.Point{}, false),
+	)
+
+	It("contains nothing in a ring with a short position", func() {
+		fence := geofence.Fence{Polygon: &geofence.Polygon{Type: "Polygon", Coordinates: [][][]float64{
+			{{-77.53, 37.37}, {-77.51}, {-77.51, 37.39}, {-77.53, 37.37}},
+		}}}
+
+		Expect(fence.Contains(geofence.Point{Latitude: 37.375, Longitude: -77.525})).To(BeFalse())
+	})
+
+	DescribeTable("validation",
+		func(fence geofence.Fence, message string) {
+			err := fence.Validate()
+
+			if message == "" {
+				Expect(err).ShouldNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(message))
+			}
+		},
+		Entry("circle", geofence.Fence{Center: &home, RadiusMeters: 500}, ""),
+		Entry("polygon", geofence.Fence{Polygon: &geofence.Polygon{Type: "Polygon", Coordinates: [][][]float64{
+			{{-77.53, 37.37}, {-77.51, 37.37}, {-77.51, 37.39}, {-77.53, 37.37}},
+		}}}, ""),
+		Entry("empty", geofence.Fence{}, "a fence needs a center or a polygon"),
+		Entry("both", geofence.Fence{Center: &home, RadiusMeters: 1, Polygon: &geofence.Polygon{}}, "a fence has either a center or a polygon, not both"),
+		Entry("no radius", geofence.Fence{Center: &home}, "a fence around a point needs a positive radius"),
+		Entry("bad center", geofence.Fence{Center: &geofence.Point{Latitude: 137}, RadiusMeters: 1}, "invalid coordinates: 137, 0"),
+		Entry("other geometry", geofence.Fence{Polygon: &geofence.Polygon{Type: "Point"}}, "unsupported geometry type: Point"),
+		Entry("open ring", geofence.Fence{Polygon: &geofence.Polygon{Type: "Polygon", Coordinates: [][][]float64{
+			{{-77.53, 37.37}, {-77.51, 37.37}, {-77.51, 37.39}, {-77.53, 37.39}},
+		}}}, "polygon rings must end where they start"),
+		Entry("short ring", geofence.Fence{Polygon: &geofence.Polygon{Type: "Polygon", Coordinates: [][][]float64{
+			{{-77.53, 37.37}, {-77.51, 37.37}, {-77.53, 37.37}},
+		}}}, "polygon rings need at least four positions"),
+	)
+})
@@ -0,0 +1,192 @@
+package graph_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/graph"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+)
+
+type response struct {
+	Data   map[string]any   `json:"data"`
+	Errors []map[string]any `json:"errors"`
+}
+
+var _ = Describe("GraphQL API", func() {
+	var ctx context.Context
+	var handler *graph.Handler
+	var received time.Time
+
+	post := func(query string, variables map[string]any) response {
+		body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		result := response{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		clock := func() time.Time { return time.Date(2030, 1, 1, 6, 30, 0, 0, time.UTC) }
+		calls := saved_calls.NewInMemory(clock)
+		incidents := saved_incidents.NewInMemory(clock)
+
+		received = time.Date(2022, 3, 23, 23, 22, 39, 0, chesterfield.LocalTime)
+		for i, call := range []saved_calls.SavedCall{
+			{ID: "0123", CallType: "police", Area: "11", Priority: "3", StreetName: "FAKE RD"},
+			{ID: "0124", CallType: "fire", Area: "11", Priority: "1", StreetName: "FAKE RD"},
+			{ID: "0125", CallType: "police", Area: "60", Priority: "3", StreetName: "EXAMPLE CT"},
+		} {
+			call.LastKnownStatus = "dispatched"
+			call.CallReceived = received.AddDate(0, 0, i)
+			Expect(calls.SaveCall(ctx, call)).To(Succeed())
+		}
+		resolved := saved_calls.SavedCall{ID: "0125", CallType: "police", StreetName: "EXAMPLE CT", CallReceived: received.AddDate(0, 0, 2), LastKnownStatus: "resolved"}
+		Expect(calls.UpdateStatus(ctx, resolved)).To(Succeed())
+
+		Expect(incidents.SaveIncident(ctx, saved_incidents.SavedIncident{
+			ID:           "abc",
+			Jurisdiction: "Chesterfield",
+			Incident:     "Crash",
+			Latitude:     37.4,
+			Longitude:    -77.5,
+		})).To(Succeed())
+
+		schema, err := graph.NewSchema(calls, incidents)
+		Expect(err).ShouldNot(HaveOccurred())
+		handler = graph.NewHandler(schema)
+	})
+
+	It("lists active calls", func() {
+		result := post(`{ activeCalls { id active callReceived callArrival } }`, nil)
+
+		Expect(result.Errors).To(BeEmpty())
+		activeCalls := result.Data["activeCalls"].([]any)
+		Expect(activeCalls).To(HaveLen(2))
+		first := activeCalls[0].(map[string]any)
+		Expect(first["id"]).To(Equal("0123"))
+		Expect(first["active"]).To(BeTrue())
+		Expect(first["callReceived"]).To(Equal(received.UTC().Format(time.RFC3339)))
+		Expect(first["callArrival"]).To(BeNil())
+	})
+
+	It("pages through history by street and date", func() {
+		query := `query ($cursor: String) {
+			calls(street: "FAKE RD", from: "2022-03-23", to: "2022-03-24", limit: 1, cursor: $cursor) {
+				calls { id }
+				cursor
+			}
+		}`
+
+		first := post(query, nil)
+		Expect(first.Errors).To(BeEmpty())
+		page := first.Data["calls"].(map[string]any)
+		Expect(page["calls"]).To(Equal([]any{map[string]any{"id": "0124"}}))
+
+		second := post(query, map[string]any{"cursor": page["cursor"]})
+		Expect(second.Errors).To(BeEmpty())
+		Expect(second.Data["calls"].(map[string]any)["calls"]).To(Equal([]any{map[string]any{"id": "0123"}}))
+	})
+
+	It("gets a single call", func() {
+		result := post(`{ call(id: "0125") { streetName active callResolved } missing: call(id: "9999") { id } }`, nil)
+
+		Expect(result.Errors).To(BeEmpty())
+		call := result.Data["call"].(map[string]any)
+		Expect(call["streetName"]).To(Equal("EXAMPLE CT"))
+		Expect(call["active"]).To(BeFalse())
+		Expect(call["callResolved"]).ToNot(BeNil())
+		Expect(result.Data["missing"]).To(BeNil())
+	})
+
+	DescribeTable("counts calls",
+		func(grouping string, expected []any) {
+			result := post(`query ($groupBy: CallGrouping!) { callCounts(groupBy: $groupBy, from: "2022-03-01", to: "2022-03-31") { key count } }`, map[string]any{"groupBy": grouping})
+
+			Expect(result.Errors).To(BeEmpty())
+			Expect(result.Data["callCounts"]).To(Equal(expected))
+		},
+		Entry("by area", "AREA", []any{
+			map[string]any{"key": "11", "count": float64(2)},
+			map[string]any{"key": "60", "count": float64(1)},
+		}),
+		Entry("by type", "TYPE", []any{
+			map[string]any{"key": "police", "count": float64(2)},
+			map[string]any{"key": "fire", "count": float64(1)},
+		}),
+		Entry("by priority", "PRIORITY", []any{
+			map[string]any{"key": "3", "count": float64(2)},
+			map[string]any{"key": "1", "count": float64(1)},
+		}),
+	)
+
+	It("counts a street's calls without a range", func() {
+		result := post(`{ callCounts(groupBy: TYPE, street: "FAKE RD") { key count } }`, nil)
+
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Data["callCounts"]).To(HaveLen(2))
+	})
+
+	It("counts a month across the end of daylight saving time", func() {
+		result := post(`{ callCounts(groupBy: TYPE, from: "2022-10-15", to: "2022-11-15") { key count } }`, nil)
+
+		Expect(result.Errors).To(BeEmpty())
+	})
+
+	DescribeTable("refuses counts that would scan too much",
+		func(query string) {
+			result := post(query, nil)
+
+			Expect(result.Errors).To(HaveLen(1))
+			Expect(result.Errors[0]["message"]).To(Equal(graph.ErrUnboundedCount.Error()))
+		},
+		Entry("no range", `{ callCounts(groupBy: TYPE) { key count } }`),
+		Entry("open range", `{ callCounts(groupBy: TYPE, from: "2022-03-01") { key count } }`),
+		Entry("long range", `{ callCounts(groupBy: TYPE, from: "2022-01-01", to: "2022-03-31") { key count } }`),
+	)
+
+	It("lists active traffic incidents", func() {
+		result := post(`{ activeIncidents { id jurisdiction incident latitude longitude resolved } }`, nil)
+
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Data["activeIncidents"]).To(Equal([]any{map[string]any{
+			"id":           "abc",
+			"jurisdiction": "Chesterfield",
+			"incident":     "Crash",
+			"latitude":     37.4,
+			"longitude":    -77.5,
+			"resolved":     nil,
+		}}))
+	})
+
+	It("reports bad dates as errors", func() {
+		result := post(`{ calls(from: "03/23/2022") { cursor } }`, nil)
+
+		Expect(result.Errors).To(HaveLen(1))
+	})
+
+	It("accepts queries over GET", func() {
+		recorder := httptest.NewRecorder()
+		target := "/graphql?query=" + url.QueryEscape(`{ activeIncidents { id } }`)
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"id":"abc"`))
+	})
+})
@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+)
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Handler struct {
+	schema graphql.Schema
+}
+
+func NewHandler(schema graphql.Schema) *Handler {
+	return &Handler{schema: schema}
+}
+
+// ServeHTTP takes the query as a JSON body on POST, or as query parameters
+// on GET so the endpoint can be tried from a browser
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body request
+	switch r.Method {
+	case http.MethodGet:
+		body.Query = r.URL.Query().Get("query")
+		body.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &body.Variables)
+			if err != nil {
+				http.Error(w, "variables must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, "body must be a JSON object", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         handler.schema,
+		RequestString:  body.Query,
+		OperationName:  body.OperationName,
+		VariableValues: body.Variables,
+		Context:        r.Context(),
+	})
+	for _, err := range result.Errors {
+		log.Printf("GraphQL error: %v\n", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(result)
+	if err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
@@ -0,0 +1,364 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+)
+
+const (
+	dateFormat = "2006-01-02"
+	// callCounts reads every page of its query on a public url, these keep it
+	// inside the table's read capacity and the function timeout
+	maxCountPages = 10
+	maxScanDays   = 31
+	countPageSize = 500
+)
+
+var (
+	ErrUnboundedCount = fmt.Errorf("callCounts needs a street, or from and to at most %d days apart", maxScanDays)
+	ErrTooManyCalls   = fmt.Errorf("callCounts matched more than %d pages of calls, narrow the query", maxCountPages)
+)
+
+type IncidentReader interface {
+	GetActiveIncidents(ctx context.Context) ([]saved_incidents.SavedIncident, error)
+}
+
+type CallCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// optionalTime resolves a time field to null when it was never set, e.g.
+// the arrival of a call nobody has arrived at yet
+func optionalTime(value func(source any) time.Time) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		t := value(p.Source)
+		if t.IsZero() {
+			return nil, nil
+		}
+		return t, nil
+	}
+}
+
+func callTime(value func(call saved_calls.SavedCall) time.Time) graphql.FieldResolveFn {
+	return optionalTime(func(source any) time.Time {
+		return value(source.(saved_calls.SavedCall))
+	})
+}
+
+// calls that couldn't be geocoded have no coordinates rather than 0, 0
+func callCoordinate(value func(call saved_calls.SavedCall) float64) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		call := p.Source.(saved_calls.SavedCall)
+		if call.Latitude == 0 && call.Longitude == 0 {
+			return nil, nil
+		}
+		return value(call), nil
+	}
+}
+
+func incidentField(value func(incident saved_incidents.SavedIncident) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return value(p.Source.(saved_incidents.SavedIncident)), nil
+	}
+}
+
+var callType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Call",
+	Fields: graphql.Fields{
+		"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"callType":        &graphql.Field{Type: graphql.String},
+		"callReason":      &graphql.Field{Type: graphql.String},
+		"lastKnownStatus": &graphql.Field{Type: graphql.String},
+		"location":        &graphql.Field{Type: graphql.String},
+		"area":            &graphql.Field{Type: graphql.String},
+		"priority":        &graphql.Field{Type: graphql.String},
+		"houseNumber":     &graphql.Field{Type: graphql.String},
+		"streetName":      &graphql.Field{Type: graphql.String},
+		"latitude": &graphql.Field{
+			Type:    graphql.Float,
+			Resolve: callCoordinate(func(call saved_calls.SavedCall) float64 { return call.Latitude }),
+		},
+		"longitude": &graphql.Field{
+			Type:    graphql.Float,
+			Resolve: callCoordinate(func(call saved_calls.SavedCall) float64 { return call.Longitude }),
+		},
+		"active": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(saved_calls.SavedCall).IsActive != "", nil
+			},
+		},
+		"callReceived": &graphql.Field{
+			Type:    graphql.DateTime,
+			Resolve: callTime(func(call saved_calls.SavedCall) time.Time { return call.CallReceived }),
+		},
+		"callArrival": &graphql.Field{
+			Type:    graphql.DateTime,
+			Resolve: callTime(func(call saved_calls.SavedCall) time.Time { return call.CallArrival }),
+		},
+		"callResolved": &graphql.Field{
+			Type:    graphql.DateTime,
+			Resolve: callTime(func(call saved_calls.SavedCall) time.Time { return call.CallResolved }),
+		},
+	},
+})
+
+var incidentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Incident",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.String),
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.ID }),
+		},
+		"jurisdiction": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.Jurisdiction }),
+		},
+		"incident": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.Incident }),
+		},
+		"incidentType": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.IncidentType }),
+		},
+		"location": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.Location }),
+		},
+		"direction": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.Direction }),
+		},
+		"lastKnownStatus": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.LastKnownStatus }),
+		},
+		"latitude": &graphql.Field{
+			Type:    graphql.Float,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.Latitude }),
+		},
+		"longitude": &graphql.Field{
+			Type:    graphql.Float,
+			Resolve: incidentField(func(incident saved_incidents.SavedIncident) any { return incident.Longitude }),
+		},
+		"firstSeen": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: optionalTime(func(source any) time.Time {
+				return source.(saved_incidents.SavedIncident).FirstSeen
+			}),
+		},
+		"resolved": &graphql.Field{
+			Type: graphql.DateTime,
+			Resolve: optionalTime(func(source any) time.Time {
+				return source.(saved_incidents.SavedIncident).Resolved
+			}),
+		},
+	},
+})
+
+var callPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CallPage",
+	Fields: graphql.Fields{
+		"calls":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(callType)))},
+		"cursor": &graphql.Field{Type: graphql.String},
+	},
+})
+
+var callCountType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CallCount",
+	Fields: graphql.Fields{
+		"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var callGroupingType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "CallGrouping",
+	Values: graphql.EnumValueConfigMap{
+		"AREA":     &graphql.EnumValueConfig{Value: "area"},
+		"TYPE":     &graphql.EnumValueConfig{Value: "type"},
+		"PRIORITY": &graphql.EnumValueConfig{Value: "priority"},
+	},
+})
+
+// the filters shared by history and aggregate queries
+var callFilterArgs = graphql.FieldConfigArgument{
+	"street": &graphql.ArgumentConfig{Type: graphql.String},
+	"from":   &graphql.ArgumentConfig{Type: graphql.String, Description: "first day, e.g. 2022-03-23"},
+	"to":     &graphql.ArgumentConfig{Type: graphql.String, Description: "last day, e.g. 2022-03-23"},
+	"type":   &graphql.ArgumentConfig{Type: graphql.String},
+}
+
+func withArgs(args graphql.FieldConfigArgument, extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	merged := graphql.FieldConfigArgument{}
+	for name, arg := range args {
+		merged[name] = arg
+	}
+	for name, arg := range extra {
+		merged[name] = arg
+	}
+	return merged
+}
+
+func parseDate(args map[string]any, name string) (time.Time, error) {
+	value, _ := args[name].(string)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(dateFormat, value, chesterfield.LocalTime)
+}
+
+func callQuery(args map[string]any) (saved_calls.CallQuery, error) {
+	query := saved_calls.CallQuery{}
+	streetName, _ := args["street"].(string)
+	query.StreetName = address.NormalizeStreet(streetName)
+	query.CallType, _ = args["type"].(string)
+	query.Cursor, _ = args["cursor"].(string)
+	if limit, ok := args["limit"].(int); ok {
+		query.Limit = int32(limit)
+	}
+
+	var err error
+	query.From, err = parseDate(args, "from")
+	if err != nil {
+		return query, err
+	}
+	query.To, err = parseDate(args, "to")
+	return query, err
+}
+
+func groupKey(call saved_calls.SavedCall, grouping string) string {
+	switch grouping {
+	case "area":
+		return call.Area
+	case "priority":
+		return call.Priority
+	default:
+		return call.CallType
+	}
+}
+
+// without a street the count scans the table, so it needs a short range
+func checkCountQuery(query saved_calls.CallQuery) error {
+	if query.StreetName != "" {
+		return nil
+	}
+	// calendar days, a range across a daylight saving change isn't a
+	// multiple of 24 hours
+	if query.From.IsZero() || query.To.IsZero() || query.From.AddDate(0, 0, maxScanDays).Before(query.To) {
+		return ErrUnboundedCount
+	}
+	return nil
+}
+
+// countCalls reads every page of the query, up to maxCountPages
+func countCalls(ctx context.Context, reader saved_calls.Reader, query saved_calls.CallQuery, grouping string) ([]CallCount, error) {
+	if err := checkCountQuery(query); err != nil {
+		return nil, err
+	}
+	query.Limit = countPageSize
+
+	counts := map[string]int{}
+	for pages := 1; ; pages++ {
+		page, err := reader.FindCalls(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, call := range page.Calls {
+			counts[groupKey(call, grouping)]++
+		}
+		if page.Cursor == "" {
+			break
+		}
+		if pages >= maxCountPages {
+			return nil, ErrTooManyCalls
+		}
+		query.Cursor = page.Cursor
+	}
+
+	result := make([]CallCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, CallCount{Key: key, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
+
+func NewSchema(calls saved_calls.Reader, incidents IncidentReader) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"activeCalls": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(callType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return calls.GetActiveCalls(p.Context)
+				},
+			},
+			"call": &graphql.Field{
+				Type: callType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					call, err := calls.GetCall(p.Context, p.Args["id"].(string))
+					if errors.Is(err, saved_calls.ErrNotFound) {
+						return nil, nil
+					}
+					return call, err
+				},
+			},
+			"calls": &graphql.Field{
+				Type: graphql.NewNonNull(callPageType),
+				Args: withArgs(callFilterArgs, graphql.FieldConfigArgument{
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+					"cursor": &graphql.ArgumentConfig{Type: graphql.String},
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					query, err := callQuery(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return calls.FindCalls(p.Context, query)
+				},
+			},
+			"callCounts": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(callCountType))),
+				Args: withArgs(callFilterArgs, graphql.FieldConfigArgument{
+					"groupBy": &graphql.ArgumentConfig{Type: graphql.NewNonNull(callGroupingType)},
+				}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					query, err := callQuery(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return countCalls(p.Context, calls, query, p.Args["groupBy"].(string))
+				},
+			},
+			"activeIncidents": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(incidentType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return incidents.GetActiveIncidents(p.Context)
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
@@ -0,0 +1,50 @@
+package harvester_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Cancellation", func() {
+	var canceled context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		canceled, cancel = context.WithCancel(ctx)
+		DeferCleanup(cancel)
+	})
+
+	It("stops waiting on a fetch that doesn't return", func() {
+		release := make(chan time.Time)
+		DeferCleanup(func() { close(release) })
+		chesterfieldMock.On("GetFireCalls", canceled).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", canceled).WaitUntil(release).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", canceled).Return([]saved_calls.SavedCall{}, nil)
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		_, err := subject.Harvest(canceled)
+
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(len(daoMock.Calls)).To(Equal(1))
+	})
+
+	It("stops updating calls once canceled", func() {
+		resolvedCall := savedCall
+		resolvedCall.ID = "0999"
+		chesterfieldMock.On("GetFireCalls", canceled).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", canceled).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", canceled).Return([]saved_calls.SavedCall{resolvedCall}, nil)
+		daoMock.On("SaveCall", canceled, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(nil)
+
+		_, err := subject.Harvest(canceled)
+
+		Expect(err).To(MatchError(context.Canceled))
+		daoMock.AssertNotCalled(GinkgoT(), "UpdateStatus", mock.Anything, mock.Anything)
+	})
+})
@@ -0,0 +1,91 @@
+package harvester_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// staleStore hands out the active calls as they were before another harvest
+// wrote to the store, like a harvest that read them at the same time
+type staleStore struct {
+	*saved_calls.InMemoryDataAccess
+	activeCalls []saved_calls.SavedCall
+}
+
+func (store *staleStore) GetActiveCalls(ctx context.Context) ([]saved_calls.SavedCall, error) {
+	return store.activeCalls, nil
+}
+
+var _ = Describe("Overlapping Harvests", func() {
+	var store *saved_calls.InMemoryDataAccess
+	var stale *staleStore
+
+	BeforeEach(func() {
+		store = saved_calls.NewInMemory(func() time.Time { return time.Date(2030, 1, 1, 1, 30, 0, 0, localLocation) })
+		Expect(store.SaveCall(ctx, savedCall)).To(Succeed())
+
+		// both harvests read the saved calls before either writes
+		activeCalls, err := store.GetActiveCalls(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		stale = &staleStore{InMemoryDataAccess: store, activeCalls: activeCalls}
+	})
+
+	harvest := func(dao saved_calls.Client) harvester.HarvestReport {
+		report, err := harvester.NewWithClients(chesterfieldMock, dao, incidentDaoMock, nil).Harvest(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		return report
+	}
+
+	It("writes each change once", func() {
+		policeCall[0].CurrentStatus = "On Scene"
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(fireCall, nil)
+
+		first := harvest(store)
+		second := harvest(stale)
+
+		Expect(first.Police.Updated).To(Equal(1))
+		Expect(first.Fire.New).To(Equal(1))
+		Expect(second.Police.Updated).To(Equal(0))
+		Expect(second.Fire.New).To(Equal(0))
+		Expect(second.Police.Conflicts).To(Equal(1))
+		Expect(second.Fire.Conflicts).To(Equal(1))
+		Expect(second.Err()).ShouldNot(HaveOccurred())
+
+		calls, err := store.GetActiveCalls(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(calls).To(HaveLen(2))
+		for _, call := range calls {
+			if call.ID == "0123" {
+				Expect(call.LastKnownStatus).To(Equal("on scene"))
+				Expect(call.Version).To(Equal(2))
+			} else {
+				Expect(call.Version).To(Equal(1))
+			}
+		}
+	})
+
+	It("doesn't resolve a call the other harvest moved on", func() {
+		chesterfieldMock.On("GetFireCalls", ctx).Return(fireCall, nil)
+		policeCall[0].CurrentStatus = "On Scene"
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil).Once()
+		harvest(store)
+
+		// the stale harvest saw the call missing for long enough to resolve it
+		stale.activeCalls[0].MissedHarvests = harvester.DefaultGuard.GraceHarvests
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall[:0], nil).Once()
+		second := harvest(stale)
+
+		Expect(second.Police.Resolved).To(Equal(0))
+		Expect(second.Police.Conflicts).To(Equal(1))
+
+		calls, err := store.GetActiveCalls(ctx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(calls).To(HaveLen(2))
+	})
+})
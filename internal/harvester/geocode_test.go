@@ -0,0 +1,80 @@
+package harvester_test
+
+import (
+	"errors"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geocode"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Geocoding", func() {
+	var geocoderMock *GeocoderMock
+
+	BeforeEach(func() {
+		geocoderMock = &GeocoderMock{}
+		subject = harvester.NewWithClients(chesterfieldMock, daoMock, incidentDaoMock, geocoderMock)
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+	})
+
+	It("stores coordinates on new calls", func() {
+		geocoderMock.On("Geocode", ctx, "22XX", "FAKE RD").Return(geocode.Coordinates{Latitude: 37.415, Longitude: -77.6}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
+			Expect(activeCall.Latitude).To(Equal(37.415))
+			Expect(activeCall.Longitude).To(Equal(-77.6))
+			return true
+		})).Return(nil)
+
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(daoMock.Calls)).To(Equal(2))
+	})
+
+	It("places intersections where the streets meet", func() {
+		policeCall[0].Location = "HULL ST RD AT COALFIELD RD"
+		geocoderMock.On("GeocodeIntersection", ctx, "HULL ST RD", "COALFIELD RD").Return(geocode.Coordinates{Latitude: 37.4, Longitude: -77.65}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
+			Expect(activeCall.Latitude).To(Equal(37.4))
+			Expect(activeCall.Longitude).To(Equal(-77.65))
+			return true
+		})).Return(nil)
+
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		geocoderMock.AssertNotCalled(GinkgoT(), "Geocode", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	It("saves calls it can't place", func() {
+		geocoderMock.On("Geocode", ctx, "22XX", "FAKE RD").Return(geocode.Coordinates{}, errors.New("lookup failed"))
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
+			Expect(activeCall.Latitude).To(BeZero())
+			Expect(activeCall.Longitude).To(BeZero())
+			return true
+		})).Return(nil)
+
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(daoMock.Calls)).To(Equal(2))
+	})
+
+	It("doesn't look up calls it already has", func() {
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
+
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(geocoderMock.Calls).To(BeEmpty())
+	})
+})
@@ -0,0 +1,55 @@
+package harvester
+
+import (
+	"log"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/metrics"
+)
+
+// Guard holds back resolutions that look like the county api lost its calls
+// rather than the calls actually ending, e.g. an empty list returned with a
+// 200. The zero value resolves a missing call right away, the way the
+// harvester always has.
+type Guard struct {
+	// harvests in a row a call has to be missing from before it's resolved,
+	// 0 and 1 both resolve it the first time it's missing
+	GraceHarvests int
+	// the largest share of an agency's saved calls that may resolve in one
+	// harvest, 0 turns the limit off
+	MaxResolvedShare float64
+	// agencies with fewer saved calls than this may resolve any share, two
+	// of three calls ending overnight is normal. They are still held back
+	// when the county returned no calls at all.
+	MinCalls int
+}
+
+var DefaultGuard = Guard{
+	GraceHarvests:    2,
+	MaxResolvedShare: 0.5,
+	MinCalls:         10,
+}
+
+func (guard Guard) graceElapsed(missedHarvests int) bool {
+	return missedHarvests >= guard.GraceHarvests
+}
+
+func (guard Guard) trips(resolving int, savedCalls int, fetched int) bool {
+	if guard.MaxResolvedShare <= 0 || resolving == 0 {
+		return false
+	}
+	// an empty response looks the same however few calls there were
+	if savedCalls < guard.MinCalls {
+		return fetched == 0
+	}
+	return float64(resolving)/float64(savedCalls) > guard.MaxResolvedShare
+}
+
+func (harvester *Harvester) recordTrip(callType string, held int, savedCalls int) {
+	log.Printf("Resolution guard tripped, holding %d of %d %s calls\n", held, savedCalls, callType)
+	if harvester.metrics == nil {
+		return
+	}
+	dimensions := map[string]string{"Agency": callType}
+	harvester.metrics.Record(metrics.Metric{Name: "ResolutionGuardTripped", Value: 1, Unit: "Count", Dimensions: dimensions})
+	harvester.metrics.Record(metrics.Metric{Name: "ResolutionsHeld", Value: float64(held), Unit: "Count", Dimensions: dimensions})
+}
@@ -0,0 +1,164 @@
+package harvester_test
+
+import (
+	"fmt"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/metrics"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Resolution Guard", func() {
+	var metricsMock *MetricsMock
+
+	savedCalls := func(count int) []saved_calls.SavedCall {
+		calls := []saved_calls.SavedCall{}
+		for i := 0; i < count; i++ {
+			call := savedCall
+			call.ID = fmt.Sprintf("09%02d", i)
+			calls = append(calls, call)
+		}
+		return calls
+	}
+
+	BeforeEach(func() {
+		metricsMock = &MetricsMock{}
+		subject.WithGuard(harvester.DefaultGuard, metricsMock)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+	})
+
+	It("waits out the grace period before resolving", func() {
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
+		daoMock.On("UpdateMissedHarvests", ctx, mock.MatchedBy(func(call saved_calls.SavedCall) bool {
+			return call.ID == "0123" && call.MissedHarvests == 1
+		})).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Missing).To(Equal(1))
+		Expect(report.Police.Resolved).To(Equal(0))
+		daoMock.AssertNotCalled(GinkgoT(), "UpdateStatus", mock.Anything, mock.Anything)
+	})
+
+	It("resolves a call once it has been missing long enough", func() {
+		savedCall.MissedHarvests = 1
+		activeCall := policeCall[0]
+		activeCall.ID = "0456"
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{activeCall}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
+		daoMock.On("SaveCall", ctx, mock.Anything).Return(nil)
+		daoMock.On("UpdateStatus", ctx, mock.MatchedBy(func(call saved_calls.SavedCall) bool {
+			return call.ID == "0123" && call.LastKnownStatus == "resolved"
+		})).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Resolved).To(Equal(1))
+		daoMock.AssertNotCalled(GinkgoT(), "UpdateMissedHarvests", mock.Anything, mock.Anything)
+	})
+
+	It("forgets missed harvests when a call comes back", func() {
+		savedCall.MissedHarvests = 1
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
+		daoMock.On("UpdateMissedHarvests", ctx, mock.MatchedBy(func(call saved_calls.SavedCall) bool {
+			return call.ID == "0123" && call.MissedHarvests == 0
+		})).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Missing).To(Equal(0))
+		daoMock.AssertNumberOfCalls(GinkgoT(), "UpdateMissedHarvests", 1)
+	})
+
+	It("holds back resolving most of an agency's calls at once", func() {
+		calls := savedCalls(10)
+		for i := range calls {
+			calls[i].MissedHarvests = 1
+		}
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return(calls, nil)
+		daoMock.On("UpdateMissedHarvests", ctx, mock.MatchedBy(func(call saved_calls.SavedCall) bool {
+			return call.MissedHarvests == 2
+		})).Return(nil)
+		metricsMock.On("Record", metrics.Metric{
+			Name: "ResolutionGuardTripped", Value: 1, Unit: "Count", Dimensions: map[string]string{"Agency": "police"},
+		}).Return()
+		metricsMock.On("Record", metrics.Metric{
+			Name: "ResolutionsHeld", Value: 10, Unit: "Count", Dimensions: map[string]string{"Agency": "police"},
+		}).Return()
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Held).To(Equal(10))
+		Expect(report.Police.Missing).To(Equal(10))
+		Expect(report.Police.Resolved).To(Equal(0))
+		daoMock.AssertNotCalled(GinkgoT(), "UpdateStatus", mock.Anything, mock.Anything)
+		metricsMock.AssertExpectations(GinkgoT())
+	})
+
+	It("lets a small share of calls resolve", func() {
+		calls := savedCalls(10)
+		calls[0].MissedHarvests = 1
+		active := chesterfield.CallForService{}
+		for _, call := range calls[1:] {
+			activeCall := policeCall[0]
+			activeCall.ID = call.ID
+			active = append(active, activeCall)
+		}
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(active, nil)
+		daoMock.On("GetActiveCalls", ctx).Return(calls, nil)
+		daoMock.On("UpdateStatus", ctx, mock.Anything).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Resolved).To(Equal(1))
+		Expect(report.Police.Held).To(Equal(0))
+		metricsMock.AssertNotCalled(GinkgoT(), "Record", mock.Anything)
+	})
+
+	It("lets most of a small agency's calls resolve", func() {
+		calls := savedCalls(3)
+		calls[0].MissedHarvests = 1
+		calls[1].MissedHarvests = 1
+		activeCall := policeCall[0]
+		activeCall.ID = calls[2].ID
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{activeCall}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return(calls, nil)
+		daoMock.On("UpdateStatus", ctx, mock.Anything).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Resolved).To(Equal(2))
+		Expect(report.Police.Held).To(Equal(0))
+	})
+
+	It("holds back a small agency's calls when the county returns none", func() {
+		calls := savedCalls(3)
+		for i := range calls {
+			calls[i].MissedHarvests = 1
+		}
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		daoMock.On("GetActiveCalls", ctx).Return(calls, nil)
+		daoMock.On("UpdateMissedHarvests", ctx, mock.Anything).Return(nil)
+		metricsMock.On("Record", mock.Anything).Return()
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Held).To(Equal(3))
+		Expect(report.Police.Resolved).To(Equal(0))
+		daoMock.AssertNotCalled(GinkgoT(), "UpdateStatus", mock.Anything, mock.Anything)
+	})
+})
@@ -2,33 +2,79 @@ package harvester
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"regexp"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geocode"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/metrics"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
 )
 
 type Harvester struct {
-	apiClient chesterfield.Client
-	dao       saved_calls.Client
+	apiClient   chesterfield.Client
+	dao         saved_calls.Client
+	incidentDao saved_incidents.Client
+	geocoder    geocode.Provider
+	guard       Guard
+	metrics     metrics.Recorder
 }
 
 func New(policeApiKey string, fireApiKey string, cfg aws.Config) *Harvester {
 	return &Harvester{
-		apiClient: chesterfield.New(policeApiKey, fireApiKey),
-		dao:       saved_calls.New(cfg),
+		apiClient:   chesterfield.New(policeApiKey, fireApiKey),
+		dao:         saved_calls.New(cfg),
+		incidentDao: saved_incidents.New(cfg),
+		guard:       DefaultGuard,
 	}
 }
 
-func NewWithClients(apiClient chesterfield.Client, dao saved_calls.Client) *Harvester {
+// geocoder may be nil, calls are then saved without coordinates
+func NewWithClients(apiClient chesterfield.Client, dao saved_calls.Client, incidentDao saved_incidents.Client, geocoder geocode.Provider) *Harvester {
 	return &Harvester{
-		apiClient: apiClient,
-		dao:       dao,
+		apiClient:   apiClient,
+		dao:         dao,
+		incidentDao: incidentDao,
+		geocoder:    geocoder,
 	}
 }
 
+// WithGuard sets how missing calls are resolved, recorder may be nil
+func (harvester *Harvester) WithGuard(guard Guard, recorder metrics.Recorder) *Harvester {
+	harvester.guard = guard
+	harvester.metrics = recorder
+	return harvester
+}
+
+// a call that can't be placed is still worth saving, so lookup failures
+// are only logged
+func (harvester *Harvester) geocodeCall(ctx context.Context, savedCall *saved_calls.SavedCall) {
+	if harvester.geocoder == nil {
+		return
+	}
+
+	var coordinates geocode.Coordinates
+	var err error
+	if savedCall.HouseNumber == "" && len(savedCall.CrossStreets) > 0 {
+		coordinates, err = harvester.geocoder.GeocodeIntersection(ctx, savedCall.StreetName, savedCall.CrossStreets[0])
+	} else {
+		coordinates, err = harvester.geocoder.Geocode(ctx, savedCall.HouseNumber, savedCall.StreetName)
+	}
+	if err != nil {
+		if !errors.Is(err, geocode.ErrNotFound) {
+			log.Printf("Error geocoding %s: %v\n", savedCall.Location, err)
+		}
+		return
+	}
+	savedCall.Latitude = coordinates.Latitude
+	savedCall.Longitude = coordinates.Longitude
+}
+
 type CallResult struct {
 	Calls chesterfield.CallForService
 	Err   error
@@ -39,19 +85,74 @@ type SavedCallResult struct {
 	Err   error
 }
 
-var streetNameRegex = regexp.MustCompile(`(?:(\d+XX) )?(.*)`)
+// writeBatch writes calls with one of the dao's batch operations and
+// returns how many were written, the calls that weren't are counted as failed
+// unless another harvest got to them first
+func (harvester *Harvester) writeBatch(ctx context.Context, action string, callType string, calls []saved_calls.SavedCall, write func(context.Context, []saved_calls.SavedCall) error, report *AgencyReport) int {
+	if len(calls) == 0 {
+		return 0
+	}
+	// stop between batches rather than failing every remaining write
+	if err := ctx.Err(); err != nil {
+		report.Errors = append(report.Errors, err)
+		return 0
+	}
+
+	err := write(ctx, calls)
+	if err == nil {
+		return len(calls)
+	}
+
+	var batchError *saved_calls.BatchError
+	if !errors.As(err, &batchError) {
+		for _, call := range calls {
+			report.fail(fmt.Errorf("%s %s call %s: %w", action, callType, call.ID, err))
+		}
+		return 0
+	}
+	for _, failure := range batchError.Failures {
+		if errors.Is(failure.Err, saved_calls.ErrConflict) {
+			log.Printf("Skipped %s %s call %s, it was changed by another harvest\n", action, callType, failure.Call.ID)
+			report.Conflicts++
+			continue
+		}
+		report.fail(fmt.Errorf("%s %s call %s: %w", action, callType, failure.Call.ID, failure.Err))
+	}
+	return len(calls) - len(batchError.Failures)
+}
+
+// Locate fills in the block and streets a call is stored under from its location
+func Locate(savedCall saved_calls.SavedCall) saved_calls.SavedCall {
+	location := address.Parse(savedCall.Location)
+	savedCall.HouseNumber = location.Block
+	savedCall.StreetName = location.StreetName()
+	savedCall.CrossStreets = nil
+	if location.CrossStreet != "" {
+		savedCall.CrossStreets = []string{location.CrossStreet}
+	}
+	return savedCall
+}
 
-func (harvester *Harvester) updateCalls(ctx context.Context, callType string, activeCalls chesterfield.CallForService, savedCalls []saved_calls.SavedCall) error {
+// updateCalls saves one agency's calls. New calls, status changes and
+// resolutions are each written in batches, a call that fails to save is
+// counted and the rest still go through.
+func (harvester *Harvester) updateCalls(ctx context.Context, callType string, activeCalls chesterfield.CallForService, savedCalls []saved_calls.SavedCall, report *AgencyReport) {
 	callMap := map[string]saved_calls.SavedCall{}
 	for _, call := range savedCalls {
 		if call.CallType == callType {
 			callMap[call.ID] = call
 		}
 	}
+	savedCount := len(callMap)
 
+	newCalls := []saved_calls.SavedCall{}
+	updatedCalls := []saved_calls.SavedCall{}
 	for _, activeCall := range activeCalls {
-		match := streetNameRegex.FindStringSubmatch(activeCall.Location)
-		savedCall := saved_calls.SavedCall{
+		if err := ctx.Err(); err != nil {
+			report.Errors = append(report.Errors, err)
+			return
+		}
+		savedCall := Locate(saved_calls.SavedCall{
 			ID:              activeCall.ID,
 			CallType:        callType,
 			CallReason:      activeCall.Type,
@@ -60,45 +161,92 @@ func (harvester *Harvester) updateCalls(ctx context.Context, callType string, ac
 			Location:        activeCall.Location,
 			Area:            activeCall.Area,
 			Priority:        activeCall.Priority,
-			HouseNumber:     match[1],
-			StreetName:      match[2],
-		}
+		})
 		if existingCall, ok := callMap[savedCall.ID]; ok {
 			delete(callMap, savedCall.ID)
-			if existingCall.LastKnownStatus != savedCall.LastKnownStatus {
-				err := harvester.dao.UpdateStatus(ctx, savedCall)
+			// the street is part of the key, a call saved before its name was
+			// normalized has to be updated where it is
+			savedCall.StreetName = existingCall.StreetName
+			savedCall.HouseNumber = existingCall.HouseNumber
+			savedCall.CrossStreets = existingCall.CrossStreets
+			// the update only applies if no other harvest changed the call since
+			// it was read
+			savedCall.Version = existingCall.Version
+			savedCall.ExpectedStatus = existingCall.LastKnownStatus
+			if existingCall.MissedHarvests > 0 {
+				err := harvester.dao.UpdateMissedHarvests(ctx, savedCall)
 				if err != nil {
-					return err
+					report.fail(fmt.Errorf("updating %s call %s: %w", callType, savedCall.ID, err))
+					continue
 				}
 			}
-		} else {
-			err := harvester.dao.SaveCall(ctx, savedCall)
-			if err != nil {
-				return err
+			// statuses are stored lowercase
+			if !strings.EqualFold(existingCall.LastKnownStatus, savedCall.LastKnownStatus) {
+				updatedCalls = append(updatedCalls, savedCall)
 			}
+		} else {
+			harvester.geocodeCall(ctx, &savedCall)
+			newCalls = append(newCalls, savedCall)
+		}
+	}
+
+	report.New += harvester.writeBatch(ctx, "saving", callType, newCalls, harvester.dao.SaveCalls, report)
+	report.Updated += harvester.writeBatch(ctx, "updating", callType, updatedCalls, harvester.dao.UpdateStatuses, report)
+
+	// whatever is left wasn't in the county's list
+	missingCalls := []saved_calls.SavedCall{}
+	resolvingCalls := []saved_calls.SavedCall{}
+	for _, missingCall := range callMap {
+		missingCall.MissedHarvests++
+		if harvester.guard.graceElapsed(missingCall.MissedHarvests) {
+			resolvingCalls = append(resolvingCalls, missingCall)
+		} else {
+			missingCalls = append(missingCalls, missingCall)
 		}
 	}
 
-	for _, resolvedCall := range callMap {
-		resolvedCall.LastKnownStatus = "resolved"
-		err := harvester.dao.UpdateStatus(ctx, resolvedCall)
+	if harvester.guard.trips(len(resolvingCalls), savedCount, len(activeCalls)) {
+		harvester.recordTrip(callType, len(resolvingCalls), savedCount)
+		report.Held = len(resolvingCalls)
+		missingCalls = append(missingCalls, resolvingCalls...)
+		resolvingCalls = nil
+	}
+
+	for _, missingCall := range missingCalls {
+		if err := ctx.Err(); err != nil {
+			report.Errors = append(report.Errors, err)
+			return
+		}
+		err := harvester.dao.UpdateMissedHarvests(ctx, missingCall)
 		if err != nil {
-			return err
+			report.fail(fmt.Errorf("updating %s call %s: %w", callType, missingCall.ID, err))
+			continue
 		}
+		report.Missing++
 	}
 
-	return nil
+	for i := range resolvingCalls {
+		resolvingCalls[i].ExpectedStatus = resolvingCalls[i].LastKnownStatus
+		resolvingCalls[i].LastKnownStatus = "resolved"
+	}
+	report.Resolved += harvester.writeBatch(ctx, "resolving", callType, resolvingCalls, harvester.dao.UpdateStatuses, report)
 }
 
-func (harvester *Harvester) Harvest(ctx context.Context) error {
-	policeCallsCh := make(chan CallResult)
-	fireCallsCh := make(chan CallResult)
-	savedCallsCh := make(chan SavedCallResult)
+// Harvest saves the latest police and fire calls. Each agency is handled on
+// its own, so one agency's outage doesn't hold up the other. The error joins
+// everything that failed, the report says how much got through.
+func (harvester *Harvester) Harvest(ctx context.Context) (HarvestReport, error) {
+	report := HarvestReport{}
+
+	// buffered so a fetch that finishes after the harvest gave up doesn't
+	// block forever
+	policeCallsCh := make(chan CallResult, 1)
+	fireCallsCh := make(chan CallResult, 1)
+	savedCallsCh := make(chan SavedCallResult, 1)
 
 	go func() {
-		defer close(policeCallsCh)
 		log.Println("Retrieving Police Calls")
-		calls, err := harvester.apiClient.GetPoliceCalls()
+		calls, err := harvester.apiClient.GetPoliceCalls(ctx)
 		policeCallsCh <- CallResult{
 			Calls: calls,
 			Err:   err,
@@ -107,9 +255,8 @@ func (harvester *Harvester) Harvest(ctx context.Context) error {
 	}()
 
 	go func() {
-		defer close(fireCallsCh)
 		log.Println("Retrieving Fire Calls")
-		calls, err := harvester.apiClient.GetFireCalls()
+		calls, err := harvester.apiClient.GetFireCalls(ctx)
 		fireCallsCh <- CallResult{
 			Calls: calls,
 			Err:   err,
@@ -118,7 +265,6 @@ func (harvester *Harvester) Harvest(ctx context.Context) error {
 	}()
 
 	go func() {
-		defer close(savedCallsCh)
 		log.Println("Retrieving Saved Calls")
 		calls, err := harvester.dao.GetActiveCalls(ctx)
 		savedCallsCh <- SavedCallResult{
@@ -128,31 +274,50 @@ func (harvester *Harvester) Harvest(ctx context.Context) error {
 		log.Printf("Found %d Saved Calls, %+v\n", len(calls), err)
 	}()
 
-	policeCalls, fireCalls, savedCalls := <-policeCallsCh, <-fireCallsCh, <-savedCallsCh
-
-	if policeCalls.Err != nil {
-		return policeCalls.Err
-	}
-	if fireCalls.Err != nil {
-		return fireCalls.Err
+	var policeCalls, fireCalls CallResult
+	var savedCalls SavedCallResult
+	for received := 0; received < 3; received++ {
+		select {
+		case policeCalls = <-policeCallsCh:
+		case fireCalls = <-fireCallsCh:
+		case savedCalls = <-savedCallsCh:
+		case <-ctx.Done():
+			log.Printf("Harvest canceled, %+v\n", ctx.Err())
+			return report, ctx.Err()
+		}
 	}
+
+	// without the saved calls every fetched call would look new and nothing
+	// could be resolved
 	if savedCalls.Err != nil {
-		return savedCalls.Err
+		return report, savedCalls.Err
 	}
 
-	log.Println("Updating Police Calls")
-	err := harvester.updateCalls(ctx, "police", policeCalls.Calls, savedCalls.Calls)
-	if err != nil {
-		log.Printf("Encountered error while updating police calls, %+v\n", err)
-		return err
+	for _, agency := range []struct {
+		callType string
+		name     string
+		result   CallResult
+	}{
+		{"police", "Police", policeCalls},
+		{"fire", "Fire", fireCalls},
+	} {
+		agencyReport := report.agency(agency.callType)
+		if agency.result.Err != nil {
+			agencyReport.Errors = append(agencyReport.Errors, fmt.Errorf("fetching %s calls: %w", agency.callType, agency.result.Err))
+			log.Printf("Skipping %s Calls, %+v\n", agency.name, agency.result.Err)
+			continue
+		}
+
+		log.Printf("Updating %s Calls\n", agency.name)
+		harvester.updateCalls(ctx, agency.callType, agency.result.Calls, savedCalls.Calls, agencyReport)
+		log.Printf("Updated %s Calls: %v\n", agency.name, agencyReport)
 	}
-	log.Println("Updating Fire Calls")
-	err = harvester.updateCalls(ctx, "fire", fireCalls.Calls, savedCalls.Calls)
+
+	err := report.Err()
 	if err != nil {
-		log.Printf("Encountered error while updating fire calls, %+v\n", err)
-		return err
+		log.Printf("Completed Harvest with errors, %+v\n", err)
+	} else {
+		log.Println("Completed Harvest")
 	}
-
-	log.Println("Completed Harvest")
-	return nil
+	return report, err
 }
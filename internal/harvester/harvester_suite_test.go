@@ -13,8 +13,11 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geocode"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/metrics"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
 )
 
 type ChesterfieldMock struct {
@@ -23,6 +26,33 @@ type ChesterfieldMock struct {
 
 type DataAccessObjectMock struct {
 	mock.Mock
+	// the calls passed to each batch operation, by method
+	Batches map[string][][]saved_calls.SavedCall
+}
+
+type IncidentDataAccessMock struct {
+	mock.Mock
+}
+
+type GeocoderMock struct {
+	mock.Mock
+}
+
+type MetricsMock struct {
+	mock.Mock
+}
+
+func (recorder *MetricsMock) Record(metric metrics.Metric) {
+	recorder.Called(metric)
+}
+
+func (geocoder *GeocoderMock) Geocode(ctx context.Context, houseNumber string, streetName string) (geocode.Coordinates, error) {
+	args := geocoder.Called(ctx, houseNumber, streetName)
+	return args.Get(0).(geocode.Coordinates), args.Error(1)
+}
+func (geocoder *GeocoderMock) GeocodeIntersection(ctx context.Context, streetName string, crossStreet string) (geocode.Coordinates, error) {
+	args := geocoder.Called(ctx, streetName, crossStreet)
+	return args.Get(0).(geocode.Coordinates), args.Error(1)
 }
 
 func (dao *DataAccessObjectMock) GetActiveCalls(ctx context.Context) ([]saved_calls.SavedCall, error) {
@@ -39,17 +69,66 @@ func (dao *DataAccessObjectMock) UpdateStatus(ctx context.Context, activeCall sa
 	return args.Error(0)
 }
 
-func (apiClient *ChesterfieldMock) GetPoliceCalls() (chesterfield.CallForService, error) {
-	args := apiClient.Called()
+// the batch operations are recorded call by call under the single call
+// methods, so expectations are set the same way for both
+func (dao *DataAccessObjectMock) calledForEach(method string, batch string, ctx context.Context, activeCalls []saved_calls.SavedCall) error {
+	if dao.Batches == nil {
+		dao.Batches = map[string][][]saved_calls.SavedCall{}
+	}
+	dao.Batches[batch] = append(dao.Batches[batch], activeCalls)
+
+	batchError := &saved_calls.BatchError{}
+	for _, call := range activeCalls {
+		err := dao.MethodCalled(method, ctx, call).Error(0)
+		if err != nil {
+			batchError.Failures = append(batchError.Failures, saved_calls.WriteFailure{Call: call, Err: err})
+		}
+	}
+	if len(batchError.Failures) == 0 {
+		return nil
+	}
+	return batchError
+}
+func (dao *DataAccessObjectMock) SaveCalls(ctx context.Context, activeCalls []saved_calls.SavedCall) error {
+	return dao.calledForEach("SaveCall", "SaveCalls", ctx, activeCalls)
+}
+func (dao *DataAccessObjectMock) UpdateStatuses(ctx context.Context, activeCalls []saved_calls.SavedCall) error {
+	return dao.calledForEach("UpdateStatus", "UpdateStatuses", ctx, activeCalls)
+}
+func (dao *DataAccessObjectMock) UpdateMissedHarvests(ctx context.Context, activeCall saved_calls.SavedCall) error {
+	args := dao.Called(ctx, activeCall)
+	return args.Error(0)
+}
+
+func (dao *IncidentDataAccessMock) GetActiveIncidents(ctx context.Context) ([]saved_incidents.SavedIncident, error) {
+	args := dao.Called(ctx)
+	return args.Get(0).([]saved_incidents.SavedIncident), args.Error(1)
+}
+func (dao *IncidentDataAccessMock) SaveIncident(ctx context.Context, incident saved_incidents.SavedIncident) error {
+	args := dao.Called(ctx, incident)
+	return args.Error(0)
+}
+func (dao *IncidentDataAccessMock) UpdateStatus(ctx context.Context, incident saved_incidents.SavedIncident) error {
+	args := dao.Called(ctx, incident)
+	return args.Error(0)
+}
+
+func (apiClient *ChesterfieldMock) GetPoliceCalls(ctx context.Context) (chesterfield.CallForService, error) {
+	args := apiClient.Called(ctx)
 	return args.Get(0).(chesterfield.CallForService), args.Error(1)
 }
-func (apiClient *ChesterfieldMock) GetFireCalls() (chesterfield.CallForService, error) {
-	args := apiClient.Called()
+func (apiClient *ChesterfieldMock) GetFireCalls(ctx context.Context) (chesterfield.CallForService, error) {
+	args := apiClient.Called(ctx)
 	return args.Get(0).(chesterfield.CallForService), args.Error(1)
 }
+func (apiClient *ChesterfieldMock) GetTrafficIncidents(ctx context.Context, jurisdiction string) (chesterfield.TrafficIncident, error) {
+	args := apiClient.Called(ctx, jurisdiction)
+	return args.Get(0).(chesterfield.TrafficIncident), args.Error(1)
+}
 
 var chesterfieldMock *ChesterfieldMock
 var daoMock *DataAccessObjectMock
+var incidentDaoMock *IncidentDataAccessMock
 var subject *harvester.Harvester
 var ctx = context.TODO()
 var localLocation, _ = time.LoadLocation("America/New_York")
@@ -65,8 +144,9 @@ var _ = BeforeSuite(func() {
 var _ = BeforeEach(func() {
 	chesterfieldMock = &ChesterfieldMock{}
 	daoMock = &DataAccessObjectMock{}
+	incidentDaoMock = &IncidentDataAccessMock{}
 
-	subject = harvester.NewWithClients(chesterfieldMock, daoMock)
+	subject = harvester.NewWithClients(chesterfieldMock, daoMock, incidentDaoMock, nil)
 
 	policeCall = chesterfield.CallForService{
 		{
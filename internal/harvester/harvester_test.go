@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -13,12 +14,12 @@ import (
 
 var _ = Describe("Harvester", func() {
 	It("does nothing for no calls", func() {
-		chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-		chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
 
 		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
 
-		err := subject.Harvest(ctx)
+		_, err := subject.Harvest(ctx)
 
 		Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 		Expect(len(daoMock.Calls)).To(Equal(1))
@@ -26,8 +27,8 @@ var _ = Describe("Harvester", func() {
 	})
 
 	It("stores a police call", func() {
-		chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-		chesterfieldMock.On("GetPoliceCalls").Return(policeCall, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
 
 		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
 		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
@@ -43,7 +44,7 @@ var _ = Describe("Harvester", func() {
 			Expect(activeCall.StreetName).To(Equal("FAKE RD"))
 			return true
 		})).Return(nil)
-		err := subject.Harvest(ctx)
+		_, err := subject.Harvest(ctx)
 
 		Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 		Expect(len(daoMock.Calls)).To(Equal(2))
@@ -51,8 +52,8 @@ var _ = Describe("Harvester", func() {
 	})
 
 	It("stores a fire call", func() {
-		chesterfieldMock.On("GetFireCalls").Return(fireCall, nil)
-		chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(fireCall, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
 
 		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
 		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
@@ -68,7 +69,7 @@ var _ = Describe("Harvester", func() {
 			Expect(activeCall.StreetName).To(Equal("DIFFERENT ST"))
 			return true
 		})).Return(nil)
-		err := subject.Harvest(ctx)
+		_, err := subject.Harvest(ctx)
 
 		Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 		Expect(len(daoMock.Calls)).To(Equal(2))
@@ -78,8 +79,8 @@ var _ = Describe("Harvester", func() {
 	It("updates a call", func() {
 		policeCall[0].CurrentStatus = "On Scene"
 
-		chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-		chesterfieldMock.On("GetPoliceCalls").Return(policeCall, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
 
 		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
 
@@ -96,20 +97,91 @@ var _ = Describe("Harvester", func() {
 			Expect(activeCall.StreetName).To(Equal("FAKE RD"))
 			return true
 		})).Return(nil)
-		err := subject.Harvest(ctx)
+		_, err := subject.Harvest(ctx)
 
 		Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 		Expect(len(daoMock.Calls)).To(Equal(2))
 		Expect(err).ShouldNot(HaveOccurred())
 	})
 
+	It("normalizes locations", func() {
+		policeCall[0].Location = "22XX FAKE ROAD APT 4"
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
+			Expect(activeCall.Location).To(Equal("22XX FAKE ROAD APT 4"))
+			Expect(activeCall.HouseNumber).To(Equal("22XX"))
+			Expect(activeCall.StreetName).To(Equal("FAKE RD"))
+			return true
+		})).Return(nil)
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(daoMock.Calls)).To(Equal(2))
+	})
+
+	It("records the cross street of an intersection", func() {
+		policeCall[0].Location = "HULL STREET RD AT COALFIELD ROAD"
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
+			Expect(activeCall.StreetName).To(Equal("HULL ST RD"))
+			Expect(activeCall.CrossStreets).To(Equal([]string{"COALFIELD RD"}))
+			return true
+		})).Return(nil)
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(daoMock.Calls)).To(Equal(2))
+	})
+
+	It("locates a call saved before street names were normalized", func() {
+		located := harvester.Locate(saved_calls.SavedCall{
+			ID:         "0123",
+			Location:   "HULL STREET RD AT COALFIELD ROAD",
+			StreetName: "HULL STREET RD AT COALFIELD ROAD",
+		})
+
+		Expect(located.ID).To(Equal("0123"))
+		Expect(located.StreetName).To(Equal("HULL ST RD"))
+		Expect(located.CrossStreets).To(Equal([]string{"COALFIELD RD"}))
+	})
+
+	It("updates a call under the street it was saved with", func() {
+		policeCall[0].Location = "HULL ST RD AT COALFIELD RD"
+		policeCall[0].CurrentStatus = "On Scene"
+		existingCall := savedCall
+		existingCall.HouseNumber = ""
+		existingCall.StreetName = "HULL ST RD AT COALFIELD RD"
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{existingCall}, nil)
+		daoMock.On("UpdateStatus", ctx, mock.MatchedBy(func(activeCall saved_calls.SavedCall) bool {
+			Expect(activeCall.StreetName).To(Equal("HULL ST RD AT COALFIELD RD"))
+			Expect(activeCall.LastKnownStatus).To(Equal("On Scene"))
+			return true
+		})).Return(nil)
+		_, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(daoMock.Calls)).To(Equal(2))
+	})
+
 	It("skips updates if status did not change", func() {
-		chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-		chesterfieldMock.On("GetPoliceCalls").Return(policeCall, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
 
 		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
 
-		err := subject.Harvest(ctx)
+		_, err := subject.Harvest(ctx)
 
 		Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 		Expect(len(daoMock.Calls)).To(Equal(1))
@@ -117,8 +189,8 @@ var _ = Describe("Harvester", func() {
 	})
 
 	It("resolves a call", func() {
-		chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-		chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
 
 		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
 
@@ -135,7 +207,7 @@ var _ = Describe("Harvester", func() {
 			Expect(activeCall.StreetName).To(Equal("FAKE RD"))
 			return true
 		})).Return(nil)
-		err := subject.Harvest(ctx)
+		_, err := subject.Harvest(ctx)
 
 		Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 		Expect(len(daoMock.Calls)).To(Equal(2))
@@ -146,89 +218,95 @@ var _ = Describe("Harvester", func() {
 		unexpectedError := errors.New("error!")
 
 		It("when fetching police calls", func() {
-			chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, unexpectedError)
-			chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, unexpectedError)
+			chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
 			daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
 
-			err := subject.Harvest(ctx)
+			_, err := subject.Harvest(ctx)
 
 			Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 			Expect(len(daoMock.Calls)).To(Equal(1))
 			Expect(err).Should(HaveOccurred())
-			Expect(err.Error()).To(Equal("error!"))
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("fetching fire calls: error!"))
 		})
 
 		It("when fetching fire calls", func() {
-			chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-			chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, unexpectedError)
+			chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, unexpectedError)
 			daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
 
-			err := subject.Harvest(ctx)
+			_, err := subject.Harvest(ctx)
 
 			Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 			Expect(len(daoMock.Calls)).To(Equal(1))
 			Expect(err).Should(HaveOccurred())
-			Expect(err.Error()).To(Equal("error!"))
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("fetching police calls: error!"))
 		})
 
 		It("when fetching active calls", func() {
-			chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-			chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
 			daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, unexpectedError)
 
-			err := subject.Harvest(ctx)
+			_, err := subject.Harvest(ctx)
 
 			Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 			Expect(len(daoMock.Calls)).To(Equal(1))
 			Expect(err).Should(HaveOccurred())
+			Expect(err).To(MatchError(unexpectedError))
 			Expect(err.Error()).To(Equal("error!"))
 		})
 
 		It("when saving a call", func() {
-			chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-			chesterfieldMock.On("GetPoliceCalls").Return(policeCall, nil)
+			chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
 			daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
 
 			daoMock.On("SaveCall", ctx, mock.Anything).Return(unexpectedError)
 
-			err := subject.Harvest(ctx)
+			_, err := subject.Harvest(ctx)
 
 			Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 			Expect(len(daoMock.Calls)).To(Equal(2))
 			Expect(err).Should(HaveOccurred())
-			Expect(err.Error()).To(Equal("error!"))
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("saving police call 0123: error!"))
 		})
 
 		It("when updating status", func() {
 			policeCall[0].CurrentStatus = "On Scene"
 
-			chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-			chesterfieldMock.On("GetPoliceCalls").Return(policeCall, nil)
+			chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
 			daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
 
 			daoMock.On("UpdateStatus", ctx, mock.Anything).Return(unexpectedError)
 
-			err := subject.Harvest(ctx)
+			_, err := subject.Harvest(ctx)
 
 			Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 			Expect(len(daoMock.Calls)).To(Equal(2))
 			Expect(err).Should(HaveOccurred())
-			Expect(err.Error()).To(Equal("error!"))
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("updating police call 0123: error!"))
 		})
 
 		It("when resolving a call", func() {
-			chesterfieldMock.On("GetFireCalls").Return(chesterfield.CallForService{}, nil)
-			chesterfieldMock.On("GetPoliceCalls").Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+			chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, nil)
 			daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall}, nil)
 
 			daoMock.On("UpdateStatus", ctx, mock.Anything).Return(unexpectedError)
 
-			err := subject.Harvest(ctx)
+			_, err := subject.Harvest(ctx)
 
 			Expect(len(chesterfieldMock.Calls)).To(Equal(2))
 			Expect(len(daoMock.Calls)).To(Equal(2))
 			Expect(err).Should(HaveOccurred())
-			Expect(err.Error()).To(Equal("error!"))
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("resolving police call 0123: error!"))
 		})
 	})
 })
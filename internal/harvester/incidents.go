@@ -0,0 +1,112 @@
+package harvester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+)
+
+func parseCoordinate(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	coordinate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Unable to parse coordinate %q, %+v\n", value, err)
+		return 0
+	}
+	return coordinate
+}
+
+func (harvester *Harvester) updateIncidents(ctx context.Context, jurisdiction string, activeIncidents chesterfield.TrafficIncident, savedIncidents []saved_incidents.SavedIncident) error {
+	incidentMap := map[string]saved_incidents.SavedIncident{}
+	for _, incident := range savedIncidents {
+		if incident.Jurisdiction == jurisdiction {
+			incidentMap[incident.ID] = incident
+		}
+	}
+
+	for _, activeIncident := range activeIncidents {
+		savedIncident := saved_incidents.SavedIncident{
+			ID: saved_incidents.IncidentID(
+				jurisdiction,
+				activeIncident.Incident,
+				activeIncident.Type,
+				activeIncident.Location,
+				activeIncident.Direction,
+			),
+			Jurisdiction:    jurisdiction,
+			Incident:        activeIncident.Incident,
+			IncidentType:    activeIncident.Type,
+			Location:        activeIncident.Location,
+			Direction:       activeIncident.Direction,
+			LastKnownStatus: activeIncident.Status,
+			Latitude:        parseCoordinate(activeIncident.Lat),
+			Longitude:       parseCoordinate(activeIncident.Lon),
+		}
+		if existingIncident, ok := incidentMap[savedIncident.ID]; ok {
+			delete(incidentMap, savedIncident.ID)
+			if existingIncident.LastKnownStatus != savedIncident.LastKnownStatus {
+				err := harvester.incidentDao.UpdateStatus(ctx, savedIncident)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			err := harvester.incidentDao.SaveIncident(ctx, savedIncident)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, resolvedIncident := range incidentMap {
+		resolvedIncident.LastKnownStatus = "resolved"
+		err := harvester.incidentDao.UpdateStatus(ctx, resolvedIncident)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (harvester *Harvester) HarvestIncidents(ctx context.Context) error {
+	log.Println("Retrieving Saved Incidents")
+	savedIncidents, err := harvester.incidentDao.GetActiveIncidents(ctx)
+	log.Printf("Found %d Saved Incidents, %+v\n", len(savedIncidents), err)
+	if err != nil {
+		return err
+	}
+
+	// one jurisdiction's outage doesn't hold up the others
+	errs := []error{}
+	for _, jurisdiction := range chesterfield.Jurisdictions {
+		log.Printf("Retrieving %s Traffic Incidents\n", jurisdiction)
+		incidents, err := harvester.apiClient.GetTrafficIncidents(ctx, jurisdiction)
+		log.Printf("Found %d %s Traffic Incidents, %+v\n", len(incidents), jurisdiction, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fetching %s traffic incidents: %w", jurisdiction, err))
+			continue
+		}
+
+		err = harvester.updateIncidents(ctx, jurisdiction, incidents, savedIncidents)
+		if err != nil {
+			log.Printf("Encountered error while updating %s traffic incidents, %+v\n", jurisdiction, err)
+			errs = append(errs, fmt.Errorf("updating %s traffic incidents: %w", jurisdiction, err))
+		}
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		log.Printf("Completed Incident Harvest with errors, %+v\n", err)
+	} else {
+		log.Println("Completed Incident Harvest")
+	}
+	return err
+}
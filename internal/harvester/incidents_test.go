@@ -0,0 +1,184 @@
+package harvester_test
+
+import (
+	"errors"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Incident Harvester", func() {
+	var trafficIncident chesterfield.TrafficIncident
+	var savedIncident saved_incidents.SavedIncident
+
+	BeforeEach(func() {
+		trafficIncident = chesterfield.TrafficIncident{
+			{
+				Location:  "HULL ST RD AT COALFIELD RD",
+				Direction: "EB",
+				Status:    "Active",
+				Incident:  "Vehicle Crash",
+				Type:      "Crash",
+				Lon:       "-77.6512",
+				Lat:       "37.4521",
+			},
+		}
+		savedIncident = saved_incidents.SavedIncident{
+			ID:              saved_incidents.IncidentID("Chesterfield", "Vehicle Crash", "Crash", "HULL ST RD AT COALFIELD RD", "EB"),
+			Jurisdiction:    "Chesterfield",
+			Incident:        "Vehicle Crash",
+			IncidentType:    "Crash",
+			Location:        "HULL ST RD AT COALFIELD RD",
+			Direction:       "EB",
+			LastKnownStatus: "Active",
+			Latitude:        37.4521,
+			Longitude:       -77.6512,
+			IsActive:        "-",
+		}
+	})
+
+	emptyJurisdictions := func(jurisdictions ...string) {
+		for _, jurisdiction := range jurisdictions {
+			chesterfieldMock.On("GetTrafficIncidents", ctx, jurisdiction).Return(chesterfield.TrafficIncident{}, nil)
+		}
+	}
+
+	It("does nothing for no incidents", func() {
+		emptyJurisdictions(chesterfield.Jurisdictions...)
+		incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{}, nil)
+
+		err := subject.HarvestIncidents(ctx)
+
+		Expect(len(chesterfieldMock.Calls)).To(Equal(3))
+		Expect(len(incidentDaoMock.Calls)).To(Equal(1))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("stores an incident with parsed coordinates", func() {
+		chesterfieldMock.On("GetTrafficIncidents", ctx, "Chesterfield").Return(trafficIncident, nil)
+		emptyJurisdictions("Henrico", "Richmond")
+		incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{}, nil)
+		incidentDaoMock.On("SaveIncident", ctx, mock.MatchedBy(func(incident saved_incidents.SavedIncident) bool {
+			Expect(incident.ID).To(Equal(savedIncident.ID))
+			Expect(incident.Jurisdiction).To(Equal("Chesterfield"))
+			Expect(incident.Incident).To(Equal("Vehicle Crash"))
+			Expect(incident.IncidentType).To(Equal("Crash"))
+			Expect(incident.Location).To(Equal("HULL ST RD AT COALFIELD RD"))
+			Expect(incident.Direction).To(Equal("EB"))
+			Expect(incident.LastKnownStatus).To(Equal("Active"))
+			Expect(incident.Latitude).To(Equal(37.4521))
+			Expect(incident.Longitude).To(Equal(-77.6512))
+			return true
+		})).Return(nil)
+
+		err := subject.HarvestIncidents(ctx)
+
+		Expect(len(incidentDaoMock.Calls)).To(Equal(2))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("keeps incidents separate per jurisdiction", func() {
+		chesterfieldMock.On("GetTrafficIncidents", ctx, "Chesterfield").Return(trafficIncident, nil)
+		chesterfieldMock.On("GetTrafficIncidents", ctx, "Henrico").Return(trafficIncident, nil)
+		emptyJurisdictions("Richmond")
+		incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{savedIncident}, nil)
+		incidentDaoMock.On("SaveIncident", ctx, mock.MatchedBy(func(incident saved_incidents.SavedIncident) bool {
+			Expect(incident.Jurisdiction).To(Equal("Henrico"))
+			return true
+		})).Return(nil)
+
+		err := subject.HarvestIncidents(ctx)
+
+		Expect(len(incidentDaoMock.Calls)).To(Equal(2))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("updates an incident", func() {
+		trafficIncident[0].Status = "Cleared"
+		chesterfieldMock.On("GetTrafficIncidents", ctx, "Chesterfield").Return(trafficIncident, nil)
+		emptyJurisdictions("Henrico", "Richmond")
+		incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{savedIncident}, nil)
+		incidentDaoMock.On("UpdateStatus", ctx, mock.MatchedBy(func(incident saved_incidents.SavedIncident) bool {
+			Expect(incident.ID).To(Equal(savedIncident.ID))
+			Expect(incident.LastKnownStatus).To(Equal("Cleared"))
+			return true
+		})).Return(nil)
+
+		err := subject.HarvestIncidents(ctx)
+
+		Expect(len(incidentDaoMock.Calls)).To(Equal(2))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("skips updates if status did not change", func() {
+		chesterfieldMock.On("GetTrafficIncidents", ctx, "Chesterfield").Return(trafficIncident, nil)
+		emptyJurisdictions("Henrico", "Richmond")
+		incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{savedIncident}, nil)
+
+		err := subject.HarvestIncidents(ctx)
+
+		Expect(len(incidentDaoMock.Calls)).To(Equal(1))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	It("resolves an incident", func() {
+		emptyJurisdictions(chesterfield.Jurisdictions...)
+		incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{savedIncident}, nil)
+		incidentDaoMock.On("UpdateStatus", ctx, mock.MatchedBy(func(incident saved_incidents.SavedIncident) bool {
+			Expect(incident.ID).To(Equal(savedIncident.ID))
+			Expect(incident.LastKnownStatus).To(Equal("resolved"))
+			return true
+		})).Return(nil)
+
+		err := subject.HarvestIncidents(ctx)
+
+		Expect(len(incidentDaoMock.Calls)).To(Equal(2))
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	Describe("propagates errors", func() {
+		unexpectedError := errors.New("error!")
+
+		It("when fetching traffic incidents, after trying every jurisdiction", func() {
+			chesterfieldMock.On("GetTrafficIncidents", ctx, "Chesterfield").Return(chesterfield.TrafficIncident{}, unexpectedError)
+			chesterfieldMock.On("GetTrafficIncidents", ctx, "Henrico").Return(chesterfield.TrafficIncident{}, unexpectedError)
+			chesterfieldMock.On("GetTrafficIncidents", ctx, "Richmond").Return(trafficIncident, nil)
+			incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{}, nil)
+			incidentDaoMock.On("SaveIncident", ctx, mock.Anything).Return(nil)
+
+			err := subject.HarvestIncidents(ctx)
+
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("fetching Chesterfield traffic incidents: error!\nfetching Henrico traffic incidents: error!"))
+			incidentDaoMock.AssertCalled(GinkgoT(), "SaveIncident", ctx, mock.MatchedBy(func(incident saved_incidents.SavedIncident) bool {
+				return incident.Jurisdiction == "Richmond"
+			}))
+		})
+
+		It("when fetching saved incidents", func() {
+			incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{}, unexpectedError)
+
+			err := subject.HarvestIncidents(ctx)
+
+			Expect(len(chesterfieldMock.Calls)).To(Equal(0))
+			Expect(err).Should(HaveOccurred())
+			Expect(err.Error()).To(Equal("error!"))
+		})
+
+		It("when saving an incident", func() {
+			chesterfieldMock.On("GetTrafficIncidents", ctx, "Chesterfield").Return(trafficIncident, nil)
+			emptyJurisdictions("Henrico", "Richmond")
+			incidentDaoMock.On("GetActiveIncidents", ctx).Return([]saved_incidents.SavedIncident{}, nil)
+			incidentDaoMock.On("SaveIncident", ctx, mock.Anything).Return(unexpectedError)
+
+			err := subject.HarvestIncidents(ctx)
+
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(err.Error()).To(Equal("updating Chesterfield traffic incidents: error!"))
+			Expect(len(chesterfieldMock.Calls)).To(Equal(3))
+		})
+	})
+})
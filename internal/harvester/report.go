@@ -0,0 +1,52 @@
+package harvester
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AgencyReport counts what a harvest did with one agency's calls. Errors
+// holds everything that went wrong, including a failed fetch, which leaves
+// the counts at zero.
+type AgencyReport struct {
+	New      int `json:"new"`
+	Updated  int `json:"updated"`
+	Resolved int `json:"resolved"`
+	Failed   int `json:"failed"`
+	// calls that weren't in the county's list but haven't been resolved,
+	// either still within their grace period or held back by the guard
+	Missing int `json:"missing"`
+	// resolutions the guard held back
+	Held int `json:"held"`
+	// writes an overlapping harvest got to first, they aren't errors since
+	// the other harvest already saved the same change
+	Conflicts int     `json:"conflicts"`
+	Errors    []error `json:"-"`
+}
+
+type HarvestReport struct {
+	Police AgencyReport `json:"police"`
+	Fire   AgencyReport `json:"fire"`
+}
+
+func (report *AgencyReport) fail(err error) {
+	report.Failed++
+	report.Errors = append(report.Errors, err)
+}
+
+func (report AgencyReport) String() string {
+	return fmt.Sprintf("%d new, %d updated, %d resolved, %d failed",
+		report.New, report.Updated, report.Resolved, report.Failed)
+}
+
+// Err joins the errors of both agencies, it is nil when nothing failed
+func (report HarvestReport) Err() error {
+	return errors.Join(append(report.Police.Errors, report.Fire.Errors...)...)
+}
+
+func (report *HarvestReport) agency(callType string) *AgencyReport {
+	if callType == "fire" {
+		return &report.Fire
+	}
+	return &report.Police
+}
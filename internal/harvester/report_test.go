@@ -0,0 +1,113 @@
+package harvester_test
+
+import (
+	"errors"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Harvest Report", func() {
+	unexpectedError := errors.New("error!")
+
+	withID := func(id string) func(saved_calls.SavedCall) bool {
+		return func(call saved_calls.SavedCall) bool { return call.ID == id }
+	}
+
+	It("counts what happened to each agency's calls", func() {
+		policeCall[0].CurrentStatus = "On Scene"
+		resolvedCall := savedCall
+		resolvedCall.ID = "0999"
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(fireCall, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{savedCall, resolvedCall}, nil)
+		daoMock.On("SaveCall", ctx, mock.Anything).Return(nil)
+		daoMock.On("UpdateStatus", ctx, mock.Anything).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.Updated).To(Equal(1))
+		Expect(report.Police.Resolved).To(Equal(1))
+		Expect(report.Police.New).To(Equal(0))
+		Expect(report.Fire.New).To(Equal(1))
+		Expect(report.Police.String()).To(Equal("0 new, 1 updated, 1 resolved, 0 failed"))
+	})
+
+	It("updates one agency when the other can't be fetched", func() {
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, unexpectedError)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.Anything).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).To(MatchError(unexpectedError))
+		Expect(report.Police.New).To(Equal(1))
+		Expect(report.Fire.Errors).To(HaveLen(1))
+		Expect(report.Police.Errors).To(BeEmpty())
+	})
+
+	It("keeps going after a call fails to save", func() {
+		secondCall := policeCall[0]
+		secondCall.ID = "0124"
+		policeCall = append(policeCall, secondCall)
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(withID("0123"))).Return(unexpectedError)
+		daoMock.On("SaveCall", ctx, mock.MatchedBy(withID("0124"))).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).To(MatchError(unexpectedError))
+		Expect(err.Error()).To(Equal("saving police call 0123: error!"))
+		Expect(report.Police.New).To(Equal(1))
+		Expect(report.Police.Failed).To(Equal(1))
+		Expect(report.Err()).To(MatchError(unexpectedError))
+	})
+
+	It("joins every failure", func() {
+		chesterfieldMock.On("GetFireCalls", ctx).Return(fireCall, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(chesterfield.CallForService{}, unexpectedError)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{}, nil)
+		daoMock.On("SaveCall", ctx, mock.Anything).Return(unexpectedError)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err.Error()).To(Equal("fetching police calls: error!\nsaving fire call 1234: error!"))
+		Expect(report.Fire.Failed).To(Equal(1))
+	})
+
+	It("writes each kind of change in one batch", func() {
+		secondCall := policeCall[0]
+		secondCall.ID = "0124"
+		thirdCall := policeCall[0]
+		thirdCall.ID = "0125"
+		thirdCall.CurrentStatus = "On Scene"
+		policeCall = append(policeCall, secondCall, thirdCall)
+		existingCall := savedCall
+		existingCall.ID = "0125"
+
+		chesterfieldMock.On("GetFireCalls", ctx).Return(chesterfield.CallForService{}, nil)
+		chesterfieldMock.On("GetPoliceCalls", ctx).Return(policeCall, nil)
+		daoMock.On("GetActiveCalls", ctx).Return([]saved_calls.SavedCall{existingCall}, nil)
+		daoMock.On("SaveCall", ctx, mock.Anything).Return(nil)
+		daoMock.On("UpdateStatus", ctx, mock.Anything).Return(nil)
+
+		report, err := subject.Harvest(ctx)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Police.New).To(Equal(2))
+		Expect(report.Police.Updated).To(Equal(1))
+		Expect(daoMock.Batches["SaveCalls"]).To(HaveLen(1))
+		Expect(daoMock.Batches["SaveCalls"][0]).To(HaveLen(2))
+		Expect(daoMock.Batches["UpdateStatuses"]).To(HaveLen(1))
+		Expect(daoMock.Batches["UpdateStatuses"][0][0].ID).To(Equal("0125"))
+	})
+})
@@ -0,0 +1,170 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	ledgerTableName = "NotificationLedger"
+	statusSending   = "sending"
+	statusSent      = "sent"
+	// a send that was claimed but never completed, e.g. the invocation timed
+	// out, can be claimed again after this long
+	claimLease = 5 * time.Minute
+	// stream records are retried for an hour at most, entries are kept well
+	// past that and then expire through the table's TTL
+	retention = 7 * 24 * time.Hour
+)
+
+type DynamoDB interface {
+	PutItem(ctx context.Context,
+		params *dynamodb.PutItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context,
+		params *dynamodb.UpdateItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context,
+		params *dynamodb.DeleteItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// Client records which notifications were sent, so a stream record that is
+// delivered again doesn't send them twice. A send is claimed before it goes
+// out, then completed, or released when it failed so a retry can send it.
+type Client interface {
+	// Claim reports false when the notification was already sent, or is
+	// being sent by another invocation
+	Claim(ctx context.Context, key string) (bool, error)
+	Complete(ctx context.Context, key string) error
+	Release(ctx context.Context, key string) error
+}
+
+type LedgerDataAccess struct {
+	Service DynamoDB
+	clock   func() time.Time
+}
+
+type Entry struct {
+	NotificationID string `dynamodbav:"notificationId"`
+	Status         string `dynamodbav:"status"`
+	// unix seconds, so claims compare as numbers
+	ClaimedAt int64 `dynamodbav:"claimedAt"`
+	// unix seconds, the table's TTL attribute
+	ExpiresAt int64 `dynamodbav:"expiresAt"`
+}
+
+// Key identifies one notification, a call's transition sent to a recipient
+func Key(callType string, callID string, transition string, recipient string) string {
+	return strings.Join([]string{callType, callID, transition, recipient}, "#")
+}
+
+func New(config aws.Config) *LedgerDataAccess {
+	return &LedgerDataAccess{
+		Service: dynamodb.NewFromConfig(config),
+		clock:   func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func NewWithClient(dynamoDB DynamoDB, clock func() time.Time) *LedgerDataAccess {
+	return &LedgerDataAccess{
+		Service: dynamoDB,
+		clock:   clock,
+	}
+}
+
+func entryKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"notificationId": &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func (dao *LedgerDataAccess) Claim(ctx context.Context, key string) (bool, error) {
+	now := dao.clock()
+	item, err := attributevalue.MarshalMap(Entry{
+		NotificationID: key,
+		Status:         statusSending,
+		ClaimedAt:      now.Unix(),
+		ExpiresAt:      now.Add(retention).Unix(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// a claim that was never completed is taken over once its lease is up
+	condition := expression.AttributeNotExists(expression.Name("notificationId")).Or(
+		expression.Name("status").Equal(expression.Value(statusSending)).
+			And(expression.Name("claimedAt").LessThan(expression.Value(now.Add(-claimLease).Unix()))),
+	)
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = dao.Service.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(ledgerTableName),
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (dao *LedgerDataAccess) Complete(ctx context.Context, key string) error {
+	expr, err := expression.
+		NewBuilder().
+		WithUpdate(expression.Set(expression.Name("status"), expression.Value(statusSent))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(ledgerTableName),
+		Key:                       entryKey(key),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	return err
+}
+
+func (dao *LedgerDataAccess) Release(ctx context.Context, key string) error {
+	// a notification that was completed in the meantime stays sent
+	expr, err := expression.
+		NewBuilder().
+		WithCondition(expression.Name("status").Equal(expression.Value(statusSending))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(ledgerTableName),
+		Key:                       entryKey(key),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package ledger_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/ledger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBMock struct {
+	mock.Mock
+}
+
+func (dynamoDBMock *DynamoDBMock) PutItem(ctx context.Context, input *dynamodb.PutItemInput, options ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) UpdateItem(ctx context.Context, input *dynamodb.UpdateItemInput, options ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) DeleteItem(ctx context.Context, input *dynamodb.DeleteItemInput, options ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+
+var subject *ledger.LedgerDataAccess
+var dynamoDBMock *DynamoDBMock
+var currentTime = time.Date(2030, 1, 1, 6, 30, 0, 0, time.UTC)
+
+var _ = BeforeEach(func() {
+	dynamoDBMock = new(DynamoDBMock)
+	subject = ledger.NewWithClient(dynamoDBMock, func() time.Time { return currentTime })
+})
+
+func TestLedger(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ledger Suite")
+}
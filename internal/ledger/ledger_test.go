@@ -0,0 +1,106 @@
+package ledger_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/ledger"
+)
+
+var _ = Describe("Notification Ledger", func() {
+	var ctx context.Context
+	key := ledger.Key("police", "0123", "new:>dispatched", "sms:+18045550100")
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+	})
+
+	It("keys on the call, transition and recipient", func() {
+		Expect(key).To(Equal("police#0123#new:>dispatched#sms:+18045550100"))
+	})
+
+	Describe("Claim()", func() {
+		It("claims a notification that wasn't sent", func() {
+			dynamoDBMock.On("PutItem", ctx, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+				Expect(*input.TableName).To(Equal("NotificationLedger"))
+				Expect(input.Item["notificationId"]).To(Equal(&types.AttributeValueMemberS{Value: key}))
+				Expect(input.Item["status"]).To(Equal(&types.AttributeValueMemberS{Value: "sending"}))
+				Expect(input.Item["claimedAt"]).To(Equal(&types.AttributeValueMemberN{Value: "1893479400"}))
+				Expect(input.Item["expiresAt"]).To(Equal(&types.AttributeValueMemberN{Value: "1894084200"}))
+				Expect(*input.ConditionExpression).To(Equal("(attribute_not_exists (#0)) OR ((#1 = :0) AND (#2 < :1))"))
+				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{
+					"#0": "notificationId",
+					"#1": "status",
+					"#2": "claimedAt",
+				}))
+				Expect(input.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
+					":0": &types.AttributeValueMemberS{Value: "sending"},
+					":1": &types.AttributeValueMemberN{Value: "1893479100"},
+				}))
+				return true
+			}), mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+			claimed, err := subject.Claim(ctx, key)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(claimed).To(BeTrue())
+		})
+
+		It("doesn't claim a notification that was already sent", func() {
+			dynamoDBMock.On("PutItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			claimed, err := subject.Claim(ctx, key)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(claimed).To(BeFalse())
+		})
+
+		It("returns other errors", func() {
+			unexpectedError := errors.New("error!")
+			dynamoDBMock.On("PutItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, unexpectedError)
+
+			claimed, err := subject.Claim(ctx, key)
+
+			Expect(err).To(MatchError(unexpectedError))
+			Expect(claimed).To(BeFalse())
+		})
+	})
+
+	Describe("Complete()", func() {
+		It("marks the notification sent", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				Expect(input.Key["notificationId"]).To(Equal(&types.AttributeValueMemberS{Value: key}))
+				Expect(*input.UpdateExpression).To(Equal("SET #0 = :0\n"))
+				Expect(input.ExpressionAttributeValues[":0"]).To(Equal(&types.AttributeValueMemberS{Value: "sent"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+			Expect(subject.Complete(ctx, key)).To(Succeed())
+		})
+	})
+
+	Describe("Release()", func() {
+		It("removes a claim that is still sending", func() {
+			dynamoDBMock.On("DeleteItem", ctx, mock.MatchedBy(func(input *dynamodb.DeleteItemInput) bool {
+				Expect(input.Key["notificationId"]).To(Equal(&types.AttributeValueMemberS{Value: key}))
+				Expect(*input.ConditionExpression).To(Equal("#0 = :0"))
+				Expect(input.ExpressionAttributeValues[":0"]).To(Equal(&types.AttributeValueMemberS{Value: "sending"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+			Expect(subject.Release(ctx, key)).To(Succeed())
+		})
+
+		It("leaves a notification that was sent in the meantime", func() {
+			dynamoDBMock.On("DeleteItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.DeleteItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			Expect(subject.Release(ctx, key)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,94 @@
+package lifecycle
+
+import (
+	"strings"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+type EventType string
+
+const (
+	// a call seen for the first time
+	NewCall EventType = "new"
+	// units arrived, dispatched -> on scene
+	OnScene EventType = "on_scene"
+	// the call dropped off the county's list, or expired while still active
+	Resolved EventType = "resolved"
+	// a resolved call showed up as active again
+	Reopened EventType = "reopened"
+	// any other status change, e.g. on scene -> dispatched
+	StatusChanged EventType = "status_changed"
+)
+
+var EventTypes = []EventType{NewCall, OnScene, Resolved, Reopened, StatusChanged}
+
+// stream event names
+const (
+	insert = "INSERT"
+	modify = "MODIFY"
+	remove = "REMOVE"
+)
+
+type Event struct {
+	Type    EventType
+	OldCall saved_calls.SavedCall
+	NewCall saved_calls.SavedCall
+}
+
+func status(call saved_calls.SavedCall) string {
+	return strings.ToLower(call.LastKnownStatus)
+}
+
+// Classify turns a stream record into a lifecycle event. Records that don't
+// change a call's status aren't events, and neither are changes to the cross
+// street copies of a call, since the call itself changes at the same time.
+func Classify(eventName string, oldCall saved_calls.SavedCall, newCall saved_calls.SavedCall) (Event, bool) {
+	event := Event{OldCall: oldCall, NewCall: newCall}
+	if oldCall.IsAlias() || newCall.IsAlias() {
+		return event, false
+	}
+
+	// a call the street backfill moved is saved again under its new key and
+	// then removed from the old one, neither is news
+	if (eventName == insert && newCall.Moved) || (eventName == remove && oldCall.Moved) {
+		return event, false
+	}
+
+	switch eventName {
+	case insert:
+		event.Type = NewCall
+		return event, true
+	case remove:
+		// removing a resolved call is cleanup, but an active call that
+		// expires is treated as resolved so subscribers hear about it
+		if status(oldCall) == "resolved" || oldCall.ID == "" {
+			return event, false
+		}
+		event.NewCall = oldCall
+		event.NewCall.LastKnownStatus = "resolved"
+		event.NewCall.IsActive = ""
+		event.Type = Resolved
+		return event, true
+	case modify:
+	default:
+		return event, false
+	}
+
+	oldStatus, newStatus := status(oldCall), status(newCall)
+	switch {
+	case oldStatus == newStatus:
+		return event, false
+	case oldStatus == "":
+		event.Type = NewCall
+	case oldStatus == "resolved":
+		event.Type = Reopened
+	case newStatus == "resolved":
+		event.Type = Resolved
+	case newStatus == "on scene":
+		event.Type = OnScene
+	default:
+		event.Type = StatusChanged
+	}
+	return event, true
+}
@@ -0,0 +1,104 @@
+package lifecycle_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+func callWithStatus(status string) saved_calls.SavedCall {
+	if status == "" {
+		return saved_calls.SavedCall{}
+	}
+	return saved_calls.SavedCall{
+		ID:              "0123",
+		CallType:        "police",
+		LastKnownStatus: status,
+		IsActive:        "-",
+		StreetName:      "FAKE RD",
+	}
+}
+
+var _ = Describe("Lifecycle", func() {
+	DescribeTable("Classify()",
+		func(eventName string, oldStatus string, newStatus string, expected lifecycle.EventType) {
+			event, ok := lifecycle.Classify(eventName, callWithStatus(oldStatus), callWithStatus(newStatus))
+
+			Expect(ok).To(BeTrue())
+			Expect(event.Type).To(Equal(expected))
+		},
+		Entry("an inserted call is new", "INSERT", "", "dispatched", lifecycle.NewCall),
+		Entry("an inserted call that is already on scene is new", "INSERT", "", "on scene", lifecycle.NewCall),
+		Entry("units arriving", "MODIFY", "dispatched", "on scene", lifecycle.OnScene),
+		Entry("resolving from dispatched", "MODIFY", "dispatched", "resolved", lifecycle.Resolved),
+		Entry("resolving from on scene", "MODIFY", "on scene", "resolved", lifecycle.Resolved),
+		Entry("a resolved call coming back", "MODIFY", "resolved", "dispatched", lifecycle.Reopened),
+		Entry("units being redispatched", "MODIFY", "on scene", "dispatched", lifecycle.StatusChanged),
+		Entry("an update to an item without a status", "MODIFY", "", "dispatched", lifecycle.NewCall),
+		Entry("an active call expiring", "REMOVE", "on scene", "", lifecycle.Resolved),
+	)
+
+	DescribeTable("Classify() ignores",
+		func(eventName string, oldStatus string, newStatus string) {
+			_, ok := lifecycle.Classify(eventName, callWithStatus(oldStatus), callWithStatus(newStatus))
+
+			Expect(ok).To(BeFalse())
+		},
+		Entry("an unchanged status", "MODIFY", "dispatched", "dispatched"),
+		Entry("a status that only changed case", "MODIFY", "On Scene", "on scene"),
+		Entry("a resolved call expiring", "REMOVE", "resolved", ""),
+		Entry("an unknown event", "UNKNOWN", "dispatched", "on scene"),
+	)
+
+	DescribeTable("Classify() ignores cross street copies",
+		func(eventName string, oldStatus string, newStatus string) {
+			oldCall, newCall := callWithStatus(oldStatus), callWithStatus(newStatus)
+			oldCall.PrimaryStreet, newCall.PrimaryStreet = "HULL ST RD", "HULL ST RD"
+
+			_, ok := lifecycle.Classify(eventName, oldCall, newCall)
+
+			Expect(ok).To(BeFalse())
+		},
+		Entry("when inserted", "INSERT", "", "dispatched"),
+		Entry("when updated", "MODIFY", "dispatched", "on scene"),
+		Entry("when removed", "REMOVE", "on scene", ""),
+	)
+
+	DescribeTable("Classify() ignores calls the street backfill moved",
+		func(eventName string, oldStatus string, newStatus string) {
+			oldCall, newCall := callWithStatus(oldStatus), callWithStatus(newStatus)
+			oldCall.Moved, newCall.Moved = oldStatus != "", newStatus != ""
+
+			_, ok := lifecycle.Classify(eventName, oldCall, newCall)
+
+			Expect(ok).To(BeFalse())
+		},
+		Entry("when saved under the new street", "INSERT", "", "dispatched"),
+		Entry("when removed from the old street", "REMOVE", "on scene", ""),
+	)
+
+	It("still reports status changes to a moved call", func() {
+		oldCall, newCall := callWithStatus("dispatched"), callWithStatus("on scene")
+		oldCall.Moved, newCall.Moved = true, true
+
+		event, ok := lifecycle.Classify("MODIFY", oldCall, newCall)
+
+		Expect(ok).To(BeTrue())
+		Expect(event.Type).To(Equal(lifecycle.OnScene))
+	})
+
+	It("reports the last known call for an expiring call", func() {
+		oldCall := callWithStatus("on scene")
+
+		event, ok := lifecycle.Classify("REMOVE", oldCall, saved_calls.SavedCall{})
+
+		Expect(ok).To(BeTrue())
+		Expect(event.OldCall).To(Equal(oldCall))
+		Expect(event.NewCall.ID).To(Equal("0123"))
+		Expect(event.NewCall.StreetName).To(Equal("FAKE RD"))
+		Expect(event.NewCall.LastKnownStatus).To(Equal("resolved"))
+		Expect(event.NewCall.IsActive).To(Equal(""))
+	})
+})
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Metric struct {
+	Name  string
+	Value float64
+	// a CloudWatch unit, e.g. "Count"
+	Unit       string
+	Dimensions map[string]string
+}
+
+type Recorder interface {
+	Record(metric Metric)
+}
+
+// EmbeddedRecorder writes metrics in the CloudWatch embedded metric format.
+// Lambda sends stdout to CloudWatch Logs, which turns these lines into
+// metrics without any calls to the CloudWatch API.
+type EmbeddedRecorder struct {
+	namespace string
+	writer    io.Writer
+	clock     func() time.Time
+	mutex     sync.Mutex
+}
+
+func NewEmbedded(namespace string, writer io.Writer, clock func() time.Time) *EmbeddedRecorder {
+	return &EmbeddedRecorder{
+		namespace: namespace,
+		writer:    writer,
+		clock:     clock,
+	}
+}
+
+type metricDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+type metricDirective struct {
+	Namespace  string             `json:"Namespace"`
+	Dimensions [][]string         `json:"Dimensions"`
+	Metrics    []metricDefinition `json:"Metrics"`
+}
+
+type metadata struct {
+	Timestamp         int64             `json:"Timestamp"`
+	CloudWatchMetrics []metricDirective `json:"CloudWatchMetrics"`
+}
+
+func (recorder *EmbeddedRecorder) Record(metric Metric) {
+	dimensionNames := make([]string, 0, len(metric.Dimensions))
+	line := map[string]any{}
+	for name, value := range metric.Dimensions {
+		dimensionNames = append(dimensionNames, name)
+		line[name] = value
+	}
+	sort.Strings(dimensionNames)
+
+	line[metric.Name] = metric.Value
+	line["_aws"] = metadata{
+		Timestamp: recorder.clock().UnixMilli(),
+		CloudWatchMetrics: []metricDirective{{
+			Namespace:  recorder.namespace,
+			Dimensions: [][]string{dimensionNames},
+			Metrics:    []metricDefinition{{Name: metric.Name, Unit: metric.Unit}},
+		}},
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		log.Printf("Error encoding metric %s: %v\n", metric.Name, err)
+		return
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	_, err = recorder.writer.Write(append(data, '\n'))
+	if err != nil {
+		log.Printf("Error writing metric %s: %v\n", metric.Name, err)
+	}
+}
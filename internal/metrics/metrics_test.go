@@ -0,0 +1,55 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/metrics"
+)
+
+var _ = Describe("Embedded Metrics", func() {
+	var output *bytes.Buffer
+	var subject *metrics.EmbeddedRecorder
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		clock := func() time.Time { return time.Date(2022, 3, 24, 3, 22, 39, 0, time.UTC) }
+		subject = metrics.NewEmbedded("ActiveCallMonitor", output, clock)
+	})
+
+	It("writes a metric with its dimensions", func() {
+		subject.Record(metrics.Metric{
+			Name:       "ResolutionsHeld",
+			Value:      12,
+			Unit:       "Count",
+			Dimensions: map[string]string{"Agency": "police"},
+		})
+
+		Expect(output.String()).To(MatchJSON(`{
+			"_aws": {
+				"Timestamp": 1648092159000,
+				"CloudWatchMetrics": [{
+					"Namespace": "ActiveCallMonitor",
+					"Dimensions": [["Agency"]],
+					"Metrics": [{"Name": "ResolutionsHeld", "Unit": "Count"}]
+				}]
+			},
+			"Agency": "police",
+			"ResolutionsHeld": 12
+		}`))
+	})
+
+	It("writes one line per metric", func() {
+		subject.Record(metrics.Metric{Name: "First", Value: 1})
+		subject.Record(metrics.Metric{Name: "Second", Value: 2})
+
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[1]).To(ContainSubstring(`"Second":2`))
+		Expect(lines[1]).To(ContainSubstring(`"Dimensions":[[]]`))
+	})
+})
@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// SlackNotifier posts to a Slack incoming webhook, the target is the
+// webhook URL.
+type SlackNotifier struct {
+	RestClient *resty.Client
+}
+
+func NewSlack() *SlackNotifier {
+	return &SlackNotifier{
+		RestClient: resty.New().SetRetryCount(1),
+	}
+}
+
+func (notifier *SlackNotifier) Send(ctx context.Context, url string, message Message) error {
+	return checkResponse(notifier.RestClient.R().
+		SetContext(ctx).
+		SetBody(map[string]string{"text": message.Text}).
+		Post(url))
+}
+
+// DiscordNotifier posts to a Discord channel webhook, the target is the
+// webhook URL.
+type DiscordNotifier struct {
+	RestClient *resty.Client
+}
+
+func NewDiscord() *DiscordNotifier {
+	return &DiscordNotifier{
+		RestClient: resty.New().SetRetryCount(1),
+	}
+}
+
+func (notifier *DiscordNotifier) Send(ctx context.Context, url string, message Message) error {
+	return checkResponse(notifier.RestClient.R().
+		SetContext(ctx).
+		SetBody(map[string]string{"content": message.Text}).
+		Post(url))
+}
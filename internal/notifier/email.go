@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+type EmailNotifier struct {
+	address string
+	auth    smtp.Auth
+	from    string
+}
+
+// NewEmail sends through an SMTP relay. Credentials are optional, net/smtp
+// only sends them over TLS or to localhost.
+func NewEmail(host string, port int, username string, password string, from string) *EmailNotifier {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &EmailNotifier{
+		address: net.JoinHostPort(host, strconv.Itoa(port)),
+		auth:    auth,
+		from:    from,
+	}
+}
+
+// keeps header values on one line
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+func (notifier *EmailNotifier) Send(ctx context.Context, to string, message Message) error {
+	subject := message.Subject
+	if subject == "" {
+		subject = "Active call alert"
+	}
+
+	contentType := "text/plain; charset=UTF-8"
+	content := strings.ReplaceAll(message.Text, "\n", "\r\n")
+	if message.HTML != "" {
+		var err error
+		contentType, content, err = alternative(message)
+		if err != nil {
+			return err
+		}
+	}
+
+	body := strings.Join([]string{
+		"From: " + headerValue(notifier.from),
+		"To: " + headerValue(to),
+		"Subject: " + headerValue(subject),
+		"MIME-Version: 1.0",
+		"Content-Type: " + contentType,
+		"",
+		content,
+	}, "\r\n")
+
+	err := smtp.SendMail(notifier.address, notifier.auth, notifier.from, []string{to}, []byte(body))
+	if err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+	return nil
+}
+
+// alternative sends the text and html of a message together, mail clients
+// show the html when they can. The html is quoted-printable, its lines can
+// be longer than SMTP allows.
+func alternative(message Message) (string, string, error) {
+	var content bytes.Buffer
+	writer := multipart.NewWriter(&content)
+
+	textPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=UTF-8"},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	textPart.Write([]byte(strings.ReplaceAll(message.Text, "\n", "\r\n")))
+
+	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	encoder := quotedprintable.NewWriter(htmlPart)
+	encoder.Write([]byte(message.HTML))
+	if err := encoder.Close(); err != nil {
+		return "", "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+	return "multipart/alternative; boundary=" + writer.Boundary(), content.String(), nil
+}
@@ -0,0 +1,127 @@
+package notifier
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/rules"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+// FromEnv configures a notifier for every channel the environment has
+// settings for. Webhooks, Slack and Discord need none, SMS needs the Twilio
+// account and email needs an SMTP host.
+func FromEnv() map[string]Notifier {
+	notifiers := map[string]Notifier{
+		ChannelWebhook: NewWebhook(os.Getenv("WEBHOOK_SECRET"), time.Now),
+		ChannelSlack:   NewSlack(),
+		ChannelDiscord: NewDiscord(),
+	}
+
+	if accountSid := os.Getenv("TWILIO_ACCOUNT_SID"); accountSid != "" {
+		notifiers[ChannelSMS] = NewTwilio(
+			accountSid,
+			os.Getenv("TWILIO_API_KEY"),
+			os.Getenv("TWILIO_API_SECRET"),
+			os.Getenv("SMS_FROM"),
+		)
+	}
+
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			smtpPort = 587
+		}
+		notifiers[ChannelEmail] = NewEmail(
+			smtpHost,
+			smtpPort,
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("EMAIL_FROM"),
+		)
+	}
+
+	return notifiers
+}
+
+// the channels a default subscriber can have and the variable that holds each
+// one's target, in the order they are sent to
+var defaultChannelVariables = []struct {
+	channelType string
+	variable    string
+}{
+	{ChannelSMS, "SMS_TO"},
+	{ChannelEmail, "EMAIL_TO"},
+	{ChannelWebhook, "WEBHOOK_URL"},
+	{ChannelSlack, "SLACK_WEBHOOK_URL"},
+	{ChannelDiscord, "DISCORD_WEBHOOK_URL"},
+}
+
+func defaultChannels() []subscriptions.Channel {
+	channels := []subscriptions.Channel{}
+	for _, channel := range defaultChannelVariables {
+		if target := os.Getenv(channel.variable); target != "" {
+			channels = append(channels, subscriptions.Channel{Type: channel.channelType, Target: target})
+		}
+	}
+	return channels
+}
+
+// DefaultSubscription is the single subscriber the *_TO and *_URL variables
+// and the rules file describe, it isn't stored in the subscriptions table.
+// It's nil when none of the channels are set.
+func DefaultSubscription() (*subscriptions.Subscription, error) {
+	channels := defaultChannels()
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	// without a rules file every status change is sent
+	var notificationRules rules.RuleSet
+	if rulesFile := os.Getenv("RULES_FILE"); rulesFile != "" {
+		var err error
+		notificationRules, err = rules.Load(rulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load rules: %w", err)
+		}
+	}
+
+	subscription := &subscriptions.Subscription{
+		UserID:         "default",
+		SubscriptionID: "default",
+		Filters:        notificationRules,
+		Channels:       channels,
+		Report:         os.Getenv("ACTIVITY_REPORT"),
+	}
+	if err := applyDelivery(subscription); err != nil {
+		return nil, fmt.Errorf("invalid delivery settings: %w", err)
+	}
+	return subscription, nil
+}
+
+// the default subscriber's quiet hours, rate limit, digest and report come
+// from the environment as well, each is off unless its variables are set
+func applyDelivery(subscription *subscriptions.Subscription) error {
+	if start, end := os.Getenv("QUIET_HOURS_START"), os.Getenv("QUIET_HOURS_END"); start != "" || end != "" {
+		subscription.QuietHours = &subscriptions.QuietHours{Start: start, End: end}
+		if err := subscription.QuietHours.Validate(); err != nil {
+			return err
+		}
+	}
+	if window := os.Getenv("RATE_LIMIT_WINDOW"); window != "" {
+		count, _ := strconv.Atoi(os.Getenv("RATE_LIMIT_COUNT"))
+		subscription.RateLimit = &subscriptions.RateLimit{Count: count, Window: window}
+		if err := subscription.RateLimit.Validate(); err != nil {
+			return err
+		}
+	}
+	if window := os.Getenv("DIGEST_WINDOW"); window != "" {
+		subscription.Digest = &subscriptions.Digest{Window: window}
+		if err := subscription.Digest.Validate(); err != nil {
+			return err
+		}
+	}
+	return subscriptions.ValidateReport(subscription.Report)
+}
@@ -0,0 +1,41 @@
+package notifier_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+var _ = Describe("DefaultSubscription()", func() {
+	BeforeEach(func() {
+		for _, variable := range []string{"SMS_TO", "EMAIL_TO", "WEBHOOK_URL", "SLACK_WEBHOOK_URL", "DISCORD_WEBHOOK_URL", "RULES_FILE"} {
+			GinkgoT().Setenv(variable, "")
+		}
+	})
+
+	It("is nil without any channels", func() {
+		subscription, err := notifier.DefaultSubscription()
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(subscription).To(BeNil())
+	})
+
+	It("lists the channels in the same order every time", func() {
+		GinkgoT().Setenv("DISCORD_WEBHOOK_URL", "https://discord.example/hook")
+		GinkgoT().Setenv("SMS_TO", "+18045550100")
+		GinkgoT().Setenv("EMAIL_TO", "someone@example.com")
+
+		for i := 0; i < 10; i++ {
+			subscription, err := notifier.DefaultSubscription()
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(subscription.Channels).To(Equal([]subscriptions.Channel{
+				{Type: notifier.ChannelSMS, Target: "+18045550100"},
+				{Type: notifier.ChannelEmail, Target: "someone@example.com"},
+				{Type: notifier.ChannelDiscord, Target: "https://discord.example/hook"},
+			}))
+		}
+	})
+})
@@ -0,0 +1,347 @@
+package notifier
+
+import (
+	"embed"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf16"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+const (
+	partSubject = "subject"
+	partText    = "text"
+	// a single SMS segment, fewer characters fit when the text needs unicode
+	smsLimit        = 160
+	smsUnicodeLimit = 70
+	ellipsis        = "..."
+)
+
+// Channels lists every channel type a template can be named for
+var Channels = []string{ChannelSMS, ChannelEmail, ChannelWebhook, ChannelSlack, ChannelDiscord}
+
+// TemplateData is what a message template is executed with
+type TemplateData struct {
+	Type    lifecycle.EventType
+	Channel string
+	Call    saved_calls.SavedCall
+	// the call before the change, empty for a new call
+	OldCall saved_calls.SavedCall
+}
+
+// TimeToArrival is how long units took to get on scene, zero until they are
+func (data TemplateData) TimeToArrival() time.Duration {
+	if data.Call.CallArrival.IsZero() || data.Call.CallReceived.IsZero() {
+		return 0
+	}
+	return data.Call.CallArrival.Sub(data.Call.CallReceived)
+}
+
+// TimeToResolve is how long the call was open, zero until it is resolved
+func (data TemplateData) TimeToResolve() time.Duration {
+	if data.Call.CallResolved.IsZero() || data.Call.CallReceived.IsZero() {
+		return 0
+	}
+	return data.Call.CallResolved.Sub(data.Call.CallReceived)
+}
+
+// MapsLink points Google Maps at the call, by its coordinates when it was
+// geocoded and by its address otherwise
+func (data TemplateData) MapsLink() string {
+	query := fmt.Sprintf("%s, Chesterfield, VA", data.Call.Location)
+	if data.Call.Latitude != 0 || data.Call.Longitude != 0 {
+		query = fmt.Sprintf("%.5f,%.5f", data.Call.Latitude, data.Call.Longitude)
+	}
+	return "https://www.google.com/maps/search/?api=1&query=" + url.QueryEscape(query)
+}
+
+var templateFuncs = template.FuncMap{
+	"duration": formatDuration,
+	"local": func(t time.Time) string {
+		return t.In(chesterfield.LocalTime).Format("3:04 PM")
+	},
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"truncate": truncate,
+}
+
+// Templates renders the messages for each channel and event type. A
+// template is named [channel.][event.]subject or [channel.][event.]text,
+// e.g. "sms.new.text", and the most specific one that is defined is used.
+type Templates struct {
+	set *template.Template
+}
+
+var defaultTemplates = template.Must(
+	template.New("default.tmpl").Funcs(templateFuncs).ParseFS(templateFiles, "templates/default.tmpl"),
+)
+
+func DefaultTemplates() *Templates {
+	return &Templates{set: defaultTemplates}
+}
+
+// ParseTemplates adds the templates in text to the defaults, replacing the
+// ones it defines again, and checks that every message still renders
+func ParseTemplates(text string) (*Templates, error) {
+	set := template.Must(defaultTemplates.Clone())
+	_, err := set.New("custom").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	templates := &Templates{set: set}
+	return templates, templates.validate()
+}
+
+func LoadTemplates(path string) (*Templates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplates(string(data))
+}
+
+// a message template for something that doesn't exist is most likely a typo
+func checkName(name string) error {
+	parts := strings.Split(name, ".")
+	part := parts[len(parts)-1]
+	if part != partSubject && part != partText {
+		// anything else is a helper for the message templates to use
+		return nil
+	}
+
+	prefix := parts[:len(parts)-1]
+	if len(prefix) > 0 && isChannel(prefix[0]) {
+		prefix = prefix[1:]
+	}
+	if len(prefix) > 0 && isEventType(prefix[0]) {
+		prefix = prefix[1:]
+	}
+	if len(prefix) > 0 {
+		return fmt.Errorf("template %q isn't named [channel.][event.]%s", name, part)
+	}
+	return nil
+}
+
+func isChannel(value string) bool {
+	for _, channel := range Channels {
+		if channel == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isEventType(value string) bool {
+	for _, eventType := range lifecycle.EventTypes {
+		if string(eventType) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// a call with everything set, so a template that uses a field that doesn't
+// exist fails at startup rather than on the first call
+var sampleCall = saved_calls.SavedCall{
+	ID:              "0123",
+	CallType:        "police",
+	CallReason:      "SUSPICIOUS SITUATION",
+	LastKnownStatus: "on scene",
+	CallReceived:    time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC),
+	CallArrival:     time.Date(2030, 1, 1, 12, 8, 0, 0, time.UTC),
+	CallResolved:    time.Date(2030, 1, 1, 12, 45, 0, 0, time.UTC),
+	Location:        "22XX FAKE RD",
+	Area:            "11",
+	Priority:        "3",
+	HouseNumber:     "22XX",
+	StreetName:      "FAKE RD",
+	Latitude:        37.3771,
+	Longitude:       -77.505,
+}
+
+func (templates *Templates) validate() error {
+	for _, defined := range templates.set.Templates() {
+		if err := checkName(defined.Name()); err != nil {
+			return err
+		}
+	}
+
+	for _, channel := range Channels {
+		for _, eventType := range lifecycle.EventTypes {
+			event := lifecycle.Event{Type: eventType, OldCall: sampleCall, NewCall: sampleCall}
+			if _, err := templates.Render(channel, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookup finds the most specific template for a part of a message
+func (templates *Templates) lookup(channel string, eventType lifecycle.EventType, part string) *template.Template {
+	for _, name := range []string{
+		channel + "." + string(eventType) + "." + part,
+		channel + "." + part,
+		string(eventType) + "." + part,
+		part,
+	} {
+		if found := templates.set.Lookup(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func (templates *Templates) execute(data TemplateData, part string) (string, error) {
+	found := templates.lookup(data.Channel, data.Type, part)
+	if found == nil {
+		return "", fmt.Errorf("no %s template for %s %s", part, data.Channel, data.Type)
+	}
+
+	var rendered strings.Builder
+	if err := found.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(rendered.String()), nil
+}
+
+// Render builds the message for an event on a channel. SMS text is cut
+// down to a single segment.
+func (templates *Templates) Render(channel string, event lifecycle.Event) (Message, error) {
+	data := TemplateData{
+		Type:    event.Type,
+		Channel: channel,
+		Call:    event.NewCall,
+		OldCall: event.OldCall,
+	}
+
+	subject, err := templates.execute(data, partSubject)
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := templates.execute(data, partText)
+	if err != nil {
+		return Message{}, err
+	}
+	if channel == ChannelSMS {
+		text = fitSMS(text)
+	}
+
+	return Message{
+		Subject: subject,
+		Text:    text,
+		Call:    event.NewCall,
+	}, nil
+}
+
+// NewDigest combines a recipient's held messages into one, a single message
+// goes out as it is
+func NewDigest(messages []Message) Message {
+	if len(messages) == 1 {
+		return messages[0]
+	}
+
+	lines := make([]string, 0, len(messages))
+	calls := make([]saved_calls.SavedCall, 0, len(messages))
+	for _, message := range messages {
+		lines = append(lines, message.Text)
+		calls = append(calls, message.Call)
+	}
+	return Message{
+		Subject: fmt.Sprintf("%d call alerts", len(messages)),
+		Text:    strings.Join(lines, "\n"),
+		Calls:   calls,
+	}
+}
+
+// rounded to the minute, e.g. "8m" or "1h 5m"
+func formatDuration(duration time.Duration) string {
+	minutes := int(duration.Round(time.Minute).Minutes())
+	switch {
+	case minutes < 1:
+		return "under a minute"
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+	}
+}
+
+func truncate(length int, text string) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	if length <= len(ellipsis) {
+		return string(runes[:length])
+	}
+	return strings.TrimSpace(string(runes[:length-len(ellipsis)])) + ellipsis
+}
+
+// the GSM 03.38 alphabet texts are sent in, the extension characters take
+// two of the 160
+const (
+	gsmBasic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsmExtension = "^{}\\[~]|€\f"
+)
+
+// smsCost is how much of a segment each character takes, a text with any
+// character outside the GSM alphabet is sent as UTF-16 instead
+func smsCost(text string) (func(rune) int, int) {
+	for _, char := range text {
+		if !strings.ContainsRune(gsmBasic, char) && !strings.ContainsRune(gsmExtension, char) {
+			return utf16.RuneLen, smsUnicodeLimit
+		}
+	}
+	return func(char rune) int {
+		if strings.ContainsRune(gsmExtension, char) {
+			return 2
+		}
+		return 1
+	}, smsLimit
+}
+
+// fitSMS cuts text down to one segment, at a word where it can
+func fitSMS(text string) string {
+	cost, limit := smsCost(text)
+
+	length := 0
+	for _, char := range text {
+		length += cost(char)
+	}
+	if length <= limit {
+		return text
+	}
+
+	// room for the ellipsis, which is plain GSM either way
+	budget := limit - len(ellipsis)
+	cut := 0
+	lastSpace := -1
+	for i, char := range text {
+		if budget-cost(char) < 0 {
+			break
+		}
+		budget -= cost(char)
+		if char == ' ' || char == '\n' {
+			lastSpace = i
+		}
+		cut = i + len(string(char))
+	}
+	// a word is only broken when the cut would lose most of the text
+	if lastSpace > cut/2 {
+		cut = lastSpace
+	}
+	return strings.TrimSpace(text[:cut]) + ellipsis
+}
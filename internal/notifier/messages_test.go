@@ -0,0 +1,238 @@
+package notifier_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+// go test ./internal/notifier -update rewrites the golden files from what
+// the templates render now
+var updateGolden = flag.Bool("update", false, "rewrite the golden files")
+
+var _ = Describe("Messages", func() {
+	var call saved_calls.SavedCall
+	received := time.Date(2030, 1, 1, 17, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		call = saved_calls.SavedCall{
+			ID:              "0123",
+			CallType:        "police",
+			CallReason:      "SUSPICIOUS SITUATION",
+			LastKnownStatus: "dispatched",
+			Location:        "22XX FAKE RD",
+		}
+	})
+
+	render := func(templates *notifier.Templates, channel string, eventType lifecycle.EventType) notifier.Message {
+		message, err := templates.Render(channel, lifecycle.Event{Type: eventType, NewCall: call})
+		Expect(err).ShouldNot(HaveOccurred())
+		return message
+	}
+
+	DescribeTable("default templates",
+		func(eventType lifecycle.EventType, subject string, text string) {
+			message := render(notifier.DefaultTemplates(), notifier.ChannelWebhook, eventType)
+
+			Expect(message.Subject).To(Equal(subject))
+			Expect(message.Text).To(Equal(text))
+			Expect(message.Call).To(Equal(call))
+		},
+		Entry("new call", lifecycle.NewCall, "New call at 22XX FAKE RD", "New call at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched"),
+		Entry("on scene", lifecycle.OnScene, "Units on scene at 22XX FAKE RD", "Units on scene at 22XX FAKE RD: SUSPICIOUS SITUATION"),
+		Entry("resolved", lifecycle.Resolved, "Call resolved at 22XX FAKE RD", "Call resolved at 22XX FAKE RD: SUSPICIOUS SITUATION"),
+		Entry("reopened", lifecycle.Reopened, "Call reopened at 22XX FAKE RD", "Call reopened at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched"),
+		Entry("status changed", lifecycle.StatusChanged, "Active call alert at 22XX FAKE RD", "Active call alert at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched"),
+		Entry("unknown event", lifecycle.EventType("other"), "Active call alert at 22XX FAKE RD", "Active call alert at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched"),
+	)
+
+	DescribeTable("durations",
+		func(arrival time.Duration, text string) {
+			call.CallReceived = received
+			call.CallArrival = received.Add(arrival)
+
+			Expect(render(notifier.DefaultTemplates(), notifier.ChannelSlack, lifecycle.OnScene).Text).To(Equal(text))
+		},
+		Entry("seconds", 20*time.Second, "Units on scene at 22XX FAKE RD: SUSPICIOUS SITUATION, under a minute after the call"),
+		Entry("minutes", 8*time.Minute+40*time.Second, "Units on scene at 22XX FAKE RD: SUSPICIOUS SITUATION, 9m after the call"),
+		Entry("hours", 2*time.Hour, "Units on scene at 22XX FAKE RD: SUSPICIOUS SITUATION, 2h after the call"),
+		Entry("hours and minutes", 65*time.Minute, "Units on scene at 22XX FAKE RD: SUSPICIOUS SITUATION, 1h 5m after the call"),
+	)
+
+	Describe("golden files", func() {
+		BeforeEach(func() {
+			call.CallReceived = received
+			call.CallArrival = received.Add(8 * time.Minute)
+			call.Area = "11"
+			call.Priority = "3"
+		})
+
+		for _, channel := range notifier.Channels {
+			for _, eventType := range lifecycle.EventTypes {
+				name := fmt.Sprintf("%s.%s.golden", eventType, channel)
+
+				It("renders "+name, func() {
+					if eventType == lifecycle.Resolved {
+						call.LastKnownStatus = "resolved"
+						call.CallResolved = received.Add(45 * time.Minute)
+					}
+					message := render(notifier.DefaultTemplates(), channel, eventType)
+					rendered := message.Subject + "\n\n" + message.Text + "\n"
+
+					path := filepath.Join("testdata", "golden", name)
+					if *updateGolden {
+						Expect(os.WriteFile(path, []byte(rendered), 0644)).To(Succeed())
+					}
+					golden, err := os.ReadFile(path)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(rendered).To(Equal(string(golden)))
+				})
+			}
+		}
+	})
+
+	Describe("MapsLink", func() {
+		templates, err := notifier.ParseTemplates(`{{define "text"}}{{.MapsLink}}{{end}}`)
+
+		It("links to the address", func() {
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(render(templates, notifier.ChannelSlack, lifecycle.StatusChanged).Text).To(Equal("https://www.google.com/maps/search/?api=1&query=22XX+FAKE+RD%2C+Chesterfield%2C+VA"))
+		})
+
+		It("links to the coordinates of a geocoded call", func() {
+			call.Latitude, call.Longitude = 37.3771, -77.505
+
+			Expect(render(templates, notifier.ChannelSlack, lifecycle.StatusChanged).Text).To(Equal("https://www.google.com/maps/search/?api=1&query=37.37710%2C-77.50500"))
+		})
+	})
+
+	Describe("ParseTemplates()", func() {
+		It("uses the most specific template", func() {
+			templates, err := notifier.ParseTemplates(`
+{{define "text"}}any {{.Call.ID}}{{end}}
+{{define "sms.text"}}sms {{.Call.ID}}{{end}}
+{{define "sms.new.text"}}new sms {{.Call.ID}}{{end}}
+`)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(render(templates, notifier.ChannelSMS, lifecycle.NewCall).Text).To(Equal("new sms 0123"))
+			Expect(render(templates, notifier.ChannelSMS, lifecycle.OnScene).Text).To(Equal("sms 0123"))
+			// the default event templates still beat the generic one
+			Expect(render(templates, notifier.ChannelSlack, lifecycle.NewCall).Text).To(Equal("New call at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched"))
+			Expect(render(templates, notifier.ChannelSlack, lifecycle.StatusChanged).Text).To(Equal("any 0123"))
+		})
+
+		It("gives templates the helper functions", func() {
+			templates, err := notifier.ParseTemplates(`{{define "slack.text"}}{{lower .Call.CallReason | truncate 12}} {{upper .Call.LastKnownStatus}} {{local .Call.CallReceived}}{{end}}`)
+			Expect(err).ShouldNot(HaveOccurred())
+			call.CallReceived = received
+
+			Expect(render(templates, notifier.ChannelSlack, lifecycle.NewCall).Text).To(Equal("suspiciou... DISPATCHED 12:00 PM"))
+		})
+
+		It("can see the call before the change", func() {
+			templates, err := notifier.ParseTemplates(`{{define "status_changed.text"}}{{.OldCall.LastKnownStatus}} to {{.Call.LastKnownStatus}}{{end}}`)
+			Expect(err).ShouldNot(HaveOccurred())
+			oldCall := call
+			call.LastKnownStatus = "enroute"
+
+			message, err := templates.Render(notifier.ChannelSlack, lifecycle.Event{Type: lifecycle.StatusChanged, OldCall: oldCall, NewCall: call})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(message.Text).To(Equal("dispatched to enroute"))
+		})
+
+		DescribeTable("rejects templates that can't render",
+			func(text string, expected string) {
+				_, err := notifier.ParseTemplates(text)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(expected))
+			},
+			Entry("syntax", `{{define "text"}}{{.Call.ID}{{end}}`, "template: custom:1: bad character"),
+			Entry("missing field", `{{define "sms.text"}}{{.Call.Street}}{{end}}`, "can't evaluate field Street"),
+			Entry("missing function", `{{define "text"}}{{shout .Call.ID}}{{end}}`, `function "shout" not defined`),
+			Entry("misnamed template", `{{define "new_call.text"}}{{.Call.ID}}{{end}}`, `template "new_call.text" isn't named [channel.][event.]text`),
+			Entry("unknown channel", `{{define "pager.subject"}}{{.Call.ID}}{{end}}`, `template "pager.subject" isn't named`),
+		)
+
+		It("loads a file", func() {
+			templates, err := notifier.LoadTemplates("testdata/custom.tmpl")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			message := render(templates, notifier.ChannelSMS, lifecycle.NewCall)
+
+			Expect(message.Subject).To(Equal("POLICE: SUSPICIOUS SITUATION"))
+			Expect(message.Text).To(Equal("POLICE 0123 at 22XX FAKE RD, dispatched"))
+		})
+	})
+
+	Describe("SMS", func() {
+		It("cuts long texts down to one segment", func() {
+			call.CallReason = strings.Repeat("VERY LONG REASON ", 12)
+
+			text := render(notifier.DefaultTemplates(), notifier.ChannelSMS, lifecycle.NewCall).Text
+
+			Expect(len(text)).To(BeNumerically("<=", 160))
+			Expect(text).To(HavePrefix("New call at 22XX FAKE RD: VERY LONG REASON"))
+			// without breaking a word
+			Expect(text).To(MatchRegexp(`(VERY|LONG|REASON)\.\.\.$`))
+		})
+
+		It("leaves other channels alone", func() {
+			call.CallReason = strings.Repeat("VERY LONG REASON ", 12)
+
+			Expect(len(render(notifier.DefaultTemplates(), notifier.ChannelEmail, lifecycle.NewCall).Text)).To(BeNumerically(">", 160))
+		})
+
+		It("counts the extension characters twice", func() {
+			call.CallReason = strings.Repeat("{}", 70)
+
+			text := render(notifier.DefaultTemplates(), notifier.ChannelSMS, lifecycle.NewCall).Text
+
+			Expect(text).To(Equal("New call at 22XX FAKE RD: " + strings.Repeat("{}", 32) + "{..."))
+		})
+
+		It("fits fewer characters when the text needs unicode", func() {
+			call.Location = "22XX FAKE RD 🚓"
+			call.CallReason = strings.Repeat("REASON ", 10)
+
+			text := render(notifier.DefaultTemplates(), notifier.ChannelSMS, lifecycle.NewCall).Text
+
+			Expect(text).To(Equal("New call at 22XX FAKE RD 🚓: REASON REASON REASON REASON REASON..."))
+		})
+	})
+
+	Describe("NewDigest()", func() {
+		It("sends a single message as it is", func() {
+			message := render(notifier.DefaultTemplates(), notifier.ChannelSMS, lifecycle.NewCall)
+
+			Expect(notifier.NewDigest([]notifier.Message{message})).To(Equal(message))
+		})
+
+		It("lists every message", func() {
+			other := call
+			other.ID = "0124"
+			other.Location = "1XX OTHER LN"
+
+			first := render(notifier.DefaultTemplates(), notifier.ChannelSMS, lifecycle.NewCall)
+			call = other
+			second := render(notifier.DefaultTemplates(), notifier.ChannelSMS, lifecycle.Resolved)
+			digest := notifier.NewDigest([]notifier.Message{first, second})
+
+			Expect(digest.Subject).To(Equal("2 call alerts"))
+			Expect(digest.Text).To(Equal("New call at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched\nCall resolved at 1XX OTHER LN: SUSPICIOUS SITUATION"))
+			Expect(digest.Calls).To(Equal([]saved_calls.SavedCall{first.Call, other}))
+		})
+	})
+})
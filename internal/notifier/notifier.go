@@ -0,0 +1,116 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/ledger"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+const (
+	ChannelSMS     = "sms"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+	ChannelDiscord = "discord"
+)
+
+type Message struct {
+	Subject string
+	Text    string
+	Call    saved_calls.SavedCall
+	// every call in a digest, Call is left empty
+	Calls []saved_calls.SavedCall
+	// optional, channels that can show it send it along with the text
+	HTML string
+}
+
+// A Notifier delivers a message to a target, whose meaning depends on the
+// channel: a phone number, an email address or a webhook URL.
+type Notifier interface {
+	Send(ctx context.Context, target string, message Message) error
+}
+
+// Dispatcher routes a message to the notifier for a subscription channel.
+type Dispatcher struct {
+	notifiers map[string]Notifier
+	ledger    ledger.Client
+}
+
+func NewDispatcher(notifiers map[string]Notifier) *Dispatcher {
+	return &Dispatcher{
+		notifiers: notifiers,
+	}
+}
+
+func (dispatcher *Dispatcher) Send(ctx context.Context, channel subscriptions.Channel, message Message) error {
+	notifier, ok := dispatcher.notifiers[channel.Type]
+	if !ok {
+		return fmt.Errorf("unsupported channel type: %s", channel.Type)
+	}
+	return notifier.Send(ctx, channel.Target, message)
+}
+
+// WithLedger makes Deliver skip notifications that were already sent
+func (dispatcher *Dispatcher) WithLedger(ledger ledger.Client) *Dispatcher {
+	dispatcher.ledger = ledger
+	return dispatcher
+}
+
+// the same call can go through the same status change again, e.g. after
+// being reopened, those are rare enough to count as one notification
+func transition(event lifecycle.Event) string {
+	return fmt.Sprintf("%s:%s>%s", event.Type, event.OldCall.LastKnownStatus, event.NewCall.LastKnownStatus)
+}
+
+func notificationKey(event lifecycle.Event, channel subscriptions.Channel) string {
+	return ledger.Key(event.NewCall.CallType, event.NewCall.ID, transition(event), outbox.Recipient(channel))
+}
+
+// once runs handle at most once per key when there is a ledger. A handle
+// that fails is released so that a retry of the same event runs it.
+func (dispatcher *Dispatcher) once(ctx context.Context, key string, handle func() error) error {
+	if dispatcher.ledger == nil {
+		return handle()
+	}
+
+	claimed, err := dispatcher.ledger.Claim(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		log.Printf("Skipping %s, it was already handled\n", key)
+		return nil
+	}
+
+	err = handle()
+	if err != nil {
+		return errors.Join(err, dispatcher.ledger.Release(ctx, key))
+	}
+
+	// the message is out, failing here would only send it again
+	if err := dispatcher.ledger.Complete(ctx, key); err != nil {
+		log.Printf("Error completing %s: %v\n", key, err)
+	}
+	return nil
+}
+
+// Deliver sends the message for an event to a channel, at most once per
+// channel target when there is a ledger
+func (dispatcher *Dispatcher) Deliver(ctx context.Context, event lifecycle.Event, channel subscriptions.Channel, message Message) error {
+	return dispatcher.SendOnce(ctx, notificationKey(event, channel), channel, message)
+}
+
+// SendOnce sends a message that isn't about a call event, at most once per
+// key when there is a ledger
+func (dispatcher *Dispatcher) SendOnce(ctx context.Context, key string, channel subscriptions.Channel, message Message) error {
+	return dispatcher.once(ctx, key, func() error {
+		return dispatcher.Send(ctx, channel, message)
+	})
+}
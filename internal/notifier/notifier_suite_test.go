@@ -0,0 +1,112 @@
+package notifier_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type receivedMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpStandIn speaks just enough SMTP for net/smtp.SendMail and keeps what
+// it receives
+type smtpStandIn struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	mail     []receivedMail
+}
+
+func startSMTPStandIn() *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ShouldNot(HaveOccurred())
+
+	server := &smtpStandIn{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+	return server
+}
+
+func (server *smtpStandIn) Address() (string, int) {
+	address := server.listener.Addr().(*net.TCPAddr)
+	return address.IP.String(), address.Port
+}
+
+func (server *smtpStandIn) Mail() []receivedMail {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]receivedMail{}, server.mail...)
+}
+
+func (server *smtpStandIn) Close() {
+	server.listener.Close()
+}
+
+func (server *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var current receivedMail
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = receivedMail{From: strings.Trim(strings.TrimSpace(line)[10:], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.To = append(current.To, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			current.Data = data.String()
+			server.mutex.Lock()
+			server.mail = append(server.mail, current)
+			server.mutex.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Notifier Suite")
+}
@@ -0,0 +1,383 @@
+package notifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+type MessageCreatorMock struct {
+	mock.Mock
+}
+
+func (api *MessageCreatorMock) CreateMessage(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error) {
+	args := api.Called(params)
+	return args.Get(0).(*openapi.ApiV2010Message), args.Error(1)
+}
+
+type NotifierMock struct {
+	mock.Mock
+}
+
+func (notifierMock *NotifierMock) Send(ctx context.Context, target string, message notifier.Message) error {
+	args := notifierMock.Called(ctx, target, message)
+	return args.Error(0)
+}
+
+type LedgerMock struct {
+	mock.Mock
+}
+
+func (ledgerMock *LedgerMock) Claim(ctx context.Context, key string) (bool, error) {
+	args := ledgerMock.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+func (ledgerMock *LedgerMock) Complete(ctx context.Context, key string) error {
+	args := ledgerMock.Called(ctx, key)
+	return args.Error(0)
+}
+func (ledgerMock *LedgerMock) Release(ctx context.Context, key string) error {
+	args := ledgerMock.Called(ctx, key)
+	return args.Error(0)
+}
+
+type capturedRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// records every request to a local http server and answers with status
+func startHTTPStandIn(status int) (*httptest.Server, *[]capturedRequest) {
+	requests := &[]capturedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*requests = append(*requests, capturedRequest{Header: r.Header.Clone(), Body: body})
+		w.WriteHeader(status)
+	}))
+	DeferCleanup(server.Close)
+	return server, requests
+}
+
+var _ = Describe("Notifiers", func() {
+	var ctx context.Context
+	var message notifier.Message
+	sentAt := time.Date(2030, 1, 1, 6, 30, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		message = notifier.Message{
+			Subject: "Call on FAKE RD",
+			Text:    "Active call alert at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched",
+			Call: saved_calls.SavedCall{
+				ID:              "0123",
+				CallType:        "police",
+				CallReason:      "SUSPICIOUS SITUATION",
+				LastKnownStatus: "dispatched",
+				Location:        "22XX FAKE RD",
+				StreetName:      "FAKE RD",
+			},
+		}
+	})
+
+	Describe("TwilioNotifier", func() {
+		It("sends a text", func() {
+			api := &MessageCreatorMock{}
+			api.On("CreateMessage", mock.MatchedBy(func(params *openapi.CreateMessageParams) bool {
+				Expect(*params.To).To(Equal("+18045550100"))
+				Expect(*params.From).To(Equal("+18045550199"))
+				Expect(*params.Body).To(Equal(message.Text))
+				return true
+			})).Return(&openapi.ApiV2010Message{}, nil)
+
+			subject := notifier.NewTwilioWithClient(api, "+18045550199")
+
+			Expect(subject.Send(ctx, "+18045550100", message)).To(Succeed())
+			Expect(api.Calls).To(HaveLen(1))
+		})
+	})
+
+	Describe("EmailNotifier", func() {
+		It("sends through the smtp relay", func() {
+			server := startSMTPStandIn()
+			DeferCleanup(server.Close)
+			host, port := server.Address()
+
+			subject := notifier.NewEmail(host, port, "", "", "alerts@example.com")
+
+			Expect(subject.Send(ctx, "kevin@example.com", message)).To(Succeed())
+
+			mail := server.Mail()
+			Expect(mail).To(HaveLen(1))
+			Expect(mail[0].From).To(Equal("alerts@example.com"))
+			Expect(mail[0].To).To(Equal([]string{"kevin@example.com"}))
+			Expect(mail[0].Data).To(ContainSubstring("Subject: Call on FAKE RD\r\n"))
+			Expect(mail[0].Data).To(ContainSubstring("To: kevin@example.com\r\n"))
+			Expect(mail[0].Data).To(ContainSubstring("\r\n\r\n" + message.Text))
+		})
+
+		It("sends html along with the text", func() {
+			server := startSMTPStandIn()
+			DeferCleanup(server.Close)
+			host, port := server.Address()
+			message.HTML = "<p>Active call alert at <b>22XX FAKE RD</b></p>"
+
+			subject := notifier.NewEmail(host, port, "", "", "alerts@example.com")
+
+			Expect(subject.Send(ctx, "kevin@example.com", message)).To(Succeed())
+			data := server.Mail()[0].Data
+			Expect(data).To(ContainSubstring("Content-Type: multipart/alternative; boundary="))
+			Expect(data).To(ContainSubstring("Content-Type: text/plain; charset=UTF-8\r\n\r\n" + message.Text))
+			Expect(data).To(ContainSubstring("Content-Type: text/html; charset=UTF-8\r\n"))
+			Expect(data).To(ContainSubstring("<p>Active call alert at <b>22XX FAKE RD</b></p>"))
+		})
+
+		It("keeps the subject on one line", func() {
+			server := startSMTPStandIn()
+			DeferCleanup(server.Close)
+			host, port := server.Address()
+			message.Subject = "Call\r\nBcc: someone@example.com"
+
+			subject := notifier.NewEmail(host, port, "", "", "alerts@example.com")
+
+			Expect(subject.Send(ctx, "kevin@example.com", message)).To(Succeed())
+			Expect(server.Mail()[0].Data).ToNot(ContainSubstring("\r\nBcc:"))
+		})
+
+		It("reports delivery failures", func() {
+			subject := notifier.NewEmail("127.0.0.1", 1, "", "", "alerts@example.com")
+
+			err := subject.Send(ctx, "kevin@example.com", message)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("unable to send email"))
+		})
+	})
+
+	Describe("WebhookNotifier", func() {
+		clock := func() time.Time { return sentAt }
+
+		It("posts signed json", func() {
+			server, requests := startHTTPStandIn(204)
+			subject := notifier.NewWebhook("shh", clock)
+
+			Expect(subject.Send(ctx, server.URL, message)).To(Succeed())
+
+			Expect(*requests).To(HaveLen(1))
+			request := (*requests)[0]
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(request.Header.Get("X-Signature-Timestamp")).To(Equal("1893479400"))
+			Expect(request.Header.Get("X-Signature-256")).To(Equal(notifier.Sign([]byte("shh"), "1893479400", request.Body)))
+
+			var payload notifier.WebhookPayload
+			Expect(json.Unmarshal(request.Body, &payload)).To(Succeed())
+			Expect(payload.Message).To(Equal(message.Text))
+			Expect(payload.Call.ID).To(Equal("0123"))
+			Expect(payload.Call.StreetName).To(Equal("FAKE RD"))
+			Expect(payload.SentAt).To(Equal(sentAt))
+			Expect(string(request.Body)).To(ContainSubstring(`"lastKnownStatus":"dispatched"`))
+		})
+
+		It("does not sign without a secret", func() {
+			server, requests := startHTTPStandIn(200)
+			subject := notifier.NewWebhook("", clock)
+
+			Expect(subject.Send(ctx, server.URL, message)).To(Succeed())
+
+			Expect((*requests)[0].Header.Get("X-Signature-256")).To(BeEmpty())
+		})
+
+		It("signs with a known value", func() {
+			Expect(notifier.Sign([]byte("shh"), "1", []byte("{}"))).
+				To(Equal("sha256=a90673f2895c42e3df468499ca588cea93b36c3f386f71fef268d0d9881e262f"))
+		})
+
+		It("returns error on non-successful status code", func() {
+			server, _ := startHTTPStandIn(500)
+			subject := notifier.NewWebhook("shh", clock)
+
+			err := subject.Send(ctx, server.URL, message)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("received invalid status code: 500"))
+		})
+	})
+
+	Describe("SlackNotifier", func() {
+		It("posts the text", func() {
+			server, requests := startHTTPStandIn(200)
+
+			Expect(notifier.NewSlack().Send(ctx, server.URL, message)).To(Succeed())
+
+			Expect(*requests).To(HaveLen(1))
+			Expect(string((*requests)[0].Body)).To(MatchJSON(`{"text": "` + message.Text + `"}`))
+		})
+	})
+
+	Describe("DiscordNotifier", func() {
+		It("posts the content", func() {
+			server, requests := startHTTPStandIn(204)
+
+			Expect(notifier.NewDiscord().Send(ctx, server.URL, message)).To(Succeed())
+
+			Expect(*requests).To(HaveLen(1))
+			Expect(string((*requests)[0].Body)).To(MatchJSON(`{"content": "` + message.Text + `"}`))
+		})
+
+		It("returns error on non-successful status code", func() {
+			server, _ := startHTTPStandIn(404)
+
+			err := notifier.NewDiscord().Send(ctx, server.URL, message)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Dispatcher", func() {
+		It("routes by channel type", func() {
+			slack := &NotifierMock{}
+			slack.On("Send", ctx, "https://hooks.slack.example/abc", message).Return(nil)
+			subject := notifier.NewDispatcher(map[string]notifier.Notifier{
+				notifier.ChannelSlack: slack,
+			})
+
+			err := subject.Send(ctx, subscriptions.Channel{Type: "slack", Target: "https://hooks.slack.example/abc"}, message)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(slack.Calls).To(HaveLen(1))
+		})
+
+		It("propagates errors", func() {
+			sms := &NotifierMock{}
+			sms.On("Send", ctx, mock.Anything, mock.Anything).Return(errors.New("error!"))
+			subject := notifier.NewDispatcher(map[string]notifier.Notifier{
+				notifier.ChannelSMS: sms,
+			})
+
+			err := subject.Send(ctx, subscriptions.Channel{Type: "sms", Target: "+18045550100"}, message)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("error!"))
+		})
+
+		It("rejects channels that aren't configured", func() {
+			subject := notifier.NewDispatcher(map[string]notifier.Notifier{})
+
+			err := subject.Send(ctx, subscriptions.Channel{Type: "email", Target: "kevin@example.com"}, message)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("unsupported channel type: email"))
+		})
+	})
+
+	Describe("Dispatcher.Deliver()", func() {
+		var sms *NotifierMock
+		var ledgerMock *LedgerMock
+		var subject *notifier.Dispatcher
+		var event lifecycle.Event
+		channel := subscriptions.Channel{Type: "sms", Target: "+18045550100"}
+		key := "police#0123#new:>dispatched#sms:+18045550100"
+
+		BeforeEach(func() {
+			sms = &NotifierMock{}
+			ledgerMock = &LedgerMock{}
+			subject = notifier.NewDispatcher(map[string]notifier.Notifier{
+				notifier.ChannelSMS: sms,
+			}).WithLedger(ledgerMock)
+			event = lifecycle.Event{Type: lifecycle.NewCall, NewCall: message.Call}
+		})
+
+		It("records a notification once it is sent", func() {
+			ledgerMock.On("Claim", ctx, key).Return(true, nil)
+			ledgerMock.On("Complete", ctx, key).Return(nil)
+			sms.On("Send", ctx, channel.Target, message).Return(nil)
+
+			err := subject.Deliver(ctx, event, channel, message)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sms.Calls).To(HaveLen(1))
+			ledgerMock.AssertCalled(GinkgoT(), "Complete", ctx, key)
+		})
+
+		It("skips a notification that was already sent", func() {
+			ledgerMock.On("Claim", ctx, key).Return(false, nil)
+
+			err := subject.Deliver(ctx, event, channel, message)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sms.Calls).To(BeEmpty())
+		})
+
+		It("releases a notification that failed so a retry sends it", func() {
+			ledgerMock.On("Claim", ctx, key).Return(true, nil)
+			ledgerMock.On("Release", ctx, key).Return(nil)
+			sms.On("Send", ctx, mock.Anything, mock.Anything).Return(errors.New("error!"))
+
+			err := subject.Deliver(ctx, event, channel, message)
+
+			Expect(err).To(MatchError("error!"))
+			ledgerMock.AssertCalled(GinkgoT(), "Release", ctx, key)
+			ledgerMock.AssertNotCalled(GinkgoT(), "Complete", ctx, key)
+		})
+
+		It("doesn't send when the ledger can't be reached", func() {
+			ledgerMock.On("Claim", ctx, key).Return(false, errors.New("error!"))
+
+			err := subject.Deliver(ctx, event, channel, message)
+
+			Expect(err).To(MatchError("error!"))
+			Expect(sms.Calls).To(BeEmpty())
+		})
+
+		It("keys each recipient and transition on its own", func() {
+			ledgerMock.On("Claim", ctx, mock.Anything).Return(true, nil)
+			ledgerMock.On("Complete", ctx, mock.Anything).Return(nil)
+			sms.On("Send", ctx, mock.Anything, mock.Anything).Return(nil)
+
+			Expect(subject.Deliver(ctx, event, channel, message)).To(Succeed())
+			Expect(subject.Deliver(ctx, event, subscriptions.Channel{Type: "sms", Target: "+18045550199"}, message)).To(Succeed())
+			onScene := event
+			onScene.Type = lifecycle.OnScene
+			onScene.OldCall = message.Call
+			onScene.NewCall.LastKnownStatus = "on scene"
+			Expect(subject.Deliver(ctx, onScene, channel, message)).To(Succeed())
+
+			ledgerMock.AssertCalled(GinkgoT(), "Claim", ctx, key)
+			ledgerMock.AssertCalled(GinkgoT(), "Claim", ctx, "police#0123#new:>dispatched#sms:+18045550199")
+			ledgerMock.AssertCalled(GinkgoT(), "Claim", ctx, "police#0123#on_scene:dispatched>on scene#sms:+18045550100")
+		})
+
+		It("sends other messages once per key", func() {
+			ledgerMock.On("Claim", ctx, "report#daily").Return(true, nil).Once()
+			ledgerMock.On("Claim", ctx, "report#daily").Return(false, nil)
+			ledgerMock.On("Complete", ctx, "report#daily").Return(nil)
+			sms.On("Send", ctx, channel.Target, message).Return(nil)
+
+			Expect(subject.SendOnce(ctx, "report#daily", channel, message)).To(Succeed())
+			Expect(subject.SendOnce(ctx, "report#daily", channel, message)).To(Succeed())
+			Expect(sms.Calls).To(HaveLen(1))
+		})
+
+		It("sends every time without a ledger", func() {
+			sms.On("Send", ctx, mock.Anything, mock.Anything).Return(nil)
+			subject = notifier.NewDispatcher(map[string]notifier.Notifier{notifier.ChannelSMS: sms})
+
+			Expect(subject.Deliver(ctx, event, channel, message)).To(Succeed())
+			Expect(subject.Deliver(ctx, event, channel, message)).To(Succeed())
+			Expect(sms.Calls).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,139 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+// Scheduler decides when a subscriber's message goes out. Messages held
+// back by quiet hours, a digest or a rate limit wait in the outbox until
+// Flush sends them.
+type Scheduler struct {
+	dispatcher *Dispatcher
+	outbox     outbox.Client
+	clock      func() time.Time
+}
+
+func NewScheduler(dispatcher *Dispatcher, outbox outbox.Client, clock func() time.Time) *Scheduler {
+	return &Scheduler{
+		dispatcher: dispatcher,
+		outbox:     outbox,
+		clock:      clock,
+	}
+}
+
+// Notify sends or holds the message for an event, at most once per channel
+// target when the dispatcher has a ledger
+func (scheduler *Scheduler) Notify(ctx context.Context, subscription subscriptions.Subscription, event lifecycle.Event, channel subscriptions.Channel, message Message) error {
+	key := notificationKey(event, channel)
+	return scheduler.dispatcher.once(ctx, key, func() error {
+		return scheduler.schedule(ctx, subscription, key, channel, message)
+	})
+}
+
+func (scheduler *Scheduler) schedule(ctx context.Context, subscription subscriptions.Subscription, key string, channel subscriptions.Channel, message Message) error {
+	now := scheduler.clock()
+
+	hold := func(deliverAt time.Time, reason string) error {
+		// nothing goes out during quiet hours, whatever it was held for
+		if subscription.QuietHours.Contains(deliverAt) {
+			deliverAt = subscription.QuietHours.Ends(deliverAt)
+		}
+		log.Printf("Holding %s until %s, %s\n", key, deliverAt.Format(time.RFC3339), reason)
+		return scheduler.outbox.Hold(ctx, outbox.HeldMessage{
+			NotificationID: key,
+			Channel:        channel,
+			Subject:        message.Subject,
+			Text:           message.Text,
+			Call:           message.Call,
+			Reason:         reason,
+			DeliverAt:      deliverAt,
+		})
+	}
+
+	if subscription.QuietHours.Contains(now) {
+		return hold(subscription.QuietHours.Ends(now), outbox.ReasonQuietHours)
+	}
+	if subscription.Digest != nil {
+		return hold(subscriptions.WindowEnd(now, subscription.Digest.WindowDuration()), outbox.ReasonDigest)
+	}
+	if subscription.RateLimit != nil {
+		windowEnd := subscriptions.WindowEnd(now, subscription.RateLimit.WindowDuration())
+		allowed, err := scheduler.outbox.Take(ctx, outbox.Recipient(channel), windowEnd, subscription.RateLimit.Count)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return hold(windowEnd, outbox.ReasonRateLimit)
+		}
+
+		err = scheduler.dispatcher.Send(ctx, channel, message)
+		if err != nil {
+			// a message that never went out doesn't count against the limit
+			if giveErr := scheduler.outbox.Give(ctx, outbox.Recipient(channel), windowEnd); giveErr != nil {
+				log.Printf("Error giving back %s's rate limit, %v\n", key, giveErr)
+			}
+		}
+		return err
+	}
+
+	return scheduler.dispatcher.Send(ctx, channel, message)
+}
+
+// Flush sends every held message that is due, as one digest per recipient,
+// and returns how many digests went out. Messages are only removed once
+// they were sent, so a recipient that fails gets them on the next flush.
+func (scheduler *Scheduler) Flush(ctx context.Context) (int, error) {
+	due, err := scheduler.outbox.Due(ctx, scheduler.clock())
+	if err != nil {
+		return 0, err
+	}
+
+	byRecipient := map[string][]outbox.HeldMessage{}
+	recipients := []string{}
+	for _, held := range due {
+		if _, ok := byRecipient[held.Recipient]; !ok {
+			recipients = append(recipients, held.Recipient)
+		}
+		byRecipient[held.Recipient] = append(byRecipient[held.Recipient], held)
+	}
+	sort.Strings(recipients)
+
+	sent := 0
+	errs := []error{}
+	for _, recipient := range recipients {
+		held := byRecipient[recipient]
+		sort.SliceStable(held, func(i, j int) bool {
+			return held[i].Call.CallReceived.Before(held[j].Call.CallReceived)
+		})
+
+		messages := make([]Message, 0, len(held))
+		for _, message := range held {
+			messages = append(messages, Message{Subject: message.Subject, Text: message.Text, Call: message.Call})
+		}
+
+		err := scheduler.dispatcher.Send(ctx, held[0].Channel, NewDigest(messages))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flushing %s: %w", recipient, err))
+			continue
+		}
+		sent++
+
+		for _, message := range held {
+			// a message that can't be removed is sent again, which beats losing it
+			if err := scheduler.outbox.Remove(ctx, message); err != nil {
+				log.Printf("Error removing %s: %v\n", message.NotificationID, err)
+			}
+		}
+	}
+
+	return sent, errors.Join(errs...)
+}
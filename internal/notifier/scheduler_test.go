@@ -0,0 +1,241 @@
+package notifier_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+type OutboxMock struct {
+	mock.Mock
+}
+
+func (outboxMock *OutboxMock) Hold(ctx context.Context, message outbox.HeldMessage) error {
+	args := outboxMock.Called(ctx, message)
+	return args.Error(0)
+}
+func (outboxMock *OutboxMock) Due(ctx context.Context, now time.Time) ([]outbox.HeldMessage, error) {
+	args := outboxMock.Called(ctx, now)
+	return args.Get(0).([]outbox.HeldMessage), args.Error(1)
+}
+func (outboxMock *OutboxMock) Remove(ctx context.Context, message outbox.HeldMessage) error {
+	args := outboxMock.Called(ctx, message)
+	return args.Error(0)
+}
+func (outboxMock *OutboxMock) Take(ctx context.Context, recipient string, windowEnd time.Time, limit int) (bool, error) {
+	args := outboxMock.Called(ctx, recipient, windowEnd, limit)
+	return args.Bool(0), args.Error(1)
+}
+func (outboxMock *OutboxMock) Give(ctx context.Context, recipient string, windowEnd time.Time) error {
+	args := outboxMock.Called(ctx, recipient, windowEnd)
+	return args.Error(0)
+}
+
+var _ = Describe("Scheduler", func() {
+	var ctx context.Context
+	var sms *NotifierMock
+	var outboxMock *OutboxMock
+	var subject *notifier.Scheduler
+	var subscription subscriptions.Subscription
+	var event lifecycle.Event
+	var message notifier.Message
+	var now time.Time
+	localLocation, _ := time.LoadLocation("America/New_York")
+	channel := subscriptions.Channel{Type: "sms", Target: "+18045550100"}
+	key := "police#0123#new:>dispatched#sms:+18045550100"
+
+	heldWith := func(reason string, deliverAt time.Time) func(outbox.HeldMessage) bool {
+		return func(held outbox.HeldMessage) bool {
+			return held.NotificationID == key && held.Reason == reason && held.DeliverAt.Equal(deliverAt)
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		sms = &NotifierMock{}
+		outboxMock = &OutboxMock{}
+		now = time.Date(2030, 1, 1, 12, 10, 0, 0, localLocation)
+		dispatcher := notifier.NewDispatcher(map[string]notifier.Notifier{notifier.ChannelSMS: sms})
+		subject = notifier.NewScheduler(dispatcher, outboxMock, func() time.Time { return now })
+
+		call := saved_calls.SavedCall{
+			ID:              "0123",
+			CallType:        "police",
+			CallReason:      "SUSPICIOUS SITUATION",
+			LastKnownStatus: "dispatched",
+			Location:        "22XX FAKE RD",
+		}
+		subscription = subscriptions.Subscription{
+			UserID:   "kevin",
+			Channels: []subscriptions.Channel{channel},
+		}
+		event = lifecycle.Event{Type: lifecycle.NewCall, NewCall: call}
+		var err error
+		message, err = notifier.DefaultTemplates().Render(notifier.ChannelSMS, event)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	Describe("Notify()", func() {
+		It("sends right away without any limits", func() {
+			sms.On("Send", ctx, channel.Target, message).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			Expect(sms.Calls).To(HaveLen(1))
+			Expect(outboxMock.Calls).To(BeEmpty())
+		})
+
+		It("holds a message until quiet hours end", func() {
+			subscription.QuietHours = &subscriptions.QuietHours{Start: "22:00", End: "07:00"}
+			now = time.Date(2030, 1, 1, 23, 30, 0, 0, localLocation)
+			morning := time.Date(2030, 1, 2, 7, 0, 0, 0, localLocation)
+			outboxMock.On("Hold", ctx, mock.MatchedBy(heldWith(outbox.ReasonQuietHours, morning))).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			Expect(sms.Calls).To(BeEmpty())
+			outboxMock.AssertExpectations(GinkgoT())
+		})
+
+		It("holds a message until the digest window ends", func() {
+			subscription.Digest = &subscriptions.Digest{Window: "1h"}
+			outboxMock.On("Hold", ctx, mock.MatchedBy(heldWith(outbox.ReasonDigest, time.Date(2030, 1, 1, 13, 0, 0, 0, localLocation)))).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			Expect(sms.Calls).To(BeEmpty())
+			outboxMock.AssertExpectations(GinkgoT())
+		})
+
+		It("sends while under the rate limit", func() {
+			subscription.RateLimit = &subscriptions.RateLimit{Count: 3, Window: "1h"}
+			windowEnd := time.Date(2030, 1, 1, 13, 0, 0, 0, localLocation)
+			outboxMock.On("Take", ctx, "sms:+18045550100", mock.MatchedBy(windowEnd.Equal), 3).Return(true, nil)
+			sms.On("Send", ctx, channel.Target, message).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			Expect(sms.Calls).To(HaveLen(1))
+			outboxMock.AssertNotCalled(GinkgoT(), "Give", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("gives the rate limit back when the send fails", func() {
+			subscription.RateLimit = &subscriptions.RateLimit{Count: 3, Window: "1h"}
+			windowEnd := time.Date(2030, 1, 1, 13, 0, 0, 0, localLocation)
+			sendError := errors.New("twilio is down")
+			outboxMock.On("Take", ctx, "sms:+18045550100", mock.MatchedBy(windowEnd.Equal), 3).Return(true, nil)
+			outboxMock.On("Give", ctx, "sms:+18045550100", mock.MatchedBy(windowEnd.Equal)).Return(nil)
+			sms.On("Send", ctx, channel.Target, message).Return(sendError)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(MatchError(sendError))
+			outboxMock.AssertExpectations(GinkgoT())
+		})
+
+		It("holds a message over the rate limit until the window ends", func() {
+			subscription.RateLimit = &subscriptions.RateLimit{Count: 3, Window: "1h"}
+			windowEnd := time.Date(2030, 1, 1, 13, 0, 0, 0, localLocation)
+			outboxMock.On("Take", ctx, "sms:+18045550100", mock.Anything, 3).Return(false, nil)
+			outboxMock.On("Hold", ctx, mock.MatchedBy(heldWith(outbox.ReasonRateLimit, windowEnd))).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			Expect(sms.Calls).To(BeEmpty())
+			outboxMock.AssertExpectations(GinkgoT())
+		})
+
+		It("doesn't deliver a held message during quiet hours", func() {
+			subscription.QuietHours = &subscriptions.QuietHours{Start: "22:00", End: "07:00"}
+			subscription.Digest = &subscriptions.Digest{Window: "1h"}
+			now = time.Date(2030, 1, 1, 21, 30, 0, 0, localLocation)
+			morning := time.Date(2030, 1, 2, 7, 0, 0, 0, localLocation)
+			outboxMock.On("Hold", ctx, mock.MatchedBy(heldWith(outbox.ReasonDigest, morning))).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			outboxMock.AssertExpectations(GinkgoT())
+		})
+
+		It("holds a message once when the record is retried", func() {
+			ledgerMock := &LedgerMock{}
+			ledgerMock.On("Claim", ctx, key).Return(true, nil).Once()
+			ledgerMock.On("Claim", ctx, key).Return(false, nil)
+			ledgerMock.On("Complete", ctx, key).Return(nil)
+			dispatcher := notifier.NewDispatcher(map[string]notifier.Notifier{notifier.ChannelSMS: sms}).WithLedger(ledgerMock)
+			subject = notifier.NewScheduler(dispatcher, outboxMock, func() time.Time { return now })
+			subscription.Digest = &subscriptions.Digest{Window: "1h"}
+			outboxMock.On("Hold", ctx, mock.Anything).Return(nil)
+
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			Expect(subject.Notify(ctx, subscription, event, channel, message)).To(Succeed())
+			outboxMock.AssertNumberOfCalls(GinkgoT(), "Hold", 1)
+		})
+	})
+
+	Describe("Flush()", func() {
+		held := func(recipient string, id string, received time.Time) outbox.HeldMessage {
+			target := recipient[len("sms:"):]
+			return outbox.HeldMessage{
+				Recipient:      recipient,
+				NotificationID: "police#" + id + "#new:>dispatched#" + recipient,
+				Channel:        subscriptions.Channel{Type: "sms", Target: target},
+				Subject:        "New call " + id,
+				Text:           "New call " + id,
+				Call:           saved_calls.SavedCall{ID: id, CallReceived: received},
+			}
+		}
+
+		It("sends each recipient's messages as one digest", func() {
+			first := held("sms:+18045550100", "0123", now.Add(-time.Hour))
+			second := held("sms:+18045550100", "0124", now.Add(-2*time.Hour))
+			other := held("sms:+18045550199", "0125", now)
+			outboxMock.On("Due", ctx, now).Return([]outbox.HeldMessage{first, other, second}, nil)
+			outboxMock.On("Remove", ctx, mock.Anything).Return(nil)
+			sms.On("Send", ctx, mock.Anything, mock.Anything).Return(nil)
+
+			sent, err := subject.Flush(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sent).To(Equal(2))
+			outboxMock.AssertNumberOfCalls(GinkgoT(), "Remove", 3)
+
+			digest := sms.Calls[0].Arguments.Get(2).(notifier.Message)
+			Expect(sms.Calls[0].Arguments.Get(1)).To(Equal("+18045550100"))
+			Expect(digest.Subject).To(Equal("2 call alerts"))
+			Expect(digest.Text).To(Equal("New call 0124\nNew call 0123"))
+			Expect(digest.Calls).To(HaveLen(2))
+
+			single := sms.Calls[1].Arguments.Get(2).(notifier.Message)
+			Expect(sms.Calls[1].Arguments.Get(1)).To(Equal("+18045550199"))
+			Expect(single.Subject).To(Equal("New call 0125"))
+		})
+
+		It("keeps the messages of a recipient that couldn't be sent", func() {
+			failed := held("sms:+18045550100", "0123", now)
+			other := held("sms:+18045550199", "0125", now)
+			outboxMock.On("Due", ctx, now).Return([]outbox.HeldMessage{failed, other}, nil)
+			outboxMock.On("Remove", ctx, other).Return(nil)
+			sms.On("Send", ctx, "+18045550100", mock.Anything).Return(errors.New("error!"))
+			sms.On("Send", ctx, "+18045550199", mock.Anything).Return(nil)
+
+			sent, err := subject.Flush(ctx)
+
+			Expect(err).To(MatchError("flushing sms:+18045550100: error!"))
+			Expect(sent).To(Equal(1))
+			outboxMock.AssertNotCalled(GinkgoT(), "Remove", ctx, failed)
+		})
+
+		It("doesn't send when nothing is due", func() {
+			outboxMock.On("Due", ctx, now).Return([]outbox.HeldMessage{}, nil)
+
+			sent, err := subject.Flush(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sent).To(Equal(0))
+			Expect(sms.Calls).To(BeEmpty())
+		})
+	})
+})
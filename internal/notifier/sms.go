@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/twilio/twilio-go"
+	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+// the part of the twilio api client used for sending texts
+type MessageCreator interface {
+	CreateMessage(params *openapi.CreateMessageParams) (*openapi.ApiV2010Message, error)
+}
+
+type TwilioNotifier struct {
+	api        MessageCreator
+	fromNumber string
+}
+
+func NewTwilio(accountSid string, apiKey string, apiSecret string, fromNumber string) *TwilioNotifier {
+	twilioClient := twilio.NewRestClientWithParams(twilio.ClientParams{
+		Username:   apiKey,
+		Password:   apiSecret,
+		AccountSid: accountSid,
+	})
+
+	return NewTwilioWithClient(twilioClient.Api, fromNumber)
+}
+
+func NewTwilioWithClient(api MessageCreator, fromNumber string) *TwilioNotifier {
+	return &TwilioNotifier{
+		api:        api,
+		fromNumber: fromNumber,
+	}
+}
+
+func (notifier *TwilioNotifier) Send(ctx context.Context, toNumber string, message Message) error {
+	params := &openapi.CreateMessageParams{}
+	params.SetTo(toNumber)
+	params.SetFrom(notifier.fromNumber)
+	params.SetBody(message.Text)
+
+	_, err := notifier.api.CreateMessage(params)
+	return err
+}
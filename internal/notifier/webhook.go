@@ -0,0 +1,91 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+const (
+	signatureHeader = "X-Signature-256"
+	timestampHeader = "X-Signature-Timestamp"
+)
+
+type WebhookPayload struct {
+	Message string                `json:"message"`
+	Call    saved_calls.SavedCall `json:"call"`
+	// set instead of call for a digest
+	Calls  []saved_calls.SavedCall `json:"calls,omitempty"`
+	SentAt time.Time               `json:"sentAt"`
+}
+
+// WebhookNotifier posts the call as JSON. When a secret is configured the
+// body is signed with HMAC-SHA256 over "<timestamp>.<body>", so receivers
+// can reject forged and replayed requests.
+type WebhookNotifier struct {
+	RestClient *resty.Client
+	secret     []byte
+	clock      func() time.Time
+}
+
+func NewWebhook(secret string, clock func() time.Time) *WebhookNotifier {
+	return &WebhookNotifier{
+		RestClient: resty.New().SetRetryCount(1),
+		secret:     []byte(secret),
+		clock:      clock,
+	}
+}
+
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (notifier *WebhookNotifier) Send(ctx context.Context, url string, message Message) error {
+	now := notifier.clock()
+	body, err := json.Marshal(WebhookPayload{
+		Message: message.Text,
+		Call:    message.Call,
+		Calls:   message.Calls,
+		SentAt:  now.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	request := notifier.RestClient.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(body)
+
+	if len(notifier.secret) > 0 {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		request.
+			SetHeader(timestampHeader, timestamp).
+			SetHeader(signatureHeader, Sign(notifier.secret, timestamp, body))
+	}
+
+	return checkResponse(request.Post(url))
+}
+
+func checkResponse(response *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if response.IsError() {
+		return fmt.Errorf("received invalid status code: %d", response.StatusCode())
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+const (
+	outboxTableName = "Outbox"
+	// rate limit counters share the table with held messages, under keys no
+	// notification has
+	rateKeyPrefix = "#rate#"
+	// held messages that were never flushed expire through the table's TTL
+	retention = 7 * 24 * time.Hour
+)
+
+// why a message was held
+const (
+	ReasonQuietHours = "quiet_hours"
+	ReasonDigest     = "digest"
+	ReasonRateLimit  = "rate_limit"
+)
+
+type DynamoDB interface {
+	PutItem(ctx context.Context,
+		params *dynamodb.PutItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context,
+		params *dynamodb.UpdateItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context,
+		params *dynamodb.DeleteItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context,
+		params *dynamodb.ScanInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// Client holds messages that can't be sent yet and counts what each
+// recipient was sent against their rate limit.
+type Client interface {
+	// Hold keeps a message until its DeliverAt, holding the same notification
+	// again leaves the first one as it was
+	Hold(ctx context.Context, message HeldMessage) error
+	// Due returns every held message whose DeliverAt has passed
+	Due(ctx context.Context, now time.Time) ([]HeldMessage, error)
+	Remove(ctx context.Context, message HeldMessage) error
+	// Take counts a message against the recipient's limit for the window
+	// ending at windowEnd, it reports false once the limit is used up
+	Take(ctx context.Context, recipient string, windowEnd time.Time, limit int) (bool, error)
+	// Give returns a message taken for a window that was never sent
+	Give(ctx context.Context, recipient string, windowEnd time.Time) error
+}
+
+type OutboxDataAccess struct {
+	Service DynamoDB
+}
+
+type HeldMessage struct {
+	// the channel type and target, e.g. "sms:+18045550100"
+	Recipient string `dynamodbav:"recipient"`
+	// the notification's ledger key
+	NotificationID string                `dynamodbav:"notificationId"`
+	Channel        subscriptions.Channel `dynamodbav:"channel"`
+	Subject        string                `dynamodbav:"subject,omitempty"`
+	Text           string                `dynamodbav:"text,omitempty"`
+	Call           saved_calls.SavedCall `dynamodbav:"call"`
+	Reason         string                `dynamodbav:"reason,omitempty"`
+	DeliverAt      time.Time             `dynamodbav:"-"`
+	// unix seconds, DeliverAt as it is stored
+	DeliverAtUnix int64 `dynamodbav:"deliverAt"`
+	// unix seconds, the table's TTL attribute
+	ExpiresAt int64 `dynamodbav:"expiresAt"`
+}
+
+// Recipient identifies a channel's target across subscriptions
+func Recipient(channel subscriptions.Channel) string {
+	return channel.Type + ":" + channel.Target
+}
+
+func New(config aws.Config) *OutboxDataAccess {
+	return &OutboxDataAccess{
+		Service: dynamodb.NewFromConfig(config),
+	}
+}
+
+func NewWithClient(dynamoDB DynamoDB) *OutboxDataAccess {
+	return &OutboxDataAccess{
+		Service: dynamoDB,
+	}
+}
+
+func messageKey(recipient string, notificationID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"recipient":      &types.AttributeValueMemberS{Value: recipient},
+		"notificationId": &types.AttributeValueMemberS{Value: notificationID},
+	}
+}
+
+func (dao *OutboxDataAccess) Hold(ctx context.Context, message HeldMessage) error {
+	message.Recipient = Recipient(message.Channel)
+	message.DeliverAtUnix = message.DeliverAt.Unix()
+	message.ExpiresAt = message.DeliverAt.Add(retention).Unix()
+
+	item, err := attributevalue.MarshalMap(message)
+	if err != nil {
+		return err
+	}
+
+	expr, err := expression.
+		NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("notificationId"))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(outboxTableName),
+		Item:                     item,
+		ConditionExpression:      expr.Condition(),
+		ExpressionAttributeNames: expr.Names(),
+	})
+	// a retried stream record holds the same message again
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return nil
+	}
+	return err
+}
+
+// the table only ever holds what hasn't been sent yet, so it is scanned
+// rather than indexed
+func (dao *OutboxDataAccess) Due(ctx context.Context, now time.Time) ([]HeldMessage, error) {
+	expr, err := expression.
+		NewBuilder().
+		WithFilter(expression.Name("deliverAt").LessThanEqual(expression.Value(now.Unix()))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	params := &dynamodb.ScanInput{
+		TableName:                 aws.String(outboxTableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	result := []HeldMessage{}
+
+	paginator := dynamodb.NewScanPaginator(dao.Service, params, func(spo *dynamodb.ScanPaginatorOptions) {})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		records := []HeldMessage{}
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &records)
+		if err != nil {
+			return nil, err
+		}
+		for i := range records {
+			records[i].DeliverAt = time.Unix(records[i].DeliverAtUnix, 0).UTC()
+		}
+		result = append(result, records...)
+	}
+
+	return result, nil
+}
+
+func (dao *OutboxDataAccess) Remove(ctx context.Context, message HeldMessage) error {
+	_, err := dao.Service.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(outboxTableName),
+		Key:       messageKey(Recipient(message.Channel), message.NotificationID),
+	})
+	return err
+}
+
+func rateKey(recipient string, windowEnd time.Time) map[string]types.AttributeValue {
+	return messageKey(recipient, rateKeyPrefix+strconv.FormatInt(windowEnd.Unix(), 10))
+}
+
+func (dao *OutboxDataAccess) Take(ctx context.Context, recipient string, windowEnd time.Time, limit int) (bool, error) {
+	update := expression.
+		Set(expression.Name("sent"), expression.Plus(expression.Name("sent").IfNotExists(expression.Value(0)), expression.Value(1))).
+		Set(expression.Name("expiresAt"), expression.Value(windowEnd.Add(time.Hour).Unix()))
+	condition := expression.AttributeNotExists(expression.Name("sent")).
+		Or(expression.Name("sent").LessThan(expression.Value(limit)))
+
+	expr, err := expression.
+		NewBuilder().
+		WithUpdate(update).
+		WithCondition(condition).
+		Build()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = dao.Service.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(outboxTableName),
+		Key:                       rateKey(recipient, windowEnd),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (dao *OutboxDataAccess) Give(ctx context.Context, recipient string, windowEnd time.Time) error {
+	update := expression.Set(expression.Name("sent"), expression.Minus(expression.Name("sent"), expression.Value(1)))
+	condition := expression.Name("sent").GreaterThan(expression.Value(0))
+
+	expr, err := expression.
+		NewBuilder().
+		WithUpdate(update).
+		WithCondition(condition).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(outboxTableName),
+		Key:                       rateKey(recipient, windowEnd),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	// a counter that already expired has nothing to give back
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return nil
+	}
+	return err
+}
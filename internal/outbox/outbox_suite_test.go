@@ -0,0 +1,46 @@
+package outbox_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBMock struct {
+	mock.Mock
+}
+
+func (dynamoDBMock *DynamoDBMock) PutItem(ctx context.Context, input *dynamodb.PutItemInput, options ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) UpdateItem(ctx context.Context, input *dynamodb.UpdateItemInput, options ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) DeleteItem(ctx context.Context, input *dynamodb.DeleteItemInput, options ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) Scan(ctx context.Context, input *dynamodb.ScanInput, options ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
+
+var subject *outbox.OutboxDataAccess
+var dynamoDBMock *DynamoDBMock
+
+var _ = BeforeEach(func() {
+	dynamoDBMock = new(DynamoDBMock)
+	subject = outbox.NewWithClient(dynamoDBMock)
+})
+
+func TestOutbox(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Outbox Suite")
+}
@@ -0,0 +1,151 @@
+package outbox_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+var _ = Describe("Outbox", func() {
+	var ctx context.Context
+	var held outbox.HeldMessage
+	deliverAt := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		held = outbox.HeldMessage{
+			NotificationID: "police#0123#new:>dispatched#sms:+18045550100",
+			Channel:        subscriptions.Channel{Type: "sms", Target: "+18045550100"},
+			Subject:        "New call at 22XX FAKE RD",
+			Text:           "New call at 22XX FAKE RD: SUSPICIOUS SITUATION, dispatched",
+			Call:           saved_calls.SavedCall{ID: "0123", CallType: "police"},
+			Reason:         outbox.ReasonQuietHours,
+			DeliverAt:      deliverAt,
+		}
+	})
+
+	Describe("Hold()", func() {
+		It("stores the message under its recipient", func() {
+			dynamoDBMock.On("PutItem", ctx, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+				Expect(*input.TableName).To(Equal("Outbox"))
+				Expect(input.Item["recipient"]).To(Equal(&types.AttributeValueMemberS{Value: "sms:+18045550100"}))
+				Expect(input.Item["notificationId"]).To(Equal(&types.AttributeValueMemberS{Value: held.NotificationID}))
+				Expect(input.Item["deliverAt"]).To(Equal(&types.AttributeValueMemberN{Value: "1893499200"}))
+				Expect(input.Item["expiresAt"]).To(Equal(&types.AttributeValueMemberN{Value: "1894104000"}))
+				Expect(input.Item["reason"]).To(Equal(&types.AttributeValueMemberS{Value: "quiet_hours"}))
+				Expect(*input.ConditionExpression).To(Equal("attribute_not_exists (#0)"))
+				return true
+			}), mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+			Expect(subject.Hold(ctx, held)).To(Succeed())
+		})
+
+		It("leaves a message that is already held", func() {
+			dynamoDBMock.On("PutItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			Expect(subject.Hold(ctx, held)).To(Succeed())
+		})
+	})
+
+	Describe("Due()", func() {
+		It("returns the messages that are due", func() {
+			dynamoDBMock.On("Scan", ctx, mock.MatchedBy(func(input *dynamodb.ScanInput) bool {
+				Expect(*input.TableName).To(Equal("Outbox"))
+				Expect(*input.FilterExpression).To(Equal("#0 <= :0"))
+				Expect(input.ExpressionAttributeNames["#0"]).To(Equal("deliverAt"))
+				Expect(input.ExpressionAttributeValues[":0"]).To(Equal(&types.AttributeValueMemberN{Value: "1893499500"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{{
+					"recipient":      &types.AttributeValueMemberS{Value: "sms:+18045550100"},
+					"notificationId": &types.AttributeValueMemberS{Value: held.NotificationID},
+					"channel": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+						"type":   &types.AttributeValueMemberS{Value: "sms"},
+						"target": &types.AttributeValueMemberS{Value: "+18045550100"},
+					}},
+					"text":      &types.AttributeValueMemberS{Value: held.Text},
+					"deliverAt": &types.AttributeValueMemberN{Value: "1893499200"},
+				}},
+			}, nil)
+
+			result, err := subject.Due(ctx, deliverAt.Add(5*time.Minute))
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+			Expect(result[0].Channel).To(Equal(held.Channel))
+			Expect(result[0].Text).To(Equal(held.Text))
+			Expect(result[0].DeliverAt).To(Equal(deliverAt))
+		})
+	})
+
+	Describe("Remove()", func() {
+		It("deletes by recipient and notification", func() {
+			dynamoDBMock.On("DeleteItem", ctx, mock.MatchedBy(func(input *dynamodb.DeleteItemInput) bool {
+				Expect(input.Key).To(Equal(map[string]types.AttributeValue{
+					"recipient":      &types.AttributeValueMemberS{Value: "sms:+18045550100"},
+					"notificationId": &types.AttributeValueMemberS{Value: held.NotificationID},
+				}))
+				return true
+			}), mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+			Expect(subject.Remove(ctx, held)).To(Succeed())
+		})
+	})
+
+	Describe("Take()", func() {
+		It("counts a message against the window", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				Expect(input.Key).To(Equal(map[string]types.AttributeValue{
+					"recipient":      &types.AttributeValueMemberS{Value: "sms:+18045550100"},
+					"notificationId": &types.AttributeValueMemberS{Value: "#rate#1893499200"},
+				}))
+				Expect(*input.ConditionExpression).To(Equal("(attribute_not_exists (#0)) OR (#0 < :0)"))
+				Expect(input.ExpressionAttributeNames["#0"]).To(Equal("sent"))
+				Expect(input.ExpressionAttributeValues[":0"]).To(Equal(&types.AttributeValueMemberN{Value: "3"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+			allowed, err := subject.Take(ctx, "sms:+18045550100", deliverAt, 3)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(allowed).To(BeTrue())
+		})
+
+		It("refuses once the limit is used up", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			allowed, err := subject.Take(ctx, "sms:+18045550100", deliverAt, 3)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(allowed).To(BeFalse())
+		})
+	})
+
+	Describe("Give()", func() {
+		It("takes a message back off the window's count", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				Expect(input.Key["notificationId"]).To(Equal(&types.AttributeValueMemberS{Value: "#rate#1893499200"}))
+				Expect(*input.UpdateExpression).To(Equal("SET #0 = #0 - :1\n"))
+				Expect(*input.ConditionExpression).To(Equal("#0 > :0"))
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+			Expect(subject.Give(ctx, "sms:+18045550100", deliverAt)).To(Succeed())
+		})
+
+		It("ignores a window with nothing taken", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			Expect(subject.Give(ctx, "sms:+18045550100", deliverAt)).To(Succeed())
+		})
+	})
+})
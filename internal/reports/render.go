@@ -0,0 +1,142 @@
+package reports
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+var templateFuncs = map[string]any{
+	"change": change,
+	"day": func(t time.Time) string {
+		return t.In(chesterfield.LocalTime).Format("Mon Jan 2")
+	},
+	"hour": hour,
+	"local": func(t time.Time) string {
+		return t.In(chesterfield.LocalTime).Format("3:04 PM")
+	},
+	"plural": plural,
+}
+
+var (
+	markdownTemplate = template.Must(
+		template.New("report.md.tmpl").Funcs(templateFuncs).ParseFS(templateFiles, "templates/report.md.tmpl"),
+	)
+	htmlTemplate = htmltemplate.Must(
+		htmltemplate.New("report.html.tmpl").Funcs(templateFuncs).ParseFS(templateFiles, "templates/report.html.tmpl"),
+	)
+)
+
+// change describes a count against the prior period's, e.g. "+3 (+50%)"
+func change(count int, prior int) string {
+	difference := count - prior
+	switch {
+	case difference == 0:
+		return "no change"
+	case prior == 0:
+		return fmt.Sprintf("%+d (new)", difference)
+	default:
+		percent := math.Round(float64(difference) / float64(prior) * 100)
+		return fmt.Sprintf("%+d (%+.0f%%)", difference, percent)
+	}
+}
+
+func plural(count int, one string, many string) string {
+	if count == 1 {
+		return one
+	}
+	return many
+}
+
+// hour labels a busiest hour, whose key is the 24 hour clock hour
+func hour(key string) string {
+	value, err := strconv.Atoi(key)
+	if err != nil {
+		return key
+	}
+	return time.Date(2000, 1, 1, value, 0, 0, 0, time.UTC).Format("3 PM")
+}
+
+// Dates is the day or days the report covers, e.g. "Mon Jan 6 - Sun Jan 12"
+func (report Report) Dates() string {
+	first := report.Start.In(chesterfield.LocalTime).Format("Mon Jan 2")
+	last := report.End.AddDate(0, 0, -1).In(chesterfield.LocalTime).Format("Mon Jan 2")
+	if first == last {
+		return first
+	}
+	return first + " - " + last
+}
+
+// PriorLabel is what the report is compared with
+func (report Report) PriorLabel() string {
+	if report.Period == subscriptions.ReportWeekly {
+		return "week before"
+	}
+	return "day before"
+}
+
+func (report Report) Markdown() (string, error) {
+	var rendered strings.Builder
+	if err := markdownTemplate.Execute(&rendered, report); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(rendered.String()) + "\n", nil
+}
+
+func (report Report) HTML() (string, error) {
+	var rendered strings.Builder
+	if err := htmlTemplate.Execute(&rendered, report); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(rendered.String()) + "\n", nil
+}
+
+// Summary is the report cut down to a text message
+func (report Report) Summary() string {
+	summary := fmt.Sprintf("%s, %s: %d %s, %s",
+		report.Title, report.Dates(), report.Total, plural(report.Total, "call", "calls"),
+		change(report.Total, report.PriorTotal))
+	if len(report.Reasons) > 0 {
+		summary += fmt.Sprintf(", most often %s", report.Reasons[0].Key)
+	}
+	if len(report.Notable) > 0 {
+		summary += fmt.Sprintf(", %d priority %s", len(report.Notable), NotablePriority)
+	}
+	return summary
+}
+
+// Message renders the report for a channel. Texts get the summary, email
+// gets the HTML along with the Markdown and everything else the Markdown.
+func (report Report) Message(channel string) (notifier.Message, error) {
+	message := notifier.Message{Subject: fmt.Sprintf("%s, %s", report.Title, report.Dates())}
+	if channel == notifier.ChannelSMS {
+		message.Text = report.Summary()
+		return message, nil
+	}
+
+	text, err := report.Markdown()
+	if err != nil {
+		return notifier.Message{}, err
+	}
+	message.Text = text
+
+	if channel == notifier.ChannelEmail {
+		message.HTML, err = report.HTML()
+		if err != nil {
+			return notifier.Message{}, err
+		}
+	}
+	return message, nil
+}
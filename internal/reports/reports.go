@@ -0,0 +1,355 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/ledger"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+const (
+	// the most urgent calls, each one is listed in the report
+	NotablePriority = "1"
+	maxNotable      = 10
+	maxReasons      = 10
+	busiestHours    = 3
+	// every page is read anyway, so read the largest ones
+	pageSize = 500
+)
+
+// Count is how many calls had a key in the period, and in the period before
+type Count struct {
+	Key   string
+	Count int
+	Prior int
+}
+
+// Report summarizes the calls a subscriber's streets and areas had over
+// the last day or week
+type Report struct {
+	Title  string
+	Period string
+	// the first day of the period and the day after its last, at the
+	// county's local midnight
+	Start time.Time
+	End   time.Time
+	Total int
+	// the calls in the period just before, for comparison
+	PriorTotal   int
+	Reasons      []Count
+	Priorities   []Count
+	Notable      []saved_calls.SavedCall
+	BusiestHours []Count
+}
+
+// Window is the period a report sent at now covers, the last full day or
+// the seven days up to today
+func Window(period string, now time.Time) (time.Time, time.Time) {
+	local := now.In(chesterfield.LocalTime)
+	end := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, chesterfield.LocalTime)
+	return end.AddDate(0, 0, -days(period)), end
+}
+
+// days are counted by date rather than hours, so a period that crosses a
+// daylight saving change still starts at midnight
+func days(period string) int {
+	if period == subscriptions.ReportWeekly {
+		return 7
+	}
+	return 1
+}
+
+// Title describes what a subscription is watching
+func Title(period string, subscription subscriptions.Subscription) string {
+	places := []string{}
+	for _, rule := range subscription.Filters {
+		for _, street := range rule.StreetNames {
+			places = appendNew(places, address.NormalizeStreet(street))
+		}
+		for _, area := range rule.Areas {
+			places = appendNew(places, "area "+area)
+		}
+	}
+	if subscription.Near != nil {
+		places = append(places, "nearby")
+	}
+	if len(places) == 0 {
+		places = append(places, "Chesterfield")
+	}
+
+	label := "Daily"
+	if period == subscriptions.ReportWeekly {
+		label = "Weekly"
+	}
+	return fmt.Sprintf("%s activity for %s", label, strings.Join(places, ", "))
+}
+
+func appendNew(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// queries reads a subscription's streets by the table's key when every
+// filter names streets, anything else has to scan the dates
+func queries(subscription subscriptions.Subscription, from time.Time, to time.Time) []saved_calls.CallQuery {
+	streets := []string{}
+	for _, rule := range subscription.Filters {
+		if len(rule.StreetNames) == 0 {
+			return []saved_calls.CallQuery{{From: from, To: to, Limit: pageSize}}
+		}
+		for _, street := range rule.StreetNames {
+			streets = appendNew(streets, address.NormalizeStreet(street))
+		}
+	}
+	if len(streets) == 0 {
+		return []saved_calls.CallQuery{{From: from, To: to, Limit: pageSize}}
+	}
+
+	result := make([]saved_calls.CallQuery, 0, len(streets))
+	for _, street := range streets {
+		result = append(result, saved_calls.CallQuery{StreetName: street, From: from, To: to, Limit: pageSize})
+	}
+	return result
+}
+
+// readAll reads every page of a query
+func readAll(ctx context.Context, reader saved_calls.Reader, query saved_calls.CallQuery) ([]saved_calls.SavedCall, error) {
+	calls := []saved_calls.SavedCall{}
+	for {
+		page, err := reader.FindCalls(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, page.Calls...)
+		if page.Cursor == "" {
+			return calls, nil
+		}
+		query.Cursor = page.Cursor
+	}
+}
+
+// queryCache keeps what each query read for the rest of a run, so every
+// subscriber without streets shares one scan of the period instead of each
+// scanning the table
+type queryCache struct {
+	reader saved_calls.Reader
+	calls  map[saved_calls.CallQuery][]saved_calls.SavedCall
+}
+
+func newQueryCache(reader saved_calls.Reader) *queryCache {
+	return &queryCache{reader: reader, calls: map[saved_calls.CallQuery][]saved_calls.SavedCall{}}
+}
+
+func (cache *queryCache) read(ctx context.Context, query saved_calls.CallQuery) ([]saved_calls.SavedCall, error) {
+	if calls, ok := cache.calls[query]; ok {
+		return calls, nil
+	}
+	calls, err := readAll(ctx, cache.reader, query)
+	if err != nil {
+		return nil, err
+	}
+	cache.calls[query] = calls
+	return calls, nil
+}
+
+// readCalls returns every call the subscription covers from the start of
+// the prior period to the end of this one. A call at an intersection is
+// found under each street, it is only kept once.
+func readCalls(ctx context.Context, cache *queryCache, subscription subscriptions.Subscription, priorStart time.Time, end time.Time) ([]saved_calls.SavedCall, error) {
+	seen := map[string]bool{}
+	calls := []saved_calls.SavedCall{}
+	// query dates are inclusive
+	for _, query := range queries(subscription, priorStart, end.AddDate(0, 0, -1)) {
+		read, err := cache.read(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, call := range read {
+			key := call.CallType + "#" + call.ID
+			if seen[key] || !subscription.Covers(call) {
+				continue
+			}
+			seen[key] = true
+			calls = append(calls, call)
+		}
+	}
+	return calls, nil
+}
+
+// Build reads the history a subscription's report covers and summarizes it
+func Build(ctx context.Context, reader saved_calls.Reader, subscription subscriptions.Subscription, period string, now time.Time) (Report, error) {
+	return build(ctx, newQueryCache(reader), subscription, period, now)
+}
+
+func build(ctx context.Context, cache *queryCache, subscription subscriptions.Subscription, period string, now time.Time) (Report, error) {
+	start, end := Window(period, now)
+	priorStart := start.AddDate(0, 0, -days(period))
+
+	calls, err := readCalls(ctx, cache, subscription, priorStart, end)
+	if err != nil {
+		return Report{}, err
+	}
+
+	current := []saved_calls.SavedCall{}
+	prior := []saved_calls.SavedCall{}
+	for _, call := range calls {
+		switch {
+		case call.CallReceived.Before(priorStart) || !call.CallReceived.Before(end):
+		case call.CallReceived.Before(start):
+			prior = append(prior, call)
+		default:
+			current = append(current, call)
+		}
+	}
+
+	report := Summarize(current, prior)
+	report.Title = Title(period, subscription)
+	report.Period = period
+	report.Start = start
+	report.End = end
+	return report, nil
+}
+
+// Summarize reports on the calls of a period compared with the one before
+func Summarize(calls []saved_calls.SavedCall, prior []saved_calls.SavedCall) Report {
+	report := Report{
+		Total:      len(calls),
+		PriorTotal: len(prior),
+		Reasons:    count(calls, prior, func(call saved_calls.SavedCall) string { return call.CallReason }),
+		Priorities: count(calls, prior, func(call saved_calls.SavedCall) string { return call.Priority }),
+		Notable:    []saved_calls.SavedCall{},
+	}
+
+	if len(report.Reasons) > maxReasons {
+		report.Reasons = report.Reasons[:maxReasons]
+	}
+	// priorities read best in their own order
+	sort.SliceStable(report.Priorities, func(i, j int) bool { return report.Priorities[i].Key < report.Priorities[j].Key })
+
+	for _, call := range calls {
+		if call.Priority == NotablePriority {
+			report.Notable = append(report.Notable, call)
+		}
+	}
+	sort.Slice(report.Notable, func(i, j int) bool {
+		return report.Notable[i].CallReceived.Before(report.Notable[j].CallReceived)
+	})
+	if len(report.Notable) > maxNotable {
+		report.Notable = report.Notable[:maxNotable]
+	}
+
+	hours := count(calls, nil, func(call saved_calls.SavedCall) string {
+		return call.CallReceived.In(chesterfield.LocalTime).Format("15")
+	})
+	if len(hours) > busiestHours {
+		hours = hours[:busiestHours]
+	}
+	report.BusiestHours = hours
+	return report
+}
+
+// count groups calls by key, busiest first, with how many the prior period
+// had of each
+func count(calls []saved_calls.SavedCall, prior []saved_calls.SavedCall, key func(saved_calls.SavedCall) string) []Count {
+	counts := map[string]*Count{}
+	for _, call := range calls {
+		if _, ok := counts[key(call)]; !ok {
+			counts[key(call)] = &Count{Key: key(call)}
+		}
+		counts[key(call)].Count++
+	}
+	for _, call := range prior {
+		// a key that only the prior period had isn't worth a line
+		if counted, ok := counts[key(call)]; ok {
+			counted.Prior++
+		}
+	}
+
+	result := make([]Count, 0, len(counts))
+	for _, counted := range counts {
+		result = append(result, *counted)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// Reporter sends each subscriber that asked for one their activity report
+type Reporter struct {
+	reader     saved_calls.Reader
+	dispatcher *notifier.Dispatcher
+	clock      func() time.Time
+}
+
+func New(reader saved_calls.Reader, dispatcher *notifier.Dispatcher, clock func() time.Time) *Reporter {
+	return &Reporter{
+		reader:     reader,
+		dispatcher: dispatcher,
+		clock:      clock,
+	}
+}
+
+// a report goes to a channel once per period, however often the schedule
+// fires
+func reportKey(subscription subscriptions.Subscription, report Report, channel subscriptions.Channel) string {
+	return ledger.Key(
+		"report",
+		subscription.UserID+"/"+subscription.SubscriptionID,
+		report.Period+":"+report.Start.Format("2006-01-02"),
+		outbox.Recipient(channel),
+	)
+}
+
+// Send reports to every subscription that wants a report for the period,
+// and returns how many reports were built. A subscriber that fails doesn't
+// stop the others. Each street and the scan the subscribers without streets
+// need is only read once.
+func (reporter *Reporter) Send(ctx context.Context, subscribers []subscriptions.Subscription, period string) (int, error) {
+	now := reporter.clock()
+	cache := newQueryCache(reporter.reader)
+	built := 0
+	errs := []error{}
+	for _, subscription := range subscribers {
+		if subscription.Report != period || subscription.Disabled {
+			continue
+		}
+
+		report, err := build(ctx, cache, subscription, period, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("building report for %s/%s: %w", subscription.UserID, subscription.SubscriptionID, err))
+			continue
+		}
+		built++
+		log.Printf("%s for %s/%s: %d calls\n", report.Title, subscription.UserID, subscription.SubscriptionID, report.Total)
+
+		for _, channel := range subscription.Channels {
+			message, err := report.Message(channel.Type)
+			if err == nil {
+				err = reporter.dispatcher.SendOnce(ctx, reportKey(subscription, report, channel), channel, message)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("sending report to %s/%s %s: %w", subscription.UserID, subscription.SubscriptionID, channel.Type, err))
+			}
+		}
+	}
+	return built, errors.Join(errs...)
+}
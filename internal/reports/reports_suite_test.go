@@ -0,0 +1,55 @@
+package reports_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+func TestReports(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Reports Suite")
+}
+
+type NotifierMock struct {
+	mock.Mock
+}
+
+func (notifierMock *NotifierMock) Send(ctx context.Context, target string, message notifier.Message) error {
+	args := notifierMock.Called(ctx, target, message)
+	return args.Error(0)
+}
+
+type LedgerMock struct {
+	mock.Mock
+}
+
+func (ledgerMock *LedgerMock) Claim(ctx context.Context, key string) (bool, error) {
+	args := ledgerMock.Called(ctx, key)
+	return args.Bool(0), args.Error(1)
+}
+func (ledgerMock *LedgerMock) Complete(ctx context.Context, key string) error {
+	args := ledgerMock.Called(ctx, key)
+	return args.Error(0)
+}
+func (ledgerMock *LedgerMock) Release(ctx context.Context, key string) error {
+	args := ledgerMock.Called(ctx, key)
+	return args.Error(0)
+}
+
+// countingReader counts the queries that reach the store
+type countingReader struct {
+	saved_calls.Reader
+	queries []saved_calls.CallQuery
+}
+
+func (reader *countingReader) FindCalls(ctx context.Context, query saved_calls.CallQuery) (saved_calls.CallPage, error) {
+	reader.queries = append(reader.queries, query)
+	return reader.Reader.FindCalls(ctx, query)
+}
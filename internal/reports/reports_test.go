@@ -0,0 +1,361 @@
+package reports_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/reports"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/rules"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+// go test ./internal/reports -update rewrites the golden files from what
+// the templates render now
+var updateGolden = flag.Bool("update", false, "rewrite the golden files")
+
+var _ = Describe("Reports", func() {
+	// a monday morning, the daily report is for sunday and the weekly one
+	// for the week up to it
+	now := time.Date(2030, 1, 7, 6, 0, 0, 0, chesterfield.LocalTime)
+	sunday := time.Date(2030, 1, 6, 0, 0, 0, 0, chesterfield.LocalTime)
+
+	call := func(id string, reason string, priority string, received time.Time) saved_calls.SavedCall {
+		return saved_calls.SavedCall{
+			ID:              id,
+			CallType:        "police",
+			CallReason:      reason,
+			LastKnownStatus: "dispatched",
+			Location:        "22XX FAKE RD",
+			HouseNumber:     "22XX",
+			StreetName:      "FAKE RD",
+			Area:            "11",
+			Priority:        priority,
+			CallReceived:    received,
+		}
+	}
+
+	subscription := func(report string, filters ...rules.Rule) subscriptions.Subscription {
+		return subscriptions.Subscription{
+			UserID:         "user",
+			SubscriptionID: "home",
+			Filters:        filters,
+			Channels:       []subscriptions.Channel{{Type: notifier.ChannelSMS, Target: "+18045550100"}},
+			Report:         report,
+		}
+	}
+
+	Describe("Window()", func() {
+		It("covers yesterday for a daily report", func() {
+			start, end := reports.Window(subscriptions.ReportDaily, now)
+
+			Expect(start).To(Equal(sunday))
+			Expect(end).To(Equal(sunday.AddDate(0, 0, 1)))
+		})
+
+		It("covers the last seven days for a weekly report", func() {
+			start, end := reports.Window(subscriptions.ReportWeekly, now)
+
+			Expect(start).To(Equal(time.Date(2029, 12, 31, 0, 0, 0, 0, chesterfield.LocalTime)))
+			Expect(end).To(Equal(sunday.AddDate(0, 0, 1)))
+		})
+
+		It("uses the local date", func() {
+			// still sunday in Chesterfield
+			start, _ := reports.Window(subscriptions.ReportDaily, time.Date(2030, 1, 7, 2, 0, 0, 0, time.UTC))
+
+			Expect(start).To(Equal(sunday.AddDate(0, 0, -1)))
+		})
+	})
+
+	Describe("Summarize()", func() {
+		It("counts reasons and priorities against the prior period", func() {
+			report := reports.Summarize([]saved_calls.SavedCall{
+				call("1", "LARCENY", "3", sunday.Add(9*time.Hour)),
+				call("2", "LARCENY", "3", sunday.Add(9*time.Hour)),
+				call("3", "ALARM", "2", sunday.Add(14*time.Hour)),
+			}, []saved_calls.SavedCall{
+				call("4", "LARCENY", "3", sunday.Add(-12*time.Hour)),
+				call("5", "TRAFFIC STOP", "4", sunday.Add(-12*time.Hour)),
+			})
+
+			Expect(report.Total).To(Equal(3))
+			Expect(report.PriorTotal).To(Equal(2))
+			Expect(report.Reasons).To(Equal([]reports.Count{
+				{Key: "LARCENY", Count: 2, Prior: 1},
+				{Key: "ALARM", Count: 1},
+			}))
+			Expect(report.Priorities).To(Equal([]reports.Count{
+				{Key: "2", Count: 1},
+				{Key: "3", Count: 2, Prior: 1},
+			}))
+			Expect(report.BusiestHours).To(Equal([]reports.Count{
+				{Key: "09", Count: 2},
+				{Key: "14", Count: 1},
+			}))
+		})
+
+		It("lists the priority 1 calls in order", func() {
+			report := reports.Summarize([]saved_calls.SavedCall{
+				call("1", "SHOOTING", "1", sunday.Add(20*time.Hour)),
+				call("2", "LARCENY", "3", sunday.Add(9*time.Hour)),
+				call("3", "CARDIAC", "1", sunday.Add(8*time.Hour)),
+			}, nil)
+
+			Expect(report.Notable).To(HaveLen(2))
+			Expect(report.Notable[0].ID).To(Equal("3"))
+			Expect(report.Notable[1].ID).To(Equal("1"))
+		})
+
+		It("keeps the ten most common reasons", func() {
+			calls := []saved_calls.SavedCall{}
+			for _, reason := range []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L"} {
+				calls = append(calls, call(reason, reason, "3", sunday))
+			}
+			calls = append(calls, call("L2", "L", "3", sunday))
+
+			report := reports.Summarize(calls, nil)
+
+			Expect(report.Reasons).To(HaveLen(10))
+			Expect(report.Reasons[0]).To(Equal(reports.Count{Key: "L", Count: 2}))
+		})
+	})
+
+	Describe("Build()", func() {
+		var (
+			ctx   context.Context
+			store *saved_calls.InMemoryDataAccess
+		)
+
+		BeforeEach(func() {
+			ctx = context.TODO()
+			store = saved_calls.NewInMemory(func() time.Time { return now })
+		})
+
+		save := func(calls ...saved_calls.SavedCall) {
+			for _, saved := range calls {
+				Expect(store.SaveCall(ctx, saved)).To(Succeed())
+			}
+		}
+
+		It("reads the subscribed streets for this period and the prior one", func() {
+			intersection := call("3", "ACCIDENT", "2", sunday.Add(15*time.Hour))
+			intersection.Location = "FAKE RD/MAIN ST"
+			intersection.HouseNumber = ""
+			intersection.CrossStreets = []string{"MAIN ST"}
+			elsewhere := call("5", "ALARM", "3", sunday.Add(10*time.Hour))
+			elsewhere.Location = "10XX OTHER ST"
+			elsewhere.StreetName = "OTHER ST"
+			save(
+				call("1", "LARCENY", "3", sunday.Add(9*time.Hour)),
+				call("2", "LARCENY", "3", sunday.Add(-3*time.Hour)),
+				intersection,
+				// too old for either period
+				call("4", "LARCENY", "3", sunday.AddDate(0, 0, -2)),
+				elsewhere,
+			)
+
+			report, err := reports.Build(ctx, store, subscription(subscriptions.ReportDaily, rules.Rule{StreetNames: []string{"Fake Road", "Main St"}}), subscriptions.ReportDaily, now)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Title).To(Equal("Daily activity for FAKE RD, MAIN ST"))
+			Expect(report.Start).To(Equal(sunday))
+			Expect(report.Total).To(Equal(2))
+			Expect(report.PriorTotal).To(Equal(1))
+		})
+
+		It("scans every street for areas", func() {
+			elsewhere := call("2", "ALARM", "3", sunday.Add(10*time.Hour))
+			elsewhere.Area = "12"
+			save(call("1", "LARCENY", "3", sunday.Add(9*time.Hour)), elsewhere)
+
+			report, err := reports.Build(ctx, store, subscription(subscriptions.ReportWeekly, rules.Rule{Areas: []string{"11"}}), subscriptions.ReportWeekly, now)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Title).To(Equal("Weekly activity for area 11"))
+			Expect(report.Total).To(Equal(1))
+			Expect(report.Reasons).To(Equal([]reports.Count{{Key: "LARCENY", Count: 1}}))
+		})
+	})
+
+	Describe("Message()", func() {
+		var report reports.Report
+
+		BeforeEach(func() {
+			report = reports.Summarize([]saved_calls.SavedCall{
+				call("1", "LARCENY", "3", sunday.Add(9*time.Hour)),
+				call("2", "LARCENY", "3", sunday.Add(9*time.Hour+30*time.Minute)),
+				call("3", "SHOOTING", "1", sunday.Add(22*time.Hour)),
+				call("4", "ALARM", "2", sunday.Add(14*time.Hour)),
+			}, []saved_calls.SavedCall{
+				call("5", "LARCENY", "3", sunday.AddDate(0, 0, -3)),
+				call("6", "ALARM", "2", sunday.AddDate(0, 0, -2)),
+				call("7", "ALARM", "2", sunday.AddDate(0, 0, -2)),
+			})
+			report.Title = "Weekly activity for FAKE RD"
+			report.Period = subscriptions.ReportWeekly
+			report.Start, report.End = reports.Window(subscriptions.ReportWeekly, now)
+		})
+
+		It("sends texts a summary", func() {
+			message, err := report.Message(notifier.ChannelSMS)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(message.Subject).To(Equal("Weekly activity for FAKE RD, Mon Dec 31 - Sun Jan 6"))
+			Expect(message.Text).To(Equal("Weekly activity for FAKE RD, Mon Dec 31 - Sun Jan 6: 4 calls, +1 (+33%), most often LARCENY, 1 priority 1"))
+			Expect(message.HTML).To(BeEmpty())
+		})
+
+		It("sends email the html along with the markdown", func() {
+			message, err := report.Message(notifier.ChannelEmail)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(message.Text).To(HavePrefix("# Weekly activity for FAKE RD"))
+			Expect(message.HTML).To(ContainSubstring("<h1>Weekly activity for FAKE RD</h1>"))
+		})
+
+		It("escapes the html", func() {
+			report.Notable[0].Location = "<b>22XX FAKE RD</b>"
+
+			message, err := report.Message(notifier.ChannelEmail)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(message.HTML).To(ContainSubstring("&lt;b&gt;22XX FAKE RD&lt;/b&gt;"))
+		})
+
+		It("says when nothing happened", func() {
+			empty := reports.Summarize(nil, nil)
+			empty.Title = "Daily activity for FAKE RD"
+			empty.Period = subscriptions.ReportDaily
+			empty.Start, empty.End = reports.Window(subscriptions.ReportDaily, now)
+
+			message, err := empty.Message(notifier.ChannelSlack)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(message.Text).To(Equal("# Daily activity for FAKE RD\n\nSun Jan 6: 0 calls, no change from the day before.\n"))
+		})
+
+		for name, render := range map[string]func(reports.Report) (string, error){
+			"report.md.golden":   reports.Report.Markdown,
+			"report.html.golden": reports.Report.HTML,
+		} {
+			It("renders "+name, func() {
+				rendered, err := render(report)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				path := filepath.Join("testdata", "golden", name)
+				if *updateGolden {
+					Expect(os.WriteFile(path, []byte(rendered), 0644)).To(Succeed())
+				}
+				golden, err := os.ReadFile(path)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(rendered).To(Equal(string(golden)))
+			})
+		}
+	})
+
+	Describe("Send()", func() {
+		var (
+			ctx        context.Context
+			store      *saved_calls.InMemoryDataAccess
+			sms        *NotifierMock
+			email      *NotifierMock
+			ledger     *LedgerMock
+			reporter   *reports.Reporter
+			subscriber subscriptions.Subscription
+		)
+
+		BeforeEach(func() {
+			ctx = context.TODO()
+			store = saved_calls.NewInMemory(func() time.Time { return now })
+			Expect(store.SaveCall(ctx, call("1", "LARCENY", "3", sunday.Add(9*time.Hour)))).To(Succeed())
+
+			sms = &NotifierMock{}
+			email = &NotifierMock{}
+			ledger = &LedgerMock{}
+			dispatcher := notifier.NewDispatcher(map[string]notifier.Notifier{
+				notifier.ChannelSMS:   sms,
+				notifier.ChannelEmail: email,
+			}).WithLedger(ledger)
+			reporter = reports.New(store, dispatcher, func() time.Time { return now })
+
+			subscriber = subscription(subscriptions.ReportDaily, rules.Rule{StreetNames: []string{"FAKE RD"}})
+			subscriber.Channels = append(subscriber.Channels, subscriptions.Channel{Type: notifier.ChannelEmail, Target: "someone@example.com"})
+		})
+
+		It("sends the report to every channel once", func() {
+			ledger.On("Claim", ctx, "report#user/home#daily:2030-01-06#sms:+18045550100").Return(true, nil)
+			ledger.On("Claim", ctx, "report#user/home#daily:2030-01-06#email:someone@example.com").Return(false, nil)
+			ledger.On("Complete", ctx, mock.Anything).Return(nil)
+			sms.On("Send", ctx, "+18045550100", mock.Anything).Return(nil)
+
+			built, err := reporter.Send(ctx, []subscriptions.Subscription{subscriber}, subscriptions.ReportDaily)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(built).To(Equal(1))
+			sms.AssertExpectations(GinkgoT())
+			email.AssertNotCalled(GinkgoT(), "Send", mock.Anything, mock.Anything, mock.Anything)
+			message := sms.Calls[0].Arguments.Get(2).(notifier.Message)
+			Expect(message.Text).To(HavePrefix("Daily activity for FAKE RD, Sun Jan 6: 1 call"))
+		})
+
+		It("skips subscriptions without the period's report", func() {
+			weekly := subscriber
+			weekly.Report = subscriptions.ReportWeekly
+			none := subscriber
+			none.Report = ""
+
+			built, err := reporter.Send(ctx, []subscriptions.Subscription{weekly, none}, subscriptions.ReportDaily)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(built).To(Equal(0))
+			ledger.AssertNotCalled(GinkgoT(), "Claim", mock.Anything, mock.Anything)
+		})
+
+		It("scans once for every subscriber without streets", func() {
+			ledger.On("Claim", ctx, mock.Anything).Return(false, nil)
+			reader := &countingReader{Reader: store}
+			reporter = reports.New(reader, notifier.NewDispatcher(map[string]notifier.Notifier{
+				notifier.ChannelSMS:   sms,
+				notifier.ChannelEmail: email,
+			}).WithLedger(ledger), func() time.Time { return now })
+			area := subscription(subscriptions.ReportDaily, rules.Rule{Areas: []string{"11"}})
+			otherArea := subscription(subscriptions.ReportDaily, rules.Rule{Areas: []string{"12"}})
+			otherArea.SubscriptionID = "work"
+
+			built, err := reporter.Send(ctx, []subscriptions.Subscription{area, otherArea, subscriber}, subscriptions.ReportDaily)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(built).To(Equal(3))
+			Expect(reader.queries).To(HaveLen(2))
+			Expect(reader.queries[0].StreetName).To(BeEmpty())
+			Expect(reader.queries[1].StreetName).To(Equal("FAKE RD"))
+		})
+
+		It("keeps sending after a channel fails", func() {
+			ledger.On("Claim", ctx, mock.Anything).Return(true, nil)
+			ledger.On("Complete", ctx, mock.Anything).Return(nil)
+			ledger.On("Release", ctx, mock.Anything).Return(nil)
+			sms.On("Send", ctx, mock.Anything, mock.Anything).Return(errors.New("sms is down"))
+			email.On("Send", ctx, "someone@example.com", mock.Anything).Return(nil)
+
+			built, err := reporter.Send(ctx, []subscriptions.Subscription{subscriber}, subscriptions.ReportDaily)
+
+			Expect(err).To(MatchError(ContainSubstring("sms is down")))
+			Expect(built).To(Equal(1))
+			email.AssertExpectations(GinkgoT())
+			message := email.Calls[0].Arguments.Get(2).(notifier.Message)
+			Expect(message.HTML).To(ContainSubstring("LARCENY"))
+		})
+	})
+})
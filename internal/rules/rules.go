@@ -0,0 +1,234 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/address"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+// A Rule matches a change to a saved call. Every criteria that is set must
+// match, criteria that are left empty match anything.
+type Rule struct {
+	Name              string            `json:"name" dynamodbav:"name,omitempty"`
+	StreetNames       []string          `json:"streetNames,omitempty" dynamodbav:"streetNames,omitempty"`
+	HouseNumbers      *HouseNumberRange `json:"houseNumbers,omitempty" dynamodbav:"houseNumbers,omitempty"`
+	CallTypes         []string          `json:"callTypes,omitempty" dynamodbav:"callTypes,omitempty"`
+	CallReasonPattern string            `json:"callReasonPattern,omitempty" dynamodbav:"callReasonPattern,omitempty"`
+	Priorities        []string          `json:"priorities,omitempty" dynamodbav:"priorities,omitempty"`
+	Areas             []string          `json:"areas,omitempty" dynamodbav:"areas,omitempty"`
+	Transitions       []Transition      `json:"transitions,omitempty" dynamodbav:"transitions,omitempty"`
+	Events            []string          `json:"events,omitempty" dynamodbav:"events,omitempty"`
+
+	callReason *regexp.Regexp
+}
+
+// an inclusive range of house numbers, compared against the hundred-block
+// the county publishes, e.g. "22XX" covers 2200 through 2299
+type HouseNumberRange struct {
+	From int `json:"from" dynamodbav:"from"`
+	To   int `json:"to" dynamodbav:"to"`
+}
+
+// a status change, an empty status matches any status
+type Transition struct {
+	From string `json:"from,omitempty" dynamodbav:"from,omitempty"`
+	To   string `json:"to,omitempty" dynamodbav:"to,omitempty"`
+}
+
+type RuleSet []Rule
+
+type ruleFile struct {
+	Rules RuleSet `json:"rules"`
+}
+
+func Parse(data []byte) (RuleSet, error) {
+	var file ruleFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(file.Rules)
+}
+
+// Compile validates rules that were decoded from somewhere other than a
+// rules file and prepares their patterns for matching.
+func Compile(rules RuleSet) (RuleSet, error) {
+	for i := range rules {
+		err := rules[i].compile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func Load(path string) (RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func (rule *Rule) compile() error {
+	if rule.CallReasonPattern != "" {
+		pattern, err := regexp.Compile("(?i)" + rule.CallReasonPattern)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		rule.callReason = pattern
+	}
+	for _, eventType := range rule.Events {
+		if !slices.Contains(lifecycle.EventTypes, lifecycle.EventType(eventType)) {
+			return fmt.Errorf("rule %q: unknown event %q", rule.Name, eventType)
+		}
+	}
+	if rule.HouseNumbers != nil && rule.HouseNumbers.From > rule.HouseNumbers.To {
+		return fmt.Errorf("rule %q: house number range %d-%d is reversed", rule.Name, rule.HouseNumbers.From, rule.HouseNumbers.To)
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// street names are compared normalized, so a rule for "Fake Road" matches
+// calls on "FAKE RD", and a call at an intersection matches either street
+func matchesStreet(streetNames []string, call saved_calls.SavedCall) bool {
+	if len(streetNames) == 0 {
+		return true
+	}
+	callStreets := append([]string{address.NormalizeStreet(call.StreetName)}, call.CrossStreets...)
+	// calls saved before cross streets were stored only have them in the
+	// location
+	if crossStreet := address.Parse(call.Location).CrossStreet; crossStreet != "" {
+		callStreets = append(callStreets, crossStreet)
+	}
+	for _, candidate := range streetNames {
+		if slices.Contains(callStreets, address.NormalizeStreet(candidate)) {
+			return true
+		}
+	}
+	return false
+}
+
+// converts a masked house number such as "22XX" into the range of
+// addresses it covers
+func houseNumberBlock(houseNumber string) (int, int, bool) {
+	digits := strings.TrimRight(strings.ToUpper(houseNumber), "X")
+	masked := len(houseNumber) - len(digits)
+	if digits == "" {
+		return 0, 0, false
+	}
+
+	low, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, 0, false
+	}
+	width := 1
+	for i := 0; i < masked; i++ {
+		low *= 10
+		width *= 10
+	}
+	return low, low + width - 1, true
+}
+
+func (houseNumbers *HouseNumberRange) matches(houseNumber string) bool {
+	if houseNumbers == nil {
+		return true
+	}
+	low, high, ok := houseNumberBlock(houseNumber)
+	if !ok {
+		return false
+	}
+	return low <= houseNumbers.To && high >= houseNumbers.From
+}
+
+func (transition Transition) matches(oldStatus string, newStatus string) bool {
+	return (transition.From == "" || strings.EqualFold(transition.From, oldStatus)) &&
+		(transition.To == "" || strings.EqualFold(transition.To, newStatus))
+}
+
+// Matches reports whether a lifecycle event satisfies the rule.
+func (rule *Rule) Matches(event lifecycle.Event) bool {
+	oldCall, newCall := event.OldCall, event.NewCall
+
+	if !containsFold(rule.Events, string(event.Type)) {
+		return false
+	}
+
+	if len(rule.Transitions) > 0 {
+		matched := false
+		for _, transition := range rule.Transitions {
+			if transition.matches(oldCall.LastKnownStatus, newCall.LastKnownStatus) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return matchesStreet(rule.StreetNames, newCall) &&
+		rule.HouseNumbers.matches(newCall.HouseNumber) &&
+		containsFold(rule.CallTypes, newCall.CallType) &&
+		(rule.callReason == nil || rule.callReason.MatchString(newCall.CallReason)) &&
+		containsFold(rule.Priorities, newCall.Priority) &&
+		containsFold(rule.Areas, newCall.Area)
+}
+
+// Covers reports whether the call happened where the rule is watching, its
+// streets, house numbers and areas. The other criteria are left out.
+func (rule *Rule) Covers(call saved_calls.SavedCall) bool {
+	return matchesStreet(rule.StreetNames, call) &&
+		rule.HouseNumbers.matches(call.HouseNumber) &&
+		containsFold(rule.Areas, call.Area)
+}
+
+// Covers reports whether any rule covers the call's location. An empty rule
+// set covers everywhere.
+func (rules RuleSet) Covers(call saved_calls.SavedCall) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	for i := range rules {
+		if rules[i].Covers(call) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns every rule that matches the event. An empty rule set
+// matches every event.
+func (rules RuleSet) Match(event lifecycle.Event) []Rule {
+	if len(rules) == 0 {
+		return []Rule{{Name: "default"}}
+	}
+
+	var matched []Rule
+	for i := range rules {
+		if rules[i].Matches(event) {
+			matched = append(matched, rules[i])
+		}
+	}
+	return matched
+}
@@ -0,0 +1,193 @@
+package rules_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/rules"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+var _ = Describe("Rules", func() {
+	var oldCall saved_calls.SavedCall
+	var newCall saved_calls.SavedCall
+	var eventType lifecycle.EventType
+
+	event := func() lifecycle.Event {
+		return lifecycle.Event{Type: eventType, OldCall: oldCall, NewCall: newCall}
+	}
+
+	BeforeEach(func() {
+		oldCall = saved_calls.SavedCall{}
+		eventType = lifecycle.NewCall
+		newCall = saved_calls.SavedCall{
+			ID:              "0123",
+			CallType:        "police",
+			CallReason:      "BURGLARY IN PROGRESS",
+			LastKnownStatus: "dispatched",
+			Area:            "11",
+			Priority:        "2",
+			HouseNumber:     "22XX",
+			StreetName:      "FAKE RD",
+		}
+	})
+
+	Describe("Load()", func() {
+		It("reads rules from a file", func() {
+			ruleSet, err := rules.Load("testdata/rules.json")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(ruleSet)).To(Equal(2))
+			Expect(ruleSet[0].Name).To(Equal("home"))
+			Expect(ruleSet[0].HouseNumbers).To(Equal(&rules.HouseNumberRange{From: 2100, To: 2350}))
+			Expect(ruleSet[1].Transitions).To(Equal([]rules.Transition{{To: "dispatched"}}))
+		})
+
+		It("rejects invalid patterns", func() {
+			_, err := rules.Parse([]byte(`{"rules": [{"name": "bad", "callReasonPattern": "("}]}`))
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(`rule "bad"`))
+		})
+
+		It("rejects unknown events", func() {
+			_, err := rules.Parse([]byte(`{"rules": [{"name": "bad", "events": ["arrived"]}]}`))
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`rule "bad": unknown event "arrived"`))
+		})
+
+		It("rejects reversed house number ranges", func() {
+			_, err := rules.Parse([]byte(`{"rules": [{"name": "bad", "houseNumbers": {"from": 500, "to": 100}}]}`))
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`rule "bad": house number range 500-100 is reversed`))
+		})
+	})
+
+	Describe("Matches()", func() {
+		It("matches any event with no criteria", func() {
+			rule := rules.Rule{}
+
+			Expect(rule.Matches(event())).To(BeTrue())
+		})
+
+		It("matches either street of an intersection", func() {
+			newCall.HouseNumber = ""
+			newCall.StreetName = "HULL ST RD"
+			newCall.Location = "HULL ST RD AT COALFIELD RD"
+
+			Expect((&rules.Rule{StreetNames: []string{"HULL STREET ROAD"}}).Matches(event())).To(BeTrue())
+			Expect((&rules.Rule{StreetNames: []string{"COALFIELD RD"}}).Matches(event())).To(BeTrue())
+			Expect((&rules.Rule{StreetNames: []string{"FAKE RD"}}).Matches(event())).To(BeFalse())
+		})
+
+		It("matches a stored cross street", func() {
+			newCall.CrossStreets = []string{"COALFIELD RD"}
+
+			Expect((&rules.Rule{StreetNames: []string{"Coalfield Road"}}).Matches(event())).To(BeTrue())
+		})
+
+		DescribeTable("criteria",
+			func(ruleJson string, expected bool) {
+				ruleSet, err := rules.Parse([]byte(`{"rules": [` + ruleJson + `]}`))
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(ruleSet[0].Matches(event())).To(Equal(expected))
+			},
+			Entry("street name", `{"streetNames": ["fake rd"]}`, true),
+			Entry("other street name", `{"streetNames": ["EXAMPLE CT"]}`, false),
+			Entry("spelled out street name", `{"streetNames": ["Fake Road"]}`, true),
+			Entry("house number range inside the block", `{"houseNumbers": {"from": 2250, "to": 2260}}`, true),
+			Entry("house number range overlapping the block", `{"houseNumbers": {"from": 2000, "to": 2200}}`, true),
+			Entry("house number range outside the block", `{"houseNumbers": {"from": 2300, "to": 2400}}`, false),
+			Entry("call type", `{"callTypes": ["police"]}`, true),
+			Entry("other call type", `{"callTypes": ["fire"]}`, false),
+			Entry("call reason pattern", `{"callReasonPattern": "burglary"}`, true),
+			Entry("other call reason pattern", `{"callReasonPattern": "^EMS"}`, false),
+			Entry("priority", `{"priorities": ["1", "2"]}`, true),
+			Entry("other priority", `{"priorities": ["1"]}`, false),
+			Entry("area", `{"areas": ["11"]}`, true),
+			Entry("other area", `{"areas": ["60"]}`, false),
+			Entry("transition to status", `{"transitions": [{"to": "Dispatched"}]}`, true),
+			Entry("transition from status", `{"transitions": [{"from": "dispatched"}]}`, false),
+			Entry("event", `{"events": ["new", "reopened"]}`, true),
+			Entry("other event", `{"events": ["resolved"]}`, false),
+		)
+
+		It("requires a house number when a range is set", func() {
+			newCall.HouseNumber = ""
+			rule := rules.Rule{HouseNumbers: &rules.HouseNumberRange{From: 0, To: 99999}}
+
+			Expect(rule.Matches(event())).To(BeFalse())
+		})
+
+		It("handles wide masks", func() {
+			newCall.HouseNumber = "123XX"
+			rule := rules.Rule{HouseNumbers: &rules.HouseNumberRange{From: 12350, To: 12350}}
+
+			Expect(rule.Matches(event())).To(BeTrue())
+		})
+	})
+
+	Describe("Match()", func() {
+		It("returns every matching rule", func() {
+			ruleSet, err := rules.Load("testdata/rules.json")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			matched := ruleSet.Match(event())
+
+			Expect(len(matched)).To(Equal(2))
+		})
+
+		It("only returns matching rules", func() {
+			ruleSet, err := rules.Load("testdata/rules.json")
+			Expect(err).ShouldNot(HaveOccurred())
+			oldCall = newCall
+			newCall.LastKnownStatus = "on scene"
+			eventType = lifecycle.OnScene
+
+			matched := ruleSet.Match(event())
+
+			Expect(len(matched)).To(Equal(1))
+			Expect(matched[0].Name).To(Equal("home"))
+		})
+
+		It("matches every event without rules", func() {
+			for _, eventType = range lifecycle.EventTypes {
+				Expect(rules.RuleSet{}.Match(event())).To(HaveLen(1))
+			}
+		})
+	})
+
+	Describe("Covers()", func() {
+		It("only looks at where the call is", func() {
+			ruleSet, err := rules.Compile(rules.RuleSet{{
+				Name:              "home",
+				StreetNames:       []string{"Fake Road"},
+				HouseNumbers:      &rules.HouseNumberRange{From: 2200, To: 2300},
+				CallTypes:         []string{"fire"},
+				CallReasonPattern: "FIRE",
+				Events:            []string{"resolved"},
+			}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(ruleSet.Covers(newCall)).To(BeTrue())
+			newCall.HouseNumber = "99XX"
+			Expect(ruleSet.Covers(newCall)).To(BeFalse())
+		})
+
+		It("covers an area", func() {
+			ruleSet := rules.RuleSet{{Name: "area", Areas: []string{"12"}}, {Name: "street", StreetNames: []string{"EXAMPLE CT"}}}
+
+			Expect(ruleSet.Covers(newCall)).To(BeFalse())
+			newCall.Area = "12"
+			Expect(ruleSet.Covers(newCall)).To(BeTrue())
+		})
+
+		It("covers everywhere without rules", func() {
+			Expect(rules.RuleSet{}.Covers(newCall)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,116 @@
+package saved_calls
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// BackfillStreets moves every call saved under a street name that locate
+// would now give it, e.g. one saved before street names were normalized,
+// and returns how many calls moved. The call is saved under its new key
+// before the old item is deleted, so running it again after a failure picks
+// up where it stopped.
+func (dao *SavedCallDataAccess) BackfillStreets(ctx context.Context, locate func(SavedCall) SavedCall) (int, error) {
+	moved := 0
+	paginator := dynamodb.NewScanPaginator(dao.Service, &dynamodb.ScanInput{
+		TableName: aws.String(savedCallsTableName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return moved, err
+		}
+
+		calls := []SavedCall{}
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &calls)
+		if err != nil {
+			return moved, err
+		}
+
+		for _, call := range dropAliases(calls) {
+			located := locate(call)
+			if located.StreetName == "" || located.StreetName == call.StreetName {
+				continue
+			}
+			log.Printf("Moving call %s from %q to %q\n", call.ID, call.StreetName, located.StreetName)
+			err = dao.moveCall(ctx, call, located)
+			if err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func (dao *SavedCallDataAccess) moveCall(ctx context.Context, from SavedCall, to SavedCall) error {
+	to.Moved = true
+	err := dao.SaveCall(ctx, to)
+	// already saved by a run that stopped before the delete
+	if err != nil && !errors.Is(err, ErrConflict) {
+		return err
+	}
+
+	// marked first so the delete's stream record isn't taken for the call
+	// ending
+	err = dao.setMoved(ctx, from, true)
+	if err != nil {
+		return err
+	}
+	key, err := callKey(from)
+	if err != nil {
+		return err
+	}
+	_, err = dao.Service.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(savedCallsTableName),
+		Key:       key,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the mark only has to cover the insert, the call's own removal later
+	// on is still news
+	normalizeCall(&to)
+	return dao.setMoved(ctx, to, false)
+}
+
+// setMoved marks or unmarks a call if it still exists, it doesn't change the
+// call's status so the stream record it causes isn't an event either
+func (dao *SavedCallDataAccess) setMoved(ctx context.Context, call SavedCall, moved bool) error {
+	update := expression.Remove(expression.Name("moved"))
+	if moved {
+		update = expression.Set(expression.Name("moved"), expression.Value(true))
+	}
+	expr, err := expression.
+		NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.AttributeExists(expression.Name("sortKey"))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	key, err := callKey(call)
+	if err != nil {
+		return err
+	}
+	_, err = dao.Service.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(savedCallsTableName),
+		Key:                       key,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+	if errors.Is(conditionFailed(err), ErrConflict) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package saved_calls_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+var _ = Describe("BackfillStreets()", func() {
+	ctx := context.TODO()
+	var oldCall saved_calls.SavedCall
+
+	// stands in for the harvester's parsing, the old name is the county's
+	// raw location
+	locate := func(call saved_calls.SavedCall) saved_calls.SavedCall {
+		if call.Location == "22XX FAKE ROAD" {
+			call.StreetName = "FAKE RD"
+		}
+		return call
+	}
+
+	scanReturns := func(calls ...saved_calls.SavedCall) {
+		items := []map[string]types.AttributeValue{}
+		for _, call := range calls {
+			item, err := attributevalue.MarshalMap(call)
+			Expect(err).ShouldNot(HaveOccurred())
+			items = append(items, item)
+		}
+		dynamoDBMock.On("Scan", ctx, mock.Anything, mock.Anything).Return(&dynamodb.ScanOutput{Items: items}, nil)
+	}
+
+	BeforeEach(func() {
+		oldCall = saved_calls.SavedCall{
+			SortKey:         "2022/03/23#0123#police",
+			ID:              "0123",
+			CallType:        "police",
+			LastKnownStatus: "dispatched",
+			CallReceived:    time.Date(2022, 3, 23, 23, 22, 39, 0, localLocation),
+			Location:        "22XX FAKE ROAD",
+			HouseNumber:     "22XX",
+			StreetName:      "FAKE ROAD",
+			Version:         3,
+		}
+	})
+
+	It("moves a call to its new street and removes the old item", func() {
+		alreadyMoved := oldCall
+		alreadyMoved.ID = "0456"
+		alreadyMoved.Location = "22XX OTHER RD"
+		alreadyMoved.StreetName = "OTHER RD"
+		scanReturns(oldCall, alreadyMoved)
+
+		oldKey := map[string]types.AttributeValue{
+			"streetName": &types.AttributeValueMemberS{Value: "FAKE ROAD"},
+			"sortKey":    &types.AttributeValueMemberS{Value: "2022/03/23#0123#police"},
+		}
+		dynamoDBMock.On("PutItem", ctx, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+			Expect(input.Item["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "FAKE RD"}))
+			Expect(input.Item["moved"]).To(Equal(&types.AttributeValueMemberBOOL{Value: true}))
+			return true
+		}), mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		newKey := map[string]types.AttributeValue{
+			"streetName": &types.AttributeValueMemberS{Value: "FAKE RD"},
+			"sortKey":    &types.AttributeValueMemberS{Value: "2022/03/23#0123#police"},
+		}
+		dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return reflect.DeepEqual(input.Key, oldKey)
+		}), mock.Anything).Run(func(args mock.Arguments) {
+			input := args.Get(1).(*dynamodb.UpdateItemInput)
+			Expect(*input.UpdateExpression).To(Equal("SET #1 = :0\n"))
+			Expect(input.ExpressionAttributeNames["#1"]).To(Equal("moved"))
+			dynamoDBMock.AssertNotCalled(GinkgoT(), "DeleteItem", mock.Anything, mock.Anything, mock.Anything)
+		}).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return reflect.DeepEqual(input.Key, newKey)
+		}), mock.Anything).Run(func(args mock.Arguments) {
+			input := args.Get(1).(*dynamodb.UpdateItemInput)
+			Expect(*input.UpdateExpression).To(Equal("REMOVE #1\n"))
+			Expect(input.ExpressionAttributeNames["#1"]).To(Equal("moved"))
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "DeleteItem", 1)
+		}).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBMock.On("DeleteItem", ctx, mock.MatchedBy(func(input *dynamodb.DeleteItemInput) bool {
+			Expect(*input.TableName).To(Equal("SavedCalls"))
+			Expect(input.Key).To(Equal(oldKey))
+			return true
+		}), mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+		moved, err := subject.BackfillStreets(ctx, locate)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(moved).To(Equal(1))
+		dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "PutItem", 1)
+		dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "DeleteItem", 1)
+		dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "UpdateItem", 2)
+	})
+
+	It("leaves cross street copies to their call", func() {
+		oldCall.PrimaryStreet = "HULL ST RD"
+		scanReturns(oldCall)
+
+		moved, err := subject.BackfillStreets(ctx, locate)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(moved).To(Equal(0))
+		dynamoDBMock.AssertNotCalled(GinkgoT(), "PutItem", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	It("removes the old item of a call a stopped run already saved", func() {
+		scanReturns(oldCall)
+		dynamoDBMock.On("PutItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+		dynamoDBMock.On("UpdateItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBMock.On("DeleteItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+		moved, err := subject.BackfillStreets(ctx, locate)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(moved).To(Equal(1))
+		dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "DeleteItem", 1)
+		dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "UpdateItem", 2)
+	})
+
+	It("keeps the old item when the new one can't be saved", func() {
+		unexpectedError := errors.New("error!")
+		scanReturns(oldCall)
+		dynamoDBMock.On("PutItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, unexpectedError)
+
+		moved, err := subject.BackfillStreets(ctx, locate)
+
+		Expect(err).To(MatchError(unexpectedError))
+		Expect(moved).To(Equal(0))
+		dynamoDBMock.AssertNotCalled(GinkgoT(), "DeleteItem", mock.Anything, mock.Anything, mock.Anything)
+	})
+})
@@ -0,0 +1,299 @@
+package saved_calls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// the most items dynamo takes in one transaction
+	batchSize = 25
+	// attempts at writing a batch that lost a race or was throttled
+	batchAttempts = 5
+	batchBackoff  = 25 * time.Millisecond
+)
+
+// BatchWriter saves and updates many calls at once. Calls that couldn't be
+// written are reported through a *BatchError, everything else was written.
+type BatchWriter interface {
+	SaveCalls(ctx context.Context, activeCalls []SavedCall) error
+	UpdateStatuses(ctx context.Context, activeCalls []SavedCall) error
+}
+
+type WriteFailure struct {
+	Call SavedCall
+	Err  error
+}
+
+type BatchError struct {
+	Failures []WriteFailure
+}
+
+func (batchError *BatchError) Error() string {
+	messages := make([]string, 0, len(batchError.Failures))
+	for _, failure := range batchError.Failures {
+		messages = append(messages, fmt.Sprintf("call %s: %v", failure.Call.ID, failure.Err))
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (batchError *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(batchError.Failures))
+	for _, failure := range batchError.Failures {
+		errs = append(errs, failure.Err)
+	}
+	return errs
+}
+
+func (batchError *BatchError) add(call SavedCall, err error) {
+	batchError.Failures = append(batchError.Failures, WriteFailure{Call: call, Err: err})
+}
+
+func (batchError *BatchError) orNil() error {
+	if len(batchError.Failures) == 0 {
+		return nil
+	}
+	return batchError
+}
+
+// writeEach is the batch write for the local stores, which have no batches
+// of their own
+func writeEach(activeCalls []SavedCall, write func(SavedCall) error) error {
+	batchError := &BatchError{}
+	for _, call := range activeCalls {
+		err := write(call)
+		if err != nil {
+			batchError.add(call, err)
+		}
+	}
+	return batchError.orNil()
+}
+
+// a batch of calls along with the dynamo items written for them, a call and
+// its aliases always end up in the same batch
+type callBatch struct {
+	calls [][]SavedCall
+	items int
+	// dynamo rejects a transaction that writes the same key twice
+	keys map[string]bool
+}
+
+func batchCalls(activeCalls []SavedCall) []callBatch {
+	batches := []callBatch{}
+	current := callBatch{keys: map[string]bool{}}
+	for _, call := range activeCalls {
+		items := append([]SavedCall{call}, aliasCalls(call)...)
+		keys := make([]string, 0, len(items))
+		duplicate := false
+		for _, item := range items {
+			normalizeCall(&item)
+			keys = append(keys, itemKey(item))
+			duplicate = duplicate || current.keys[itemKey(item)]
+		}
+		if (current.items+len(items) > batchSize || duplicate) && current.items > 0 {
+			batches = append(batches, current)
+			current = callBatch{keys: map[string]bool{}}
+		}
+		current.calls = append(current.calls, items)
+		current.items += len(items)
+		for _, key := range keys {
+			current.keys[key] = true
+		}
+	}
+	if current.items > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// new calls are put in transactions rather than with BatchWriteItem, which
+// can't make a put conditional. Every item in a transaction costs 2 WCU.
+func (dao *SavedCallDataAccess) SaveCalls(ctx context.Context, activeCalls []SavedCall) error {
+	return dao.writeTransactions(ctx, activeCalls, saveItems)
+}
+
+func (dao *SavedCallDataAccess) UpdateStatuses(ctx context.Context, activeCalls []SavedCall) error {
+	return dao.writeTransactions(ctx, activeCalls, dao.statusItems)
+}
+
+// the same puts SaveCall makes one at a time, as transaction items
+func saveItems(activeCall SavedCall) ([]types.TransactWriteItem, error) {
+	puts, err := saveInputs(activeCall)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]types.TransactWriteItem, 0, len(puts))
+	for _, put := range puts {
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName:                 put.TableName,
+				Item:                      put.Item,
+				ConditionExpression:       put.ConditionExpression,
+				ExpressionAttributeNames:  put.ExpressionAttributeNames,
+				ExpressionAttributeValues: put.ExpressionAttributeValues,
+			},
+		})
+	}
+	return items, nil
+}
+
+// the same updates UpdateStatus makes one at a time, as transaction items
+func (dao *SavedCallDataAccess) statusItems(activeCall SavedCall) ([]types.TransactWriteItem, error) {
+	updates, err := dao.statusInputs(activeCall)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]types.TransactWriteItem, 0, len(updates))
+	for _, update := range updates {
+		items = append(items, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                 update.TableName,
+				Key:                       update.Key,
+				ConditionExpression:       update.ConditionExpression,
+				ExpressionAttributeNames:  update.ExpressionAttributeNames,
+				ExpressionAttributeValues: update.ExpressionAttributeValues,
+				UpdateExpression:          update.UpdateExpression,
+			},
+		})
+	}
+	return items, nil
+}
+
+func (dao *SavedCallDataAccess) writeTransactions(ctx context.Context, activeCalls []SavedCall, items func(SavedCall) ([]types.TransactWriteItem, error)) error {
+	batchError := &BatchError{}
+	for _, batch := range batchCalls(activeCalls) {
+		dao.writeTransaction(ctx, batch, items, batchError)
+	}
+	return batchError.orNil()
+}
+
+// a call and the transaction items written for it
+type pendingCall struct {
+	call  SavedCall
+	items []types.TransactWriteItem
+}
+
+func (dao *SavedCallDataAccess) writeTransaction(ctx context.Context, batch callBatch, items func(SavedCall) ([]types.TransactWriteItem, error), batchError *BatchError) {
+	pending := []pendingCall{}
+	for _, group := range batch.calls {
+		callItems, err := items(group[0])
+		if err != nil {
+			batchError.add(group[0], err)
+			continue
+		}
+		pending = append(pending, pendingCall{call: group[0], items: callItems})
+	}
+
+	delay := batchBackoff
+	for attempt := 1; len(pending) > 0; {
+		transactItems := []types.TransactWriteItem{}
+		for _, call := range pending {
+			transactItems = append(transactItems, call.items...)
+		}
+		_, err := dao.Service.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
+		})
+		if err == nil {
+			return
+		}
+
+		// a call another write got to first fails the whole transaction, it's
+		// left out, along with any missing alias, and the rest are tried again
+		// straight away
+		if remaining, conflicted := dropConflicts(pending, err, batchError); conflicted {
+			pending = remaining
+			continue
+		}
+
+		if attempt == batchAttempts || !retryableCancellation(err) {
+			failPending(pending, err, batchError)
+			return
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			failPending(pending, err, batchError)
+			return
+		}
+		attempt++
+		delay *= 2
+	}
+}
+
+func failPending(pending []pendingCall, err error, batchError *BatchError) {
+	for _, call := range pending {
+		batchError.add(call.call, err)
+	}
+}
+
+// dropConflicts reports the calls whose condition failed as ErrConflict and
+// returns the rest. An alias that no longer exists is left out of its call
+// rather than failing it. The cancellation reasons line up with the
+// transaction's items.
+func dropConflicts(pending []pendingCall, err error, batchError *BatchError) ([]pendingCall, bool) {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return pending, false
+	}
+
+	remaining := []pendingCall{}
+	changed := false
+	index := 0
+	for _, call := range pending {
+		conflict := false
+		kept := []types.TransactWriteItem{}
+		for i, item := range call.items {
+			failed := index < len(canceled.CancellationReasons) && aws.ToString(canceled.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+			index++
+			switch {
+			case failed && i == 0:
+				conflict = true
+			case failed:
+				changed = true
+			default:
+				kept = append(kept, item)
+			}
+		}
+		if conflict {
+			changed = true
+			batchError.add(call.call, ErrConflict)
+			continue
+		}
+		call.items = kept
+		remaining = append(remaining, call)
+	}
+	return remaining, changed
+}
+
+// a transaction that only lost a race with another write, or was throttled,
+// is worth trying again
+func retryableCancellation(err error) bool {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	for _, reason := range canceled.CancellationReasons {
+		switch aws.ToString(reason.Code) {
+		case "", "None", "TransactionConflict", "ThrottlingError":
+		default:
+			return false
+		}
+	}
+	return true
+}
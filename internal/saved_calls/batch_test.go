@@ -0,0 +1,248 @@
+package saved_calls_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+var _ = Describe("Batch Writes", func() {
+	ctx := context.TODO()
+
+	newCalls := func(count int) []saved_calls.SavedCall {
+		calls := []saved_calls.SavedCall{}
+		for i := 0; i < count; i++ {
+			calls = append(calls, saved_calls.SavedCall{
+				ID:              fmt.Sprintf("%04d", i),
+				CallType:        "police",
+				LastKnownStatus: "Dispatched",
+				CallReceived:    time.Date(2022, 3, 23, 23, 22, 39, 0, localLocation),
+				StreetName:      "FAKE RD",
+			})
+		}
+		return calls
+	}
+
+	conditionFailed := func(codes ...string) error {
+		reasons := []types.CancellationReason{}
+		for _, code := range codes {
+			reasons = append(reasons, types.CancellationReason{Code: aws.String(code)})
+		}
+		return &types.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	Describe("SaveCalls()", func() {
+		It("puts in transactions of 25", func() {
+			sizes := []int{}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				sizes = append(sizes, len(input.TransactItems))
+				first := input.TransactItems[0].Put
+				Expect(*first.TableName).To(Equal("SavedCalls"))
+				Expect(*first.ConditionExpression).To(Equal("attribute_not_exists (#0)"))
+				Expect(first.Item["lastKnownStatus"]).To(Equal(&types.AttributeValueMemberS{Value: "dispatched"}))
+				Expect(first.Item["isActive"]).To(Equal(&types.AttributeValueMemberS{Value: "-"}))
+				Expect(first.Item["version"]).To(Equal(&types.AttributeValueMemberN{Value: "1"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+			err := subject.SaveCalls(ctx, newCalls(30))
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sizes).To(Equal([]int{25, 5}))
+		})
+
+		It("keeps a call and its cross street copies in one transaction", func() {
+			calls := newCalls(25)
+			calls[24].CrossStreets = []string{"COALFIELD RD"}
+
+			sizes := []int{}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				sizes = append(sizes, len(input.TransactItems))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+			err := subject.SaveCalls(ctx, calls)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sizes).To(Equal([]int{24, 2}))
+		})
+
+		It("never writes the same key twice in one transaction", func() {
+			calls := newCalls(3)
+			calls[2] = calls[0]
+
+			sizes := []int{}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				sizes = append(sizes, len(input.TransactItems))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+			err := subject.SaveCalls(ctx, calls)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sizes).To(Equal([]int{2, 1}))
+		})
+
+		It("copies a call once for a cross street listed twice", func() {
+			calls := newCalls(1)
+			calls[0].CrossStreets = []string{"COALFIELD RD", "COALFIELD RD", "FAKE RD"}
+
+			sizes := []int{}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				sizes = append(sizes, len(input.TransactItems))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+			err := subject.SaveCalls(ctx, calls)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sizes).To(Equal([]int{2}))
+		})
+
+		It("reports calls another harvest already saved as conflicts and saves the rest", func() {
+			calls := newCalls(3)
+			calls[1].CrossStreets = []string{"COALFIELD RD"}
+
+			// items are the first call, the second and its copy, then the third
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				return len(input.TransactItems) == 4
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, conditionFailed("None", "ConditionalCheckFailed", "None", "None")).Once()
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				Expect(input.TransactItems).To(HaveLen(2))
+				Expect(input.TransactItems[0].Put.Item["id"]).To(Equal(&types.AttributeValueMemberS{Value: "0000"}))
+				Expect(input.TransactItems[1].Put.Item["id"]).To(Equal(&types.AttributeValueMemberS{Value: "0002"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+
+			err := subject.SaveCalls(ctx, calls)
+
+			var batchError *saved_calls.BatchError
+			Expect(errors.As(err, &batchError)).To(BeTrue())
+			Expect(batchError.Failures).To(HaveLen(1))
+			Expect(batchError.Failures[0].Call.ID).To(Equal("0001"))
+			Expect(err).To(MatchError(saved_calls.ErrConflict))
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "TransactWriteItems", 2)
+		})
+
+		It("fails every call in a transaction dynamo rejects", func() {
+			unexpectedError := errors.New("error!")
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.Anything, mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, unexpectedError)
+
+			err := subject.SaveCalls(ctx, newCalls(2))
+
+			var batchError *saved_calls.BatchError
+			Expect(errors.As(err, &batchError)).To(BeTrue())
+			Expect(batchError.Failures).To(HaveLen(2))
+			Expect(err).To(MatchError(unexpectedError))
+		})
+	})
+
+	Describe("UpdateStatuses()", func() {
+		It("updates in transactions of 25", func() {
+			calls := newCalls(30)
+			for i := range calls {
+				calls[i].LastKnownStatus = "On Scene"
+			}
+
+			sizes := []int{}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				sizes = append(sizes, len(input.TransactItems))
+				update := input.TransactItems[0].Update
+				Expect(*update.TableName).To(Equal("SavedCalls"))
+				Expect(*update.UpdateExpression).To(Equal("SET #1 = :0, #2 = :1, #3 = if_not_exists(#3, :2) + :3\n"))
+				Expect(*update.ConditionExpression).To(Equal("attribute_exists (#0)"))
+				Expect(update.Key["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "FAKE RD"}))
+				Expect(update.ExpressionAttributeValues[":1"]).To(Equal(&types.AttributeValueMemberS{Value: "2030-01-01T06:30:00Z"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+			err := subject.UpdateStatuses(ctx, calls)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sizes).To(Equal([]int{25, 5}))
+		})
+
+		It("retries a transaction that lost a race", func() {
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.Anything, mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, conditionFailed("None", "TransactionConflict")).Once()
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.Anything, mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+
+			err := subject.UpdateStatuses(ctx, newCalls(2))
+
+			Expect(err).ShouldNot(HaveOccurred())
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "TransactWriteItems", 2)
+		})
+
+		It("fails the calls of a transaction that can't succeed", func() {
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.Anything, mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, conditionFailed("ValidationError"))
+
+			err := subject.UpdateStatuses(ctx, newCalls(2))
+
+			var batchError *saved_calls.BatchError
+			Expect(errors.As(err, &batchError)).To(BeTrue())
+			Expect(batchError.Failures).To(HaveLen(2))
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "TransactWriteItems", 1)
+		})
+
+		It("reports calls whose status already moved on as conflicts", func() {
+			calls := newCalls(2)
+			for i := range calls {
+				calls[i].LastKnownStatus = "Resolved"
+				calls[i].ExpectedStatus = "Dispatched"
+			}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.Anything, mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, conditionFailed("ConditionalCheckFailed", "None")).Once()
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				return len(input.TransactItems) == 1
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+
+			err := subject.UpdateStatuses(ctx, calls)
+
+			var batchError *saved_calls.BatchError
+			Expect(errors.As(err, &batchError)).To(BeTrue())
+			Expect(batchError.Failures).To(HaveLen(1))
+			Expect(batchError.Failures[0].Call.ID).To(Equal("0000"))
+			Expect(err).To(MatchError(saved_calls.ErrConflict))
+		})
+
+		It("leaves out a cross street copy that no longer exists", func() {
+			calls := newCalls(1)
+			calls[0].LastKnownStatus = "On Scene"
+			calls[0].CrossStreets = []string{"COALFIELD RD"}
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				return len(input.TransactItems) == 2
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, conditionFailed("None", "ConditionalCheckFailed")).Once()
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				Expect(input.TransactItems).To(HaveLen(1))
+				Expect(input.TransactItems[0].Update.Key["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "FAKE RD"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil).Once()
+
+			err := subject.UpdateStatuses(ctx, calls)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "TransactWriteItems", 2)
+		})
+
+		It("fails calls with an unknown status without sending them", func() {
+			calls := newCalls(2)
+			calls[0].LastKnownStatus = "enroute"
+			dynamoDBMock.On("TransactWriteItems", ctx, mock.MatchedBy(func(input *dynamodb.TransactWriteItemsInput) bool {
+				return len(input.TransactItems) == 1
+			}), mock.Anything).Return(&dynamodb.TransactWriteItemsOutput{}, nil)
+
+			err := subject.UpdateStatuses(ctx, calls)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("call 0000: unknown status: enroute"))
+		})
+	})
+})
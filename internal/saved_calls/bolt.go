@@ -0,0 +1,172 @@
+package saved_calls
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var savedCallsBucket = []byte(savedCallsTableName)
+
+// BoltDataAccess stores saved calls in a local BoltDB file so the whole
+// pipeline can run on a laptop. The caller owns the database handle, which
+// lets other stores share the same file.
+type BoltDataAccess struct {
+	db    *bolt.DB
+	clock func() time.Time
+}
+
+func NewBolt(db *bolt.DB, clock func() time.Time) (*BoltDataAccess, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(savedCallsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltDataAccess{
+		db:    db,
+		clock: clock,
+	}, nil
+}
+
+func (dao *BoltDataAccess) allCalls() ([]SavedCall, error) {
+	calls := []SavedCall{}
+	err := dao.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(savedCallsBucket).ForEach(func(key []byte, value []byte) error {
+			var call SavedCall
+			err := json.Unmarshal(value, &call)
+			if err != nil {
+				return err
+			}
+			calls = append(calls, call)
+			return nil
+		})
+	})
+	return calls, err
+}
+
+func (dao *BoltDataAccess) GetActiveCalls(ctx context.Context) ([]SavedCall, error) {
+	calls, err := dao.allCalls()
+	if err != nil {
+		return nil, err
+	}
+
+	return dropAliases(filterActiveCalls(calls)), nil
+}
+
+func (dao *BoltDataAccess) FindCalls(ctx context.Context, query CallQuery) (CallPage, error) {
+	calls, err := dao.allCalls()
+	if err != nil {
+		return CallPage{}, err
+	}
+
+	return findCalls(calls, query)
+}
+
+func (dao *BoltDataAccess) GetCall(ctx context.Context, id string) (SavedCall, error) {
+	calls, err := dao.allCalls()
+	if err != nil {
+		return SavedCall{}, err
+	}
+
+	return getCall(calls, id)
+}
+
+func putCall(bucket *bolt.Bucket, savedCall SavedCall) error {
+	value, err := json.Marshal(savedCall)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(itemKey(savedCall)), value)
+}
+
+func (dao *BoltDataAccess) SaveCall(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+	activeCall.Version = 1
+
+	return dao.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(savedCallsBucket)
+		if bucket.Get([]byte(itemKey(activeCall))) != nil {
+			return ErrConflict
+		}
+		for _, call := range append([]SavedCall{activeCall}, aliasCalls(activeCall)...) {
+			err := putCall(bucket, call)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (dao *BoltDataAccess) UpdateStatus(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+
+	return dao.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(savedCallsBucket)
+
+		for i, call := range append([]SavedCall{activeCall}, aliasCalls(activeCall)...) {
+			var existingCall SavedCall
+			value := bucket.Get([]byte(itemKey(call)))
+			if value != nil {
+				err := json.Unmarshal(value, &existingCall)
+				if err != nil {
+					return err
+				}
+			}
+
+			updatedCall, err := applyStatusUpdate(existingCall, call, dao.clock())
+			if err != nil {
+				return err
+			}
+			// only the call itself is checked, the aliases go with it if they
+			// exist
+			if i == 0 {
+				if err := checkStatusUpdate(existingCall, value != nil, call); err != nil {
+					return err
+				}
+			} else if value == nil {
+				continue
+			}
+			err = putCall(bucket, updatedCall)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (dao *BoltDataAccess) UpdateMissedHarvests(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+
+	return dao.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(savedCallsBucket)
+
+		value := bucket.Get([]byte(itemKey(activeCall)))
+		if value == nil {
+			return nil
+		}
+
+		var existingCall SavedCall
+		err := json.Unmarshal(value, &existingCall)
+		if err != nil {
+			return err
+		}
+
+		existingCall.MissedHarvests = activeCall.MissedHarvests
+		return putCall(bucket, existingCall)
+	})
+}
+
+func (dao *BoltDataAccess) SaveCalls(ctx context.Context, activeCalls []SavedCall) error {
+	return writeEach(activeCalls, func(call SavedCall) error { return dao.SaveCall(ctx, call) })
+}
+
+func (dao *BoltDataAccess) UpdateStatuses(ctx context.Context, activeCalls []SavedCall) error {
+	return writeEach(activeCalls, func(call SavedCall) error { return dao.UpdateStatus(ctx, call) })
+}
@@ -0,0 +1,60 @@
+package saved_calls
+
+// A call at an intersection is saved once under its first street and again
+// under each cross street, so history for either street finds it. The copies
+// are aliases that point back at the primary item through PrimaryStreet,
+// and are left out wherever a call should only be seen once.
+
+// IsAlias reports whether the item is a cross street copy of a call
+func (savedCall SavedCall) IsAlias() bool {
+	return savedCall.PrimaryStreet != ""
+}
+
+func aliasCalls(savedCall SavedCall) []SavedCall {
+	aliases := []SavedCall{}
+	seen := map[string]bool{savedCall.StreetName: true}
+	for _, crossStreet := range savedCall.CrossStreets {
+		// a street listed twice would write the same key twice
+		if crossStreet == "" || seen[crossStreet] {
+			continue
+		}
+		seen[crossStreet] = true
+		alias := savedCall
+		alias.StreetName = crossStreet
+		alias.PrimaryStreet = savedCall.StreetName
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+func dropAliases(calls []SavedCall) []SavedCall {
+	result := []SavedCall{}
+	for _, call := range calls {
+		if !call.IsAlias() {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+// primaryView shows an alias as the call it copies
+func primaryView(savedCall SavedCall) SavedCall {
+	if savedCall.IsAlias() {
+		savedCall.StreetName = savedCall.PrimaryStreet
+		savedCall.PrimaryStreet = ""
+	}
+	return savedCall
+}
+
+// presentCalls prepares a page of history. Aliases are how a street query
+// finds calls at its intersections, anywhere else they'd be duplicates.
+func presentCalls(query CallQuery, calls []SavedCall) []SavedCall {
+	if query.StreetName == "" {
+		calls = dropAliases(calls)
+	}
+	result := make([]SavedCall, 0, len(calls))
+	for _, call := range calls {
+		result = append(result, primaryView(call))
+	}
+	return result
+}
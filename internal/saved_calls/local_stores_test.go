@@ -0,0 +1,287 @@
+package saved_calls_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+type localStore interface {
+	saved_calls.Client
+	saved_calls.Reader
+}
+
+func describeLocalStore(name string, newStore func() localStore) {
+	Describe(name, func() {
+		var ctx context.Context
+		var store localStore
+		var call saved_calls.SavedCall
+
+		BeforeEach(func() {
+			ctx = context.TODO()
+			store = newStore()
+			call = saved_calls.SavedCall{
+				ID:              "0123",
+				CallType:        "police",
+				CallReason:      "SUSPICIOUS SITUATION",
+				LastKnownStatus: "Dispatched",
+				CallReceived:    time.Date(2022, 3, 23, 23, 22, 39, 0, localLocation),
+				Location:        "22XX FAKE RD",
+				Area:            "11",
+				Priority:        "3",
+				HouseNumber:     "22XX",
+				StreetName:      "FAKE RD",
+			}
+		})
+
+		It("returns saved calls as active", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			result, err := store.GetActiveCalls(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].SortKey).To(Equal("2022/03/23#0123#police"))
+			Expect(result[0].LastKnownStatus).To(Equal("dispatched"))
+			Expect(result[0].IsActive).To(Equal("-"))
+			Expect(result[0].CallReceived.Equal(call.CallReceived)).To(BeTrue())
+			Expect(result[0].StreetName).To(Equal("FAKE RD"))
+		})
+
+		It("orders active calls by time received", func() {
+			laterCall := call
+			laterCall.ID = "0124"
+			laterCall.StreetName = "EXAMPLE CT"
+			laterCall.CallReceived = call.CallReceived.Add(time.Hour)
+
+			Expect(store.SaveCall(ctx, laterCall)).To(Succeed())
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			result, err := store.GetActiveCalls(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(2))
+			Expect(result[0].ID).To(Equal("0123"))
+			Expect(result[1].ID).To(Equal("0124"))
+		})
+
+		It("sets arrival time", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			call.LastKnownStatus = "On Scene"
+			Expect(store.UpdateStatus(ctx, call)).To(Succeed())
+
+			result, err := store.GetActiveCalls(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].LastKnownStatus).To(Equal("on scene"))
+			Expect(result[0].CallArrival.Equal(currentTime)).To(BeTrue())
+			Expect(result[0].CallReason).To(Equal("SUSPICIOUS SITUATION"))
+		})
+
+		It("drops resolved calls from the active set", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			call.LastKnownStatus = "resolved"
+			Expect(store.UpdateStatus(ctx, call)).To(Succeed())
+
+			result, err := store.GetActiveCalls(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(BeEmpty())
+		})
+
+		It("finds calls newest first across pages", func() {
+			for i, streetName := range []string{"FAKE RD", "EXAMPLE CT", "FAKE RD"} {
+				saved := call
+				saved.ID = fmt.Sprintf("01%d", i)
+				saved.StreetName = streetName
+				saved.CallReceived = call.CallReceived.AddDate(0, 0, i)
+				Expect(store.SaveCall(ctx, saved)).To(Succeed())
+			}
+
+			first, err := store.FindCalls(ctx, saved_calls.CallQuery{StreetName: "FAKE RD", Limit: 1})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(first.Calls)).To(Equal(1))
+			Expect(first.Calls[0].ID).To(Equal("012"))
+			Expect(first.Cursor).ToNot(BeEmpty())
+
+			second, err := store.FindCalls(ctx, saved_calls.CallQuery{StreetName: "FAKE RD", Limit: 1, Cursor: first.Cursor})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(second.Calls)).To(Equal(1))
+			Expect(second.Calls[0].ID).To(Equal("010"))
+
+			ranged, err := store.FindCalls(ctx, saved_calls.CallQuery{
+				From: call.CallReceived.AddDate(0, 0, 1),
+				To:   call.CallReceived.AddDate(0, 0, 1),
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(ranged.Calls)).To(Equal(1))
+			Expect(ranged.Calls[0].StreetName).To(Equal("EXAMPLE CT"))
+			Expect(ranged.Cursor).To(BeEmpty())
+		})
+
+		It("gets a call by id", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			result, err := store.GetCall(ctx, "0123")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.StreetName).To(Equal("FAKE RD"))
+
+			_, err = store.GetCall(ctx, "9999")
+			Expect(err).To(Equal(saved_calls.ErrNotFound))
+		})
+
+		It("finds intersection calls under either street once", func() {
+			call.Location = "HULL ST RD AT COALFIELD RD"
+			call.HouseNumber = ""
+			call.StreetName = "HULL ST RD"
+			call.CrossStreets = []string{"COALFIELD RD"}
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			active, err := store.GetActiveCalls(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(active)).To(Equal(1))
+			Expect(active[0].StreetName).To(Equal("HULL ST RD"))
+
+			for _, streetName := range []string{"HULL ST RD", "COALFIELD RD"} {
+				page, err := store.FindCalls(ctx, saved_calls.CallQuery{StreetName: streetName})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(len(page.Calls)).To(Equal(1))
+				Expect(page.Calls[0].StreetName).To(Equal("HULL ST RD"))
+				Expect(page.Calls[0].IsAlias()).To(BeFalse())
+			}
+
+			all, err := store.FindCalls(ctx, saved_calls.CallQuery{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(all.Calls)).To(Equal(1))
+
+			call.LastKnownStatus = "resolved"
+			Expect(store.UpdateStatus(ctx, call)).To(Succeed())
+
+			crossStreet, err := store.FindCalls(ctx, saved_calls.CallQuery{StreetName: "COALFIELD RD"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(crossStreet.Calls[0].LastKnownStatus).To(Equal("resolved"))
+		})
+
+		It("tracks missed harvests", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			call.MissedHarvests = 1
+			Expect(store.UpdateMissedHarvests(ctx, call)).To(Succeed())
+
+			result, err := store.GetActiveCalls(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result[0].MissedHarvests).To(Equal(1))
+			Expect(result[0].LastKnownStatus).To(Equal("dispatched"))
+
+			unsaved := call
+			unsaved.ID = "9999"
+			Expect(store.UpdateMissedHarvests(ctx, unsaved)).To(Succeed())
+			result, err = store.GetActiveCalls(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+		})
+
+		It("saves and updates calls in batches", func() {
+			otherCall := call
+			otherCall.ID = "0124"
+			Expect(store.SaveCalls(ctx, []saved_calls.SavedCall{call, otherCall})).To(Succeed())
+
+			call.LastKnownStatus = "resolved"
+			otherCall.LastKnownStatus = "enroute"
+			err := store.UpdateStatuses(ctx, []saved_calls.SavedCall{call, otherCall})
+
+			var batchError *saved_calls.BatchError
+			Expect(errors.As(err, &batchError)).To(BeTrue())
+			Expect(batchError.Failures).To(HaveLen(1))
+			Expect(batchError.Failures[0].Call.ID).To(Equal("0124"))
+
+			result, err := store.GetActiveCalls(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].ID).To(Equal("0124"))
+		})
+
+		It("only saves a new call once", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			duplicate := call
+			duplicate.LastKnownStatus = "On Scene"
+			Expect(store.SaveCall(ctx, duplicate)).To(MatchError(saved_calls.ErrConflict))
+
+			result, err := store.GetActiveCalls(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+			Expect(result[0].LastKnownStatus).To(Equal("dispatched"))
+			Expect(result[0].Version).To(Equal(1))
+		})
+
+		It("only updates a call that is still as it was read", func() {
+			Expect(store.SaveCall(ctx, call)).To(Succeed())
+
+			// two harvests read the call while it was dispatched
+			first := call
+			first.LastKnownStatus = "On Scene"
+			first.ExpectedStatus = "Dispatched"
+			first.Version = 1
+			second := first
+			second.LastKnownStatus = "resolved"
+
+			Expect(store.UpdateStatus(ctx, first)).To(Succeed())
+			Expect(store.UpdateStatus(ctx, second)).To(MatchError(saved_calls.ErrConflict))
+
+			result, err := store.GetActiveCalls(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+			Expect(result[0].LastKnownStatus).To(Equal("on scene"))
+			Expect(result[0].Version).To(Equal(2))
+		})
+
+		It("doesn't update a call that was never saved", func() {
+			call.LastKnownStatus = "resolved"
+
+			Expect(store.UpdateStatus(ctx, call)).To(MatchError(saved_calls.ErrConflict))
+
+			result, err := store.FindCalls(ctx, saved_calls.CallQuery{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.Calls).To(BeEmpty())
+		})
+
+		It("rejects unknown statuses", func() {
+			call.LastKnownStatus = "enroute"
+
+			err := store.UpdateStatus(ctx, call)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("unknown status: enroute"))
+		})
+	})
+}
+
+var _ = Describe("Local Stores", func() {
+	clock := func() time.Time { return currentTime }
+
+	describeLocalStore("InMemoryDataAccess", func() localStore {
+		return saved_calls.NewInMemory(clock)
+	})
+
+	describeLocalStore("BoltDataAccess", func() localStore {
+		db, err := bolt.Open(filepath.Join(GinkgoT().TempDir(), "calls.db"), 0600, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(db.Close)
+
+		store, err := saved_calls.NewBolt(db, clock)
+		Expect(err).ShouldNot(HaveOccurred())
+		return store
+	})
+})
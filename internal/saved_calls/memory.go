@@ -0,0 +1,116 @@
+package saved_calls
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryDataAccess keeps saved calls in a map, for offline tests and for
+// running the harvester without a table. Nothing survives a restart.
+type InMemoryDataAccess struct {
+	mutex sync.Mutex
+	calls map[string]SavedCall
+	clock func() time.Time
+}
+
+func NewInMemory(clock func() time.Time) *InMemoryDataAccess {
+	return &InMemoryDataAccess{
+		calls: map[string]SavedCall{},
+		clock: clock,
+	}
+}
+
+func itemKey(savedCall SavedCall) string {
+	return savedCall.StreetName + "\x00" + savedCall.SortKey
+}
+
+func (dao *InMemoryDataAccess) allCalls() []SavedCall {
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	calls := make([]SavedCall, 0, len(dao.calls))
+	for _, call := range dao.calls {
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+func (dao *InMemoryDataAccess) GetActiveCalls(ctx context.Context) ([]SavedCall, error) {
+	return dropAliases(filterActiveCalls(dao.allCalls())), nil
+}
+
+func (dao *InMemoryDataAccess) FindCalls(ctx context.Context, query CallQuery) (CallPage, error) {
+	return findCalls(dao.allCalls(), query)
+}
+
+func (dao *InMemoryDataAccess) GetCall(ctx context.Context, id string) (SavedCall, error) {
+	return getCall(dao.allCalls(), id)
+}
+
+func (dao *InMemoryDataAccess) SaveCall(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+
+	activeCall.Version = 1
+
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	if _, ok := dao.calls[itemKey(activeCall)]; ok {
+		return ErrConflict
+	}
+	for _, call := range append([]SavedCall{activeCall}, aliasCalls(activeCall)...) {
+		dao.calls[itemKey(call)] = call
+	}
+	return nil
+}
+
+func (dao *InMemoryDataAccess) UpdateStatus(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	for i, call := range append([]SavedCall{activeCall}, aliasCalls(activeCall)...) {
+		key := itemKey(call)
+		existingCall, ok := dao.calls[key]
+		updatedCall, err := applyStatusUpdate(existingCall, call, dao.clock())
+		if err != nil {
+			return err
+		}
+		// only the call itself is checked, the aliases go with it if they exist
+		if i == 0 {
+			if err := checkStatusUpdate(existingCall, ok, call); err != nil {
+				return err
+			}
+		} else if !ok {
+			continue
+		}
+		dao.calls[key] = updatedCall
+	}
+	return nil
+}
+
+func (dao *InMemoryDataAccess) UpdateMissedHarvests(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	key := itemKey(activeCall)
+	existingCall, ok := dao.calls[key]
+	if !ok {
+		return nil
+	}
+	existingCall.MissedHarvests = activeCall.MissedHarvests
+	dao.calls[key] = existingCall
+	return nil
+}
+
+func (dao *InMemoryDataAccess) SaveCalls(ctx context.Context, activeCalls []SavedCall) error {
+	return writeEach(activeCalls, func(call SavedCall) error { return dao.SaveCall(ctx, call) })
+}
+
+func (dao *InMemoryDataAccess) UpdateStatuses(ctx context.Context, activeCalls []SavedCall) error {
+	return writeEach(activeCalls, func(call SavedCall) error { return dao.UpdateStatus(ctx, call) })
+}
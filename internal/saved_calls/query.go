@@ -0,0 +1,354 @@
+package saved_calls
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	sortKeyDate     = "2006/01/02"
+)
+
+var ErrNotFound = errors.New("call not found")
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CallQuery filters call history. Every field is optional, but only a
+// query with a street name can use the table's key, anything else has to
+// scan the table.
+type CallQuery struct {
+	StreetName string
+	// calendar days in the county's local time, the same dates used as the
+	// sort key prefix, both ends inclusive
+	From     time.Time
+	To       time.Time
+	CallType string
+	ID       string
+	Limit    int32
+	Cursor   string
+}
+
+// CallPage is one page of results, pass Cursor back to get the next one.
+// A page may hold fewer calls than the limit even when more remain, since
+// filters are applied after the limit is read.
+type CallPage struct {
+	Calls  []SavedCall `json:"calls"`
+	Cursor string      `json:"cursor,omitempty"`
+}
+
+type Reader interface {
+	GetActiveCalls(ctx context.Context) ([]SavedCall, error)
+	FindCalls(ctx context.Context, query CallQuery) (CallPage, error)
+	GetCall(ctx context.Context, id string) (SavedCall, error)
+}
+
+func (query CallQuery) pageSize() int32 {
+	switch {
+	case query.Limit <= 0:
+		return defaultPageSize
+	case query.Limit > maxPageSize:
+		return maxPageSize
+	default:
+		return query.Limit
+	}
+}
+
+func (query CallQuery) sortKeyRange() (string, string) {
+	var from, to string
+	if !query.From.IsZero() {
+		from = query.From.In(chesterfield.LocalTime).Format(sortKeyDate)
+	}
+	if !query.To.IsZero() {
+		// "~" sorts after the "#" that follows the date
+		to = query.To.In(chesterfield.LocalTime).Format(sortKeyDate) + "~"
+	}
+	return from, to
+}
+
+// cursors are the last evaluated key, which only holds string attributes
+func encodeCursor(lastEvaluatedKey map[string]types.AttributeValue) (string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return "", nil
+	}
+	key := map[string]string{}
+	err := attributevalue.UnmarshalMap(lastEvaluatedKey, &key)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursorKey(cursor string) (map[string]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	key := map[string]string{}
+	err = json.Unmarshal(data, &key)
+	if err != nil || key["streetName"] == "" || key["sortKey"] == "" {
+		return nil, ErrInvalidCursor
+	}
+	return key, nil
+}
+
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	key, err := decodeCursorKey(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return attributevalue.MarshalMap(key)
+}
+
+func (query CallQuery) filterCondition() (expression.ConditionBuilder, bool) {
+	var conditions []expression.ConditionBuilder
+	if query.CallType != "" {
+		conditions = append(conditions, expression.Name("callType").Equal(expression.Value(query.CallType)))
+	}
+	if query.ID != "" {
+		conditions = append(conditions, expression.Name("id").Equal(expression.Value(query.ID)))
+	}
+	if query.StreetName == "" {
+		from, to := query.sortKeyRange()
+		if from != "" {
+			conditions = append(conditions, expression.Name("sortKey").GreaterThanEqual(expression.Value(from)))
+		}
+		if to != "" {
+			conditions = append(conditions, expression.Name("sortKey").LessThanEqual(expression.Value(to)))
+		}
+	}
+
+	switch len(conditions) {
+	case 0:
+		return expression.ConditionBuilder{}, false
+	case 1:
+		return conditions[0], true
+	default:
+		return expression.And(conditions[0], conditions[1], conditions[2:]...), true
+	}
+}
+
+func (query CallQuery) keyCondition() expression.KeyConditionBuilder {
+	keyCondition := expression.Key("streetName").Equal(expression.Value(query.StreetName))
+	from, to := query.sortKeyRange()
+	switch {
+	case from != "" && to != "":
+		keyCondition = keyCondition.And(expression.Key("sortKey").Between(expression.Value(from), expression.Value(to)))
+	case from != "":
+		keyCondition = keyCondition.And(expression.Key("sortKey").GreaterThanEqual(expression.Value(from)))
+	case to != "":
+		keyCondition = keyCondition.And(expression.Key("sortKey").LessThanEqual(expression.Value(to)))
+	}
+	return keyCondition
+}
+
+func (dao *SavedCallDataAccess) FindCalls(ctx context.Context, query CallQuery) (CallPage, error) {
+	startKey, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return CallPage{}, err
+	}
+
+	builder := expression.NewBuilder()
+	filter, hasFilter := query.filterCondition()
+	if hasFilter {
+		builder = builder.WithFilter(filter)
+	}
+	if query.StreetName != "" {
+		builder = builder.WithKeyCondition(query.keyCondition())
+	}
+
+	var items []map[string]types.AttributeValue
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	if query.StreetName != "" {
+		expr, err := builder.Build()
+		if err != nil {
+			return CallPage{}, err
+		}
+		output, err := dao.Service.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(savedCallsTableName),
+			KeyConditionExpression:    expr.KeyCondition(),
+			FilterExpression:          expr.Filter(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ExclusiveStartKey:         startKey,
+			Limit:                     aws.Int32(query.pageSize()),
+			ScanIndexForward:          aws.Bool(false),
+		})
+		if err != nil {
+			return CallPage{}, err
+		}
+		items, lastEvaluatedKey = output.Items, output.LastEvaluatedKey
+	} else {
+		input := &dynamodb.ScanInput{
+			TableName:         aws.String(savedCallsTableName),
+			ExclusiveStartKey: startKey,
+			Limit:             aws.Int32(query.pageSize()),
+		}
+		if hasFilter {
+			expr, err := builder.Build()
+			if err != nil {
+				return CallPage{}, err
+			}
+			input.FilterExpression = expr.Filter()
+			input.ExpressionAttributeNames = expr.Names()
+			input.ExpressionAttributeValues = expr.Values()
+		}
+		output, err := dao.Service.Scan(ctx, input)
+		if err != nil {
+			return CallPage{}, err
+		}
+		items, lastEvaluatedKey = output.Items, output.LastEvaluatedKey
+	}
+
+	calls := []SavedCall{}
+	err = attributevalue.UnmarshalListOfMaps(items, &calls)
+	if err != nil {
+		return CallPage{}, err
+	}
+	page := CallPage{Calls: presentCalls(query, calls)}
+	page.Cursor, err = encodeCursor(lastEvaluatedKey)
+	return page, err
+}
+
+// GetCall finds a call by the county's id through the id index. A call
+// has a copy for each cross street there, only the primary one is returned.
+func (dao *SavedCallDataAccess) GetCall(ctx context.Context, id string) (SavedCall, error) {
+	expr, err := expression.
+		NewBuilder().
+		WithKeyCondition(expression.Key("id").Equal(expression.Value(id))).
+		Build()
+	if err != nil {
+		return SavedCall{}, err
+	}
+
+	params := &dynamodb.QueryInput{
+		TableName:                 aws.String(savedCallsTableName),
+		IndexName:                 aws.String(idIndexName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+	}
+
+	paginator := dynamodb.NewQueryPaginator(dao.Service, params, func(qpo *dynamodb.QueryPaginatorOptions) {})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return SavedCall{}, err
+		}
+
+		records := []SavedCall{}
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &records)
+		if err != nil {
+			return SavedCall{}, err
+		}
+		if calls := dropAliases(records); len(calls) > 0 {
+			return calls[0], nil
+		}
+	}
+	return SavedCall{}, ErrNotFound
+}
+
+func (query CallQuery) matches(call SavedCall) bool {
+	if query.StreetName != "" && call.StreetName != query.StreetName {
+		return false
+	}
+	if query.StreetName == "" && call.IsAlias() {
+		return false
+	}
+	if query.CallType != "" && call.CallType != query.CallType {
+		return false
+	}
+	if query.ID != "" && call.ID != query.ID {
+		return false
+	}
+	from, to := query.sortKeyRange()
+	if from != "" && call.SortKey < from {
+		return false
+	}
+	if to != "" && call.SortKey > to {
+		return false
+	}
+	return true
+}
+
+// findCalls answers a query for the stores that hold every call in memory,
+// newest first, with the same cursor format as dynamo
+func findCalls(calls []SavedCall, query CallQuery) (CallPage, error) {
+	var after map[string]string
+	if query.Cursor != "" {
+		var err error
+		after, err = decodeCursorKey(query.Cursor)
+		if err != nil {
+			return CallPage{}, err
+		}
+	}
+
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].SortKey != calls[j].SortKey {
+			return calls[i].SortKey > calls[j].SortKey
+		}
+		return calls[i].StreetName < calls[j].StreetName
+	})
+
+	start := 0
+	if after != nil {
+		start = len(calls)
+		for i, call := range calls {
+			if call.StreetName == after["streetName"] && call.SortKey == after["sortKey"] {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	matched := []SavedCall{}
+	cursor := ""
+	limit := int(query.pageSize())
+	for i := start; i < len(calls); i++ {
+		if len(matched) == limit {
+			last := matched[len(matched)-1]
+			data, err := json.Marshal(map[string]string{"streetName": last.StreetName, "sortKey": last.SortKey})
+			if err != nil {
+				return CallPage{}, err
+			}
+			cursor = base64.RawURLEncoding.EncodeToString(data)
+			break
+		}
+		if query.matches(calls[i]) {
+			matched = append(matched, calls[i])
+		}
+	}
+	return CallPage{Calls: presentCalls(query, matched), Cursor: cursor}, nil
+}
+
+func getCall(calls []SavedCall, id string) (SavedCall, error) {
+	page, err := findCalls(calls, CallQuery{ID: id, Limit: 1})
+	if err != nil {
+		return SavedCall{}, err
+	}
+	if len(page.Calls) == 0 {
+		return SavedCall{}, ErrNotFound
+	}
+	return page.Calls[0], nil
+}
@@ -0,0 +1,156 @@
+package saved_calls_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Call Queries", func() {
+	var ctx context.Context
+	var callItem map[string]types.AttributeValue
+	var lastKey map[string]types.AttributeValue
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		callItem = map[string]types.AttributeValue{
+			"sortKey":         &types.AttributeValueMemberS{Value: "2022/03/23#0123#police"},
+			"id":              &types.AttributeValueMemberS{Value: "0123"},
+			"callType":        &types.AttributeValueMemberS{Value: "police"},
+			"lastKnownStatus": &types.AttributeValueMemberS{Value: "dispatched"},
+			"callReceived":    &types.AttributeValueMemberS{Value: "2022-03-23T23:22:39-04:00"},
+			"streetName":      &types.AttributeValueMemberS{Value: "FAKE RD"},
+		}
+		lastKey = map[string]types.AttributeValue{
+			"sortKey":    &types.AttributeValueMemberS{Value: "2022/03/23#0123#police"},
+			"streetName": &types.AttributeValueMemberS{Value: "FAKE RD"},
+		}
+	})
+
+	Describe("FindCalls()", func() {
+		It("queries a street by date range, newest first", func() {
+			dynamoDBMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+				Expect(*input.TableName).To(Equal("SavedCalls"))
+				Expect(input.IndexName).To(BeNil())
+				Expect(*input.KeyConditionExpression).To(Equal("(#1 = :1) AND (#2 BETWEEN :2 AND :3)"))
+				Expect(*input.FilterExpression).To(Equal("#0 = :0"))
+				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{
+					"#0": "callType",
+					"#1": "streetName",
+					"#2": "sortKey",
+				}))
+				Expect(input.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
+					":0": &types.AttributeValueMemberS{Value: "police"},
+					":1": &types.AttributeValueMemberS{Value: "FAKE RD"},
+					":2": &types.AttributeValueMemberS{Value: "2022/03/01"},
+					":3": &types.AttributeValueMemberS{Value: "2022/03/31~"},
+				}))
+				Expect(*input.Limit).To(Equal(int32(10)))
+				Expect(*input.ScanIndexForward).To(BeFalse())
+				Expect(input.ExclusiveStartKey).To(BeNil())
+				return true
+			}), mock.Anything).Return(&dynamodb.QueryOutput{
+				Items:            []map[string]types.AttributeValue{callItem},
+				LastEvaluatedKey: lastKey,
+			}, nil)
+
+			result, err := subject.FindCalls(ctx, saved_calls.CallQuery{
+				StreetName: "FAKE RD",
+				From:       time.Date(2022, 3, 1, 0, 0, 0, 0, localLocation),
+				To:         time.Date(2022, 3, 31, 0, 0, 0, 0, localLocation),
+				CallType:   "police",
+				Limit:      10,
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result.Calls)).To(Equal(1))
+			Expect(result.Calls[0].ID).To(Equal("0123"))
+			Expect(result.Cursor).ToNot(BeEmpty())
+		})
+
+		It("resumes from a cursor", func() {
+			dynamoDBMock.On("Query", ctx, mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{
+				Items:            []map[string]types.AttributeValue{callItem},
+				LastEvaluatedKey: lastKey,
+			}, nil).Once()
+			dynamoDBMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+				return input.ExclusiveStartKey != nil
+			}), mock.Anything).Return(&dynamodb.QueryOutput{}, nil).Once()
+
+			first, err := subject.FindCalls(ctx, saved_calls.CallQuery{StreetName: "FAKE RD"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			second, err := subject.FindCalls(ctx, saved_calls.CallQuery{StreetName: "FAKE RD", Cursor: first.Cursor})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(second.Calls).To(BeEmpty())
+			Expect(second.Cursor).To(BeEmpty())
+			Expect(dynamoDBMock.Calls[1].Arguments.Get(1).(*dynamodb.QueryInput).ExclusiveStartKey).To(Equal(lastKey))
+		})
+
+		It("scans when no street is given", func() {
+			dynamoDBMock.On("Scan", ctx, mock.MatchedBy(func(input *dynamodb.ScanInput) bool {
+				Expect(*input.TableName).To(Equal("SavedCalls"))
+				Expect(*input.FilterExpression).To(Equal("#0 >= :0"))
+				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{
+					"#0": "sortKey",
+				}))
+				Expect(*input.Limit).To(Equal(int32(50)))
+				return true
+			}), mock.Anything).Return(&dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{callItem},
+			}, nil)
+
+			result, err := subject.FindCalls(ctx, saved_calls.CallQuery{
+				From: time.Date(2022, 3, 1, 0, 0, 0, 0, localLocation),
+			})
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result.Calls)).To(Equal(1))
+			Expect(result.Cursor).To(BeEmpty())
+		})
+
+		It("rejects a malformed cursor", func() {
+			_, err := subject.FindCalls(ctx, saved_calls.CallQuery{Cursor: "not a cursor"})
+
+			Expect(err).To(Equal(saved_calls.ErrInvalidCursor))
+			dynamoDBMock.AssertNotCalled(GinkgoT(), "Scan", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("GetCall()", func() {
+		It("queries the id index", func() {
+			alias := map[string]types.AttributeValue{}
+			for key, value := range callItem {
+				alias[key] = value
+			}
+			alias["streetName"] = &types.AttributeValueMemberS{Value: "MAIN ST"}
+			alias["primaryStreet"] = &types.AttributeValueMemberS{Value: "FAKE RD"}
+			dynamoDBMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+				return *input.IndexName == "IdIndex"
+			}), mock.Anything).Return(&dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{alias, callItem},
+			}, nil)
+
+			result, err := subject.GetCall(ctx, "0123")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.StreetName).To(Equal("FAKE RD"))
+			dynamoDBMock.AssertNotCalled(GinkgoT(), "Scan", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("reports missing calls", func() {
+			dynamoDBMock.On("Query", ctx, mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+
+			_, err := subject.GetCall(ctx, "9999")
+
+			Expect(err).To(Equal(saved_calls.ErrNotFound))
+		})
+	})
+})
@@ -2,7 +2,9 @@ package saved_calls
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,9 +20,15 @@ import (
 const (
 	savedCallsTableName = "SavedCalls"
 	secondaryIndexName  = "ActiveIndex"
-	isActiveString      = "-"
+	// looks calls up by the county's id, which isn't part of the table's key
+	idIndexName    = "IdIndex"
+	isActiveString = "-"
 )
 
+// ErrConflict means another write got to the call first, e.g. an
+// overlapping harvest saved the same new call or already moved its status on
+var ErrConflict = errors.New("call was changed by another write")
+
 type DynamoDB interface {
 	PutItem(ctx context.Context,
 		params *dynamodb.PutItemInput,
@@ -28,9 +36,18 @@ type DynamoDB interface {
 	Query(ctx context.Context,
 		params *dynamodb.QueryInput,
 		optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context,
+		params *dynamodb.ScanInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 	UpdateItem(ctx context.Context,
 		params *dynamodb.UpdateItemInput,
 		optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context,
+		params *dynamodb.DeleteItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	TransactWriteItems(ctx context.Context,
+		params *dynamodb.TransactWriteItemsInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
 type SavedCallDataAccess struct {
@@ -42,23 +59,41 @@ type Client interface {
 	GetActiveCalls(ctx context.Context) ([]SavedCall, error)
 	SaveCall(ctx context.Context, activeCall SavedCall) error
 	UpdateStatus(ctx context.Context, activeCall SavedCall) error
+	UpdateMissedHarvests(ctx context.Context, activeCall SavedCall) error
+	BatchWriter
 }
 
 type SavedCall struct {
-	SortKey         string    `dynamodbav:"sortKey,omitempty"`
-	ID              string    `dynamodbav:"id,omitempty"`
-	CallType        string    `dynamodbav:"callType,omitempty"`
-	CallReason      string    `dynamodbav:"callReason,omitempty"`
-	LastKnownStatus string    `dynamodbav:"lastKnownStatus,omitempty"`
-	CallReceived    time.Time `dynamodbav:"callReceived,omitempty"`
-	CallArrival     time.Time `dynamodbav:"callArrival,omitempty"`
-	CallResolved    time.Time `dynamodbav:"callResolved,omitempty"`
-	IsActive        string    `dynamodbav:"isActive,omitempty"`
-	Location        string    `dynamodbav:"location,omitempty"`
-	Area            string    `dynamodbav:"area,omitempty"`
-	Priority        string    `dynamodbav:"priority,omitempty"`
-	HouseNumber     string    `dynamodbav:"houseNumber,omitempty"`
-	StreetName      string    `dynamodbav:"streetName,omitempty"`
+	SortKey         string    `dynamodbav:"sortKey,omitempty" json:"sortKey,omitempty"`
+	ID              string    `dynamodbav:"id,omitempty" json:"id,omitempty"`
+	CallType        string    `dynamodbav:"callType,omitempty" json:"callType,omitempty"`
+	CallReason      string    `dynamodbav:"callReason,omitempty" json:"callReason,omitempty"`
+	LastKnownStatus string    `dynamodbav:"lastKnownStatus,omitempty" json:"lastKnownStatus,omitempty"`
+	CallReceived    time.Time `dynamodbav:"callReceived,omitempty" json:"callReceived,omitempty"`
+	CallArrival     time.Time `dynamodbav:"callArrival,omitempty" json:"callArrival,omitempty"`
+	CallResolved    time.Time `dynamodbav:"callResolved,omitempty" json:"callResolved,omitempty"`
+	IsActive        string    `dynamodbav:"isActive,omitempty" json:"isActive,omitempty"`
+	Location        string    `dynamodbav:"location,omitempty" json:"location,omitempty"`
+	Area            string    `dynamodbav:"area,omitempty" json:"area,omitempty"`
+	Priority        string    `dynamodbav:"priority,omitempty" json:"priority,omitempty"`
+	HouseNumber     string    `dynamodbav:"houseNumber,omitempty" json:"houseNumber,omitempty"`
+	StreetName      string    `dynamodbav:"streetName,omitempty" json:"streetName,omitempty"`
+	Latitude        float64   `dynamodbav:"latitude,omitempty" json:"latitude,omitempty"`
+	Longitude       float64   `dynamodbav:"longitude,omitempty" json:"longitude,omitempty"`
+	CrossStreets    []string  `dynamodbav:"crossStreets,omitempty" json:"crossStreets,omitempty"`
+	PrimaryStreet   string    `dynamodbav:"primaryStreet,omitempty" json:"primaryStreet,omitempty"`
+	// consecutive harvests the call was active but missing from the county's
+	// list, it is resolved once this reaches the harvester's grace period
+	MissedHarvests int `dynamodbav:"missedHarvests,omitempty" json:"missedHarvests,omitempty"`
+	// starts at 1 when the call is saved and goes up with every status change,
+	// when set on an update the stored call has to still be at this version
+	Version int `dynamodbav:"version,omitempty" json:"version,omitempty"`
+	// set while the street backfill moves a call to a new key, so the move
+	// isn't taken for a new call and a removed one
+	Moved bool `dynamodbav:"moved,omitempty" json:"-"`
+	// the status the caller last read, when set on an update the stored call
+	// has to still have it. It's never stored.
+	ExpectedStatus string `dynamodbav:"-" json:"-"`
 }
 
 func normalizeCall(savedCall *SavedCall) {
@@ -82,6 +117,91 @@ func normalizeCall(savedCall *SavedCall) {
 	savedCall.CallResolved = savedCall.CallResolved.UTC()
 }
 
+// the column that records when a call reached the given (normalized) status
+func statusTimestampColumn(status string) (string, error) {
+	switch status {
+	case "dispatched":
+		return "", nil
+	case "on scene":
+		return "callArrival", nil
+	case "resolved":
+		return "callResolved", nil
+	default:
+		return "", fmt.Errorf("unknown status: %s", status)
+	}
+}
+
+// applies an UpdateStatus to a stored call the same way the dynamo update
+// expression does, for the stores that hold calls in memory
+func applyStatusUpdate(existingCall SavedCall, activeCall SavedCall, now time.Time) (SavedCall, error) {
+	timestampColumnName, err := statusTimestampColumn(activeCall.LastKnownStatus)
+	if err != nil {
+		return existingCall, err
+	}
+
+	existingCall.StreetName = activeCall.StreetName
+	existingCall.SortKey = activeCall.SortKey
+	existingCall.LastKnownStatus = activeCall.LastKnownStatus
+
+	switch timestampColumnName {
+	case "callArrival":
+		existingCall.CallArrival = now
+	case "callResolved":
+		existingCall.CallResolved = now
+	}
+
+	if activeCall.IsActive == "" {
+		existingCall.IsActive = ""
+	}
+	existingCall.Version++
+
+	return existingCall, nil
+}
+
+// checkStatusUpdate mirrors the condition on a status update for the local
+// stores, the call has to exist and still be how the caller last saw it
+func checkStatusUpdate(existingCall SavedCall, exists bool, activeCall SavedCall) error {
+	if !exists {
+		return ErrConflict
+	}
+	if activeCall.ExpectedStatus != "" && existingCall.LastKnownStatus != strings.ToLower(activeCall.ExpectedStatus) {
+		return ErrConflict
+	}
+	if activeCall.Version > 0 && existingCall.Version != activeCall.Version {
+		return ErrConflict
+	}
+	return nil
+}
+
+// mirrors a query against the ActiveIndex GSI
+func filterActiveCalls(calls []SavedCall) []SavedCall {
+	result := []SavedCall{}
+	for _, call := range calls {
+		if call.IsActive == isActiveString {
+			result = append(result, call)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CallReceived.Before(result[j].CallReceived)
+	})
+	return result
+}
+
+func callKey(savedCall SavedCall) (map[string]types.AttributeValue, error) {
+	sortKey, err := attributevalue.Marshal(savedCall.SortKey)
+	if err != nil {
+		return nil, err
+	}
+	streetName, err := attributevalue.Marshal(savedCall.StreetName)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		"streetName": streetName,
+		"sortKey":    sortKey,
+	}, nil
+}
+
 func New(config aws.Config) *SavedCallDataAccess {
 	service := dynamodb.NewFromConfig(config)
 	clock := func() time.Time { return time.Now().UTC() }
@@ -135,40 +255,90 @@ func (dao *SavedCallDataAccess) GetActiveCalls(ctx context.Context) ([]SavedCall
 		result = append(result, records...)
 	}
 
-	return result, err
+	return dropAliases(result), err
 }
 
-func (dao *SavedCallDataAccess) SaveCall(ctx context.Context, activeCall SavedCall) error {
+// conditionFailed turns a failed condition into ErrConflict
+func conditionFailed(err error) error {
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return ErrConflict
+	}
+	return err
+}
+
+// the puts that save a call and its aliases, the call is only saved if it
+// doesn't exist yet
+func saveInputs(activeCall SavedCall) ([]*dynamodb.PutItemInput, error) {
 	normalizeCall(&activeCall)
+	activeCall.Version = 1
+
+	condition, err := expression.
+		NewBuilder().
+		WithCondition(expression.AttributeNotExists(expression.Name("sortKey"))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
 
-	item, err := attributevalue.MarshalMap(activeCall)
+	puts := []*dynamodb.PutItemInput{}
+	for i, call := range append([]SavedCall{activeCall}, aliasCalls(activeCall)...) {
+		item, err := attributevalue.MarshalMap(call)
+		if err != nil {
+			return nil, err
+		}
+		put := &dynamodb.PutItemInput{
+			TableName: aws.String(savedCallsTableName),
+			Item:      item,
+		}
+		// the aliases go with the call, only the call itself is checked
+		if i == 0 {
+			put.ConditionExpression = condition.Condition()
+			put.ExpressionAttributeNames = condition.Names()
+		}
+		puts = append(puts, put)
+	}
+	return puts, nil
+}
 
+// SaveCall puts the call before its aliases, so a call another write saved
+// first is reported as ErrConflict before any of them are touched
+func (dao *SavedCallDataAccess) SaveCall(ctx context.Context, activeCall SavedCall) error {
+	puts, err := saveInputs(activeCall)
 	if err != nil {
 		return err
 	}
 
-	_, err = dao.Service.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(savedCallsTableName),
-		Item:      item,
-	})
+	for _, put := range puts {
+		_, err = dao.Service.PutItem(ctx, put)
+		if err != nil {
+			return conditionFailed(err)
+		}
+	}
 
-	return err
+	return nil
 }
 
-func (dao *SavedCallDataAccess) UpdateStatus(ctx context.Context, activeCall SavedCall) error {
-	normalizeCall(&activeCall)
+// the condition a status update is made under, the call has to exist and
+// still be how the caller last saw it
+func statusCondition(activeCall SavedCall) expression.ConditionBuilder {
+	condition := expression.AttributeExists(expression.Name("sortKey"))
+	if activeCall.ExpectedStatus != "" {
+		condition = condition.And(expression.Name("lastKnownStatus").Equal(expression.Value(strings.ToLower(activeCall.ExpectedStatus))))
+	}
+	if activeCall.Version > 0 {
+		condition = condition.And(expression.Name("version").Equal(expression.Value(activeCall.Version)))
+	}
+	return condition
+}
 
-	var timestampColumnName string
+// the updates that apply a status change to a call and its aliases
+func (dao *SavedCallDataAccess) statusInputs(activeCall SavedCall) ([]*dynamodb.UpdateItemInput, error) {
+	normalizeCall(&activeCall)
 
-	switch activeCall.LastKnownStatus {
-	case "dispatched":
-		timestampColumnName = ""
-	case "on scene":
-		timestampColumnName = "callArrival"
-	case "resolved":
-		timestampColumnName = "callResolved"
-	default:
-		return fmt.Errorf("unknown status: %s", activeCall.LastKnownStatus)
+	timestampColumnName, err := statusTimestampColumn(activeCall.LastKnownStatus)
+	if err != nil {
+		return nil, err
 	}
 
 	setExpression := expression.
@@ -178,38 +348,116 @@ func (dao *SavedCallDataAccess) UpdateStatus(ctx context.Context, activeCall Sav
 		setExpression = setExpression.Set(expression.Name(timestampColumnName), expression.Value(dao.clock()))
 	}
 
+	setExpression = setExpression.Set(
+		expression.Name("version"),
+		expression.Plus(expression.Name("version").IfNotExists(expression.Value(0)), expression.Value(1)),
+	)
+
 	if activeCall.IsActive == "" {
 		setExpression = setExpression.Remove(expression.Name("isActive"))
 	}
 
-	expr, err := expression.
+	primaryExpr, err := expression.
+		NewBuilder().
+		WithUpdate(setExpression).
+		WithCondition(statusCondition(activeCall)).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	// the aliases go with the call, they only have to exist so a missing
+	// one isn't created with nothing but its key
+	aliasExpr, err := expression.
 		NewBuilder().
 		WithUpdate(setExpression).
+		WithCondition(expression.AttributeExists(expression.Name("sortKey"))).
 		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	updates := []*dynamodb.UpdateItemInput{}
+	for i, call := range append([]SavedCall{activeCall}, aliasCalls(activeCall)...) {
+		key, err := callKey(call)
+		if err != nil {
+			return nil, err
+		}
+		expr := aliasExpr
+		if i == 0 {
+			expr = primaryExpr
+		}
+		updates = append(updates, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(savedCallsTableName),
+			Key:                       key,
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		})
+	}
+	return updates, nil
+}
 
+// UpdateStatus updates the call before its aliases, so a call whose status
+// already moved on is reported as ErrConflict before any of them change. An
+// alias that doesn't exist is skipped.
+func (dao *SavedCallDataAccess) UpdateStatus(ctx context.Context, activeCall SavedCall) error {
+	updates, err := dao.statusInputs(activeCall)
 	if err != nil {
 		return err
 	}
 
-	sortKey, err := attributevalue.Marshal(activeCall.SortKey)
+	for i, update := range updates {
+		_, err = dao.Service.UpdateItem(ctx, update)
+		if i > 0 && errors.Is(conditionFailed(err), ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return conditionFailed(err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateMissedHarvests stores the call's missed harvest count, only on the
+// primary item since that's the one the harvester reads back
+func (dao *SavedCallDataAccess) UpdateMissedHarvests(ctx context.Context, activeCall SavedCall) error {
+	normalizeCall(&activeCall)
+
+	update := expression.Remove(expression.Name("missedHarvests"))
+	if activeCall.MissedHarvests > 0 {
+		update = expression.Set(expression.Name("missedHarvests"), expression.Value(activeCall.MissedHarvests))
+	}
+
+	// a call that was deleted or moved since it was read isn't created again
+	// with nothing but its key and the count
+	expr, err := expression.
+		NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.AttributeExists(expression.Name("sortKey"))).
+		Build()
+
 	if err != nil {
 		return err
 	}
-	streetName, err := attributevalue.Marshal(activeCall.StreetName)
+
+	key, err := callKey(activeCall)
 	if err != nil {
 		return err
 	}
 
 	_, err = dao.Service.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(savedCallsTableName),
-		Key: map[string]types.AttributeValue{
-			"streetName": streetName,
-			"sortKey":    sortKey,
-		},
+		TableName:                 aws.String(savedCallsTableName),
+		Key:                       key,
+		ConditionExpression:       expr.Condition(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		UpdateExpression:          expr.Update(),
 	})
-
+	if errors.Is(conditionFailed(err), ErrConflict) {
+		return nil
+	}
 	return err
 }
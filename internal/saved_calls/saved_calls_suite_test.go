@@ -24,10 +24,26 @@ func (dynamoDBMock *DynamoDBMock) Query(ctx context.Context, input *dynamodb.Que
 	args := dynamoDBMock.Called(ctx, input, options)
 	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
 }
+func (dynamoDBMock *DynamoDBMock) Scan(ctx context.Context, input *dynamodb.ScanInput, options ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
 func (dynamoDBMock *DynamoDBMock) UpdateItem(ctx context.Context, input *dynamodb.UpdateItemInput, options ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
 	args := dynamoDBMock.Called(ctx, input, options)
 	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
 }
+func (dynamoDBMock *DynamoDBMock) DeleteItem(ctx context.Context, input *dynamodb.DeleteItemInput, options ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) TransactWriteItems(ctx context.Context, input *dynamodb.TransactWriteItemsInput, options ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.TransactWriteItemsOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput, options ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.BatchWriteItemOutput), args.Error(1)
+}
 
 var subject *saved_calls.SavedCallDataAccess
 var dynamoDBMock *DynamoDBMock
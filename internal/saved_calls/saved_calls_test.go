@@ -131,6 +131,8 @@ var _ = Describe("Saved Calls DAO", func() {
 				Priority:        "3",
 				HouseNumber:     "22XX",
 				StreetName:      "FAKE RD",
+				Latitude:        37.415,
+				Longitude:       -77.6,
 			}
 
 			putOutput := &dynamodb.PutItemOutput{}
@@ -152,6 +154,11 @@ var _ = Describe("Saved Calls DAO", func() {
 				Expect(input.Item["priority"]).To(Equal(&types.AttributeValueMemberS{Value: "3"}))
 				Expect(input.Item["houseNumber"]).To(Equal(&types.AttributeValueMemberS{Value: "22XX"}))
 				Expect(input.Item["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "FAKE RD"}))
+				Expect(input.Item["latitude"]).To(Equal(&types.AttributeValueMemberN{Value: "37.415"}))
+				Expect(input.Item["longitude"]).To(Equal(&types.AttributeValueMemberN{Value: "-77.6"}))
+				Expect(input.Item["version"]).To(Equal(&types.AttributeValueMemberN{Value: "1"}))
+				Expect(*input.ConditionExpression).To(Equal("attribute_not_exists (#0)"))
+				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{"#0": "sortKey"}))
 
 				return true
 			}), mock.Anything).Return(putOutput, nil)
@@ -160,6 +167,54 @@ var _ = Describe("Saved Calls DAO", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(callToSave.SortKey).To(Equal(""))
 		})
+
+		It("stores a copy under each cross street", func() {
+			callToSave := saved_calls.SavedCall{
+				ID:              "0123",
+				CallType:        "police",
+				LastKnownStatus: "Dispatched",
+				CallReceived:    time.Date(2022, 3, 23, 23, 22, 39, 0, localLocation),
+				Location:        "HULL ST RD AT COALFIELD RD",
+				StreetName:      "HULL ST RD",
+				CrossStreets:    []string{"COALFIELD RD"},
+			}
+
+			streets := []string{}
+			dynamoDBMock.On("PutItem", ctx, mock.MatchedBy(func(putInput *dynamodb.PutItemInput) bool {
+				streetName := putInput.Item["streetName"].(*types.AttributeValueMemberS).Value
+				streets = append(streets, streetName)
+				if streetName == "COALFIELD RD" {
+					Expect(putInput.Item["primaryStreet"]).To(Equal(&types.AttributeValueMemberS{Value: "HULL ST RD"}))
+					Expect(putInput.ConditionExpression).To(BeNil())
+				} else {
+					Expect(putInput.Item).ToNot(HaveKey("primaryStreet"))
+				}
+				Expect(putInput.Item["sortKey"]).To(Equal(&types.AttributeValueMemberS{Value: "2022/03/23#0123#police"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+			err := subject.SaveCall(ctx, callToSave)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(streets).To(Equal([]string{"HULL ST RD", "COALFIELD RD"}))
+		})
+
+		It("reports a call that was already saved as a conflict", func() {
+			callToSave := saved_calls.SavedCall{
+				ID:              "0123",
+				CallType:        "police",
+				LastKnownStatus: "Dispatched",
+				CallReceived:    time.Date(2022, 3, 23, 23, 22, 39, 0, localLocation),
+				StreetName:      "HULL ST RD",
+				CrossStreets:    []string{"COALFIELD RD"},
+			}
+			dynamoDBMock.On("PutItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			err := subject.SaveCall(ctx, callToSave)
+
+			Expect(err).To(MatchError(saved_calls.ErrConflict))
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "PutItem", 1)
+		})
 	})
 
 	Describe("UpdateStatus()", func() {
@@ -185,16 +240,21 @@ var _ = Describe("Saved Calls DAO", func() {
 				input := *updateInput
 				Expect(*input.TableName).To(Equal("SavedCalls"))
 				Expect(len(input.Key)).To(Equal(2))
-				Expect(*input.UpdateExpression).To(Equal("SET #0 = :0, #1 = :1\n"))
+				Expect(*input.UpdateExpression).To(Equal("SET #1 = :0, #2 = :1, #3 = if_not_exists(#3, :2) + :3\n"))
+				Expect(*input.ConditionExpression).To(Equal("attribute_exists (#0)"))
 				Expect(input.Key["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "FAKE RD"}))
 				Expect(input.Key["sortKey"]).To(Equal(&types.AttributeValueMemberS{Value: "2022/03/23#0123#police"}))
 				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{
-					"#0": "lastKnownStatus",
-					"#1": "callArrival",
+					"#0": "sortKey",
+					"#1": "lastKnownStatus",
+					"#2": "callArrival",
+					"#3": "version",
 				}))
 				Expect(input.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
 					":0": &types.AttributeValueMemberS{Value: "on scene"},
 					":1": &types.AttributeValueMemberS{Value: "2030-01-01T06:30:00Z"},
+					":2": &types.AttributeValueMemberN{Value: "0"},
+					":3": &types.AttributeValueMemberN{Value: "1"},
 				}))
 
 				return true
@@ -207,23 +267,32 @@ var _ = Describe("Saved Calls DAO", func() {
 		It("sets resolve time", func() {
 			callToSave.CallArrival = time.Date(2022, 3, 23, 23, 27, 39, 0, localLocation)
 			callToSave.LastKnownStatus = "Resolved"
+			callToSave.ExpectedStatus = "On Scene"
+			callToSave.Version = 2
 
 			updateOutput := &dynamodb.UpdateItemOutput{}
 			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(updateInput *dynamodb.UpdateItemInput) bool {
 				input := *updateInput
 				Expect(*input.TableName).To(Equal("SavedCalls"))
 				Expect(len(input.Key)).To(Equal(2))
-				Expect(*input.UpdateExpression).To(Equal("REMOVE #0\nSET #1 = :0, #2 = :1\n"))
+				Expect(*input.UpdateExpression).To(Equal("REMOVE #3\nSET #1 = :2, #4 = :3, #2 = if_not_exists(#2, :4) + :5\n"))
+				Expect(*input.ConditionExpression).To(Equal("((attribute_exists (#0)) AND (#1 = :0)) AND (#2 = :1)"))
 				Expect(input.Key["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "FAKE RD"}))
 				Expect(input.Key["sortKey"]).To(Equal(&types.AttributeValueMemberS{Value: "2022/03/23#0123#police"}))
 				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{
-					"#0": "isActive",
+					"#0": "sortKey",
 					"#1": "lastKnownStatus",
-					"#2": "callResolved",
+					"#2": "version",
+					"#3": "isActive",
+					"#4": "callResolved",
 				}))
 				Expect(input.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
-					":0": &types.AttributeValueMemberS{Value: "resolved"},
-					":1": &types.AttributeValueMemberS{Value: "2030-01-01T06:30:00Z"},
+					":0": &types.AttributeValueMemberS{Value: "on scene"},
+					":1": &types.AttributeValueMemberN{Value: "2"},
+					":2": &types.AttributeValueMemberS{Value: "resolved"},
+					":3": &types.AttributeValueMemberS{Value: "2030-01-01T06:30:00Z"},
+					":4": &types.AttributeValueMemberN{Value: "0"},
+					":5": &types.AttributeValueMemberN{Value: "1"},
 				}))
 
 				return true
@@ -231,6 +300,90 @@ var _ = Describe("Saved Calls DAO", func() {
 
 			err := subject.UpdateStatus(ctx, callToSave)
 
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("reports a call another write changed as a conflict", func() {
+			callToSave.LastKnownStatus = "Resolved"
+			callToSave.ExpectedStatus = "Dispatched"
+
+			dynamoDBMock.On("UpdateItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			err := subject.UpdateStatus(ctx, callToSave)
+
+			Expect(err).To(MatchError(saved_calls.ErrConflict))
+		})
+		It("only updates cross street copies that exist", func() {
+			callToSave.CrossStreets = []string{"COALFIELD RD"}
+
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				return input.Key["streetName"].(*types.AttributeValueMemberS).Value == "FAKE RD"
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				Expect(input.Key["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "COALFIELD RD"}))
+				Expect(*input.ConditionExpression).To(Equal("attribute_exists (#0)"))
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			err := subject.UpdateStatus(ctx, callToSave)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "UpdateItem", 2)
+		})
+	})
+
+	Describe("UpdateMissedHarvests()", func() {
+		var missedCall saved_calls.SavedCall
+
+		BeforeEach(func() {
+			missedCall = saved_calls.SavedCall{
+				ID:              "0123",
+				CallType:        "police",
+				LastKnownStatus: "Dispatched",
+				CallReceived:    time.Date(2022, 3, 23, 23, 22, 39, 0, localLocation),
+				StreetName:      "HULL ST RD",
+				CrossStreets:    []string{"COALFIELD RD"},
+				MissedHarvests:  2,
+			}
+		})
+
+		It("sets the count on the primary item", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				Expect(*input.UpdateExpression).To(Equal("SET #1 = :0\n"))
+				Expect(*input.ConditionExpression).To(Equal("attribute_exists (#0)"))
+				Expect(input.Key["streetName"]).To(Equal(&types.AttributeValueMemberS{Value: "HULL ST RD"}))
+				Expect(input.Key["sortKey"]).To(Equal(&types.AttributeValueMemberS{Value: "2022/03/23#0123#police"}))
+				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{"#0": "sortKey", "#1": "missedHarvests"}))
+				Expect(input.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
+					":0": &types.AttributeValueMemberN{Value: "2"},
+				}))
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil).Once()
+
+			err := subject.UpdateMissedHarvests(ctx, missedCall)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			dynamoDBMock.AssertNumberOfCalls(GinkgoT(), "UpdateItem", 1)
+		})
+
+		It("removes the count once the call is back", func() {
+			missedCall.MissedHarvests = 0
+
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+				Expect(*input.UpdateExpression).To(Equal("REMOVE #1\n"))
+				Expect(input.ExpressionAttributeValues).To(BeEmpty())
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+			err := subject.UpdateMissedHarvests(ctx, missedCall)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("leaves a call that no longer exists alone", func() {
+			dynamoDBMock.On("UpdateItem", ctx, mock.Anything, mock.Anything).Return(&dynamodb.UpdateItemOutput{}, &types.ConditionalCheckFailedException{})
+
+			err := subject.UpdateMissedHarvests(ctx, missedCall)
+
 			Expect(err).ShouldNot(HaveOccurred())
 		})
 	})
@@ -0,0 +1,80 @@
+package saved_calls
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// stream records use the lambda event types rather than the sdk's, so they
+// are converted before going through the same unmarshalling as a query
+func fromStreamAttribute(value events.DynamoDBAttributeValue) (types.AttributeValue, error) {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}, nil
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}, nil
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}, nil
+	case events.DataTypeNull:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}, nil
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}, nil
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}, nil
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}, nil
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, 0, len(value.List()))
+		for _, item := range value.List() {
+			converted, err := fromStreamAttribute(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case events.DataTypeMap:
+		converted, err := fromStreamImage(value.Map())
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: converted}, nil
+	default:
+		return nil, fmt.Errorf("unsupported stream attribute type: %v", value.DataType())
+	}
+}
+
+func fromStreamImage(image map[string]events.DynamoDBAttributeValue) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		converted, err := fromStreamAttribute(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = converted
+	}
+	return item, nil
+}
+
+// UnmarshalStreamImage decodes the old or new image of a stream record. An
+// image that isn't present, such as the old image of an INSERT, decodes to
+// an empty call.
+func UnmarshalStreamImage(image map[string]events.DynamoDBAttributeValue) (SavedCall, error) {
+	var savedCall SavedCall
+	if len(image) == 0 {
+		return savedCall, nil
+	}
+
+	item, err := fromStreamImage(image)
+	if err != nil {
+		return savedCall, err
+	}
+
+	err = attributevalue.UnmarshalMap(item, &savedCall)
+	return savedCall, err
+}
@@ -0,0 +1,96 @@
+package saved_calls_test
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+var _ = Describe("Stream Images", func() {
+	var event events.DynamoDBEvent
+
+	BeforeEach(func() {
+		data, err := os.ReadFile("sample_events/stream_event.json")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(json.Unmarshal(data, &event)).To(Succeed())
+		Expect(event.Records).To(HaveLen(3))
+	})
+
+	It("decodes an INSERT", func() {
+		record := event.Records[0]
+		Expect(record.EventName).To(Equal("INSERT"))
+
+		oldCall, err := saved_calls.UnmarshalStreamImage(record.Change.OldImage)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(oldCall).To(Equal(saved_calls.SavedCall{}))
+
+		newCall, err := saved_calls.UnmarshalStreamImage(record.Change.NewImage)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(newCall.SortKey).To(Equal("2022/03/23#0123#police"))
+		Expect(newCall.ID).To(Equal("0123"))
+		Expect(newCall.CallType).To(Equal("police"))
+		Expect(newCall.CallReason).To(Equal("SUSPICIOUS SITUATION"))
+		Expect(newCall.LastKnownStatus).To(Equal("dispatched"))
+		Expect(newCall.CallReceived.Equal(time.Date(2022, 3, 24, 3, 22, 39, 0, time.UTC))).To(BeTrue())
+		Expect(newCall.CallArrival.IsZero()).To(BeTrue())
+		Expect(newCall.IsActive).To(Equal("-"))
+		Expect(newCall.Location).To(Equal("22XX FAKE RD"))
+		Expect(newCall.Area).To(Equal("11"))
+		Expect(newCall.Priority).To(Equal("3"))
+		Expect(newCall.HouseNumber).To(Equal("22XX"))
+		Expect(newCall.StreetName).To(Equal("FAKE RD"))
+	})
+
+	It("decodes a MODIFY", func() {
+		record := event.Records[1]
+		Expect(record.EventName).To(Equal("MODIFY"))
+
+		oldCall, err := saved_calls.UnmarshalStreamImage(record.Change.OldImage)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(oldCall.LastKnownStatus).To(Equal("dispatched"))
+
+		newCall, err := saved_calls.UnmarshalStreamImage(record.Change.NewImage)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(newCall.LastKnownStatus).To(Equal("on scene"))
+		Expect(newCall.CallArrival.Equal(time.Date(2022, 3, 24, 3, 30, 0, 0, time.UTC))).To(BeTrue())
+	})
+
+	It("decodes a REMOVE", func() {
+		record := event.Records[2]
+		Expect(record.EventName).To(Equal("REMOVE"))
+
+		oldCall, err := saved_calls.UnmarshalStreamImage(record.Change.OldImage)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(oldCall.LastKnownStatus).To(Equal("resolved"))
+		Expect(oldCall.IsActive).To(Equal(""))
+		Expect(oldCall.CallResolved.Equal(time.Date(2022, 3, 24, 4, 0, 0, 0, time.UTC))).To(BeTrue())
+
+		newCall, err := saved_calls.UnmarshalStreamImage(record.Change.NewImage)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(newCall).To(Equal(saved_calls.SavedCall{}))
+	})
+
+	It("decodes nested attributes", func() {
+		image := map[string]events.DynamoDBAttributeValue{
+			"id": events.NewStringAttribute("0123"),
+			"extra": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+				"list": events.NewListAttribute([]events.DynamoDBAttributeValue{
+					events.NewNumberAttribute("1"),
+					events.NewBooleanAttribute(true),
+					events.NewNullAttribute(),
+				}),
+			}),
+		}
+
+		call, err := saved_calls.UnmarshalStreamImage(image)
+
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(call.ID).To(Equal("0123"))
+	})
+})
@@ -0,0 +1,90 @@
+package saved_incidents
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var savedIncidentsBucket = []byte(savedIncidentsTableName)
+
+// BoltDataAccess stores saved incidents in a local BoltDB file, usually the
+// same one the saved calls live in.
+type BoltDataAccess struct {
+	db    *bolt.DB
+	clock func() time.Time
+}
+
+func NewBolt(db *bolt.DB, clock func() time.Time) (*BoltDataAccess, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(savedIncidentsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltDataAccess{
+		db:    db,
+		clock: clock,
+	}, nil
+}
+
+func (dao *BoltDataAccess) GetActiveIncidents(ctx context.Context) ([]SavedIncident, error) {
+	incidents := []SavedIncident{}
+	err := dao.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(savedIncidentsBucket).ForEach(func(key []byte, value []byte) error {
+			var incident SavedIncident
+			err := json.Unmarshal(value, &incident)
+			if err != nil {
+				return err
+			}
+			incidents = append(incidents, incident)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return filterActiveIncidents(incidents), nil
+}
+
+func putIncident(bucket *bolt.Bucket, incident SavedIncident) error {
+	value, err := json.Marshal(incident)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(itemKey(incident)), value)
+}
+
+func (dao *BoltDataAccess) SaveIncident(ctx context.Context, incident SavedIncident) error {
+	normalizeIncident(&incident)
+	if incident.FirstSeen.IsZero() {
+		incident.FirstSeen = dao.clock()
+	}
+
+	return dao.db.Update(func(tx *bolt.Tx) error {
+		return putIncident(tx.Bucket(savedIncidentsBucket), incident)
+	})
+}
+
+func (dao *BoltDataAccess) UpdateStatus(ctx context.Context, incident SavedIncident) error {
+	normalizeIncident(&incident)
+
+	return dao.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(savedIncidentsBucket)
+
+		var existingIncident SavedIncident
+		if value := bucket.Get([]byte(itemKey(incident))); value != nil {
+			err := json.Unmarshal(value, &existingIncident)
+			if err != nil {
+				return err
+			}
+		}
+
+		return putIncident(bucket, applyStatusUpdate(existingIncident, incident, dao.clock()))
+	})
+}
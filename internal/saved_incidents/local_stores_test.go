@@ -0,0 +1,79 @@
+package saved_incidents_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+)
+
+func describeLocalStore(name string, newStore func() saved_incidents.Client) {
+	Describe(name, func() {
+		var ctx context.Context
+		var store saved_incidents.Client
+		var incident saved_incidents.SavedIncident
+
+		BeforeEach(func() {
+			ctx = context.TODO()
+			store = newStore()
+			incident = saved_incidents.SavedIncident{
+				Jurisdiction:    "Chesterfield",
+				Incident:        "Vehicle Crash",
+				IncidentType:    "Crash",
+				Location:        "HULL ST RD AT COALFIELD RD",
+				Direction:       "EB",
+				LastKnownStatus: "Active",
+				Latitude:        37.4521,
+				Longitude:       -77.6512,
+			}
+		})
+
+		It("returns saved incidents as active", func() {
+			Expect(store.SaveIncident(ctx, incident)).To(Succeed())
+
+			result, err := store.GetActiveIncidents(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].ID).To(Equal(saved_incidents.IncidentID("Chesterfield", "Vehicle Crash", "Crash", "HULL ST RD AT COALFIELD RD", "EB")))
+			Expect(result[0].LastKnownStatus).To(Equal("active"))
+			Expect(result[0].Latitude).To(Equal(37.4521))
+			Expect(result[0].FirstSeen.Equal(currentTime)).To(BeTrue())
+		})
+
+		It("drops resolved incidents from the active set", func() {
+			Expect(store.SaveIncident(ctx, incident)).To(Succeed())
+
+			incident.LastKnownStatus = "resolved"
+			Expect(store.UpdateStatus(ctx, incident)).To(Succeed())
+
+			result, err := store.GetActiveIncidents(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(BeEmpty())
+		})
+	})
+}
+
+var _ = Describe("Local Stores", func() {
+	clock := func() time.Time { return currentTime }
+
+	describeLocalStore("InMemoryDataAccess", func() saved_incidents.Client {
+		return saved_incidents.NewInMemory(clock)
+	})
+
+	describeLocalStore("BoltDataAccess", func() saved_incidents.Client {
+		db, err := bolt.Open(filepath.Join(GinkgoT().TempDir(), "incidents.db"), 0600, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(db.Close)
+
+		store, err := saved_incidents.NewBolt(db, clock)
+		Expect(err).ShouldNot(HaveOccurred())
+		return store
+	})
+})
@@ -0,0 +1,61 @@
+package saved_incidents
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryDataAccess keeps saved incidents in a map, for offline tests and
+// for running the harvester without a table. Nothing survives a restart.
+type InMemoryDataAccess struct {
+	mutex     sync.Mutex
+	incidents map[string]SavedIncident
+	clock     func() time.Time
+}
+
+func NewInMemory(clock func() time.Time) *InMemoryDataAccess {
+	return &InMemoryDataAccess{
+		incidents: map[string]SavedIncident{},
+		clock:     clock,
+	}
+}
+
+func itemKey(incident SavedIncident) string {
+	return incident.Jurisdiction + "\x00" + incident.ID
+}
+
+func (dao *InMemoryDataAccess) GetActiveIncidents(ctx context.Context) ([]SavedIncident, error) {
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	incidents := make([]SavedIncident, 0, len(dao.incidents))
+	for _, incident := range dao.incidents {
+		incidents = append(incidents, incident)
+	}
+	return filterActiveIncidents(incidents), nil
+}
+
+func (dao *InMemoryDataAccess) SaveIncident(ctx context.Context, incident SavedIncident) error {
+	normalizeIncident(&incident)
+	if incident.FirstSeen.IsZero() {
+		incident.FirstSeen = dao.clock()
+	}
+
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	dao.incidents[itemKey(incident)] = incident
+	return nil
+}
+
+func (dao *InMemoryDataAccess) UpdateStatus(ctx context.Context, incident SavedIncident) error {
+	normalizeIncident(&incident)
+
+	dao.mutex.Lock()
+	defer dao.mutex.Unlock()
+
+	key := itemKey(incident)
+	dao.incidents[key] = applyStatusUpdate(dao.incidents[key], incident, dao.clock())
+	return nil
+}
@@ -0,0 +1,241 @@
+package saved_incidents
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	savedIncidentsTableName = "SavedIncidents"
+	secondaryIndexName      = "ActiveIndex"
+	isActiveString          = "-"
+)
+
+// DynamoDB is the part of the dynamo client the incidents table uses
+type DynamoDB interface {
+	PutItem(ctx context.Context,
+		params *dynamodb.PutItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context,
+		params *dynamodb.QueryInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	UpdateItem(ctx context.Context,
+		params *dynamodb.UpdateItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+type SavedIncidentDataAccess struct {
+	Service DynamoDB
+	clock   func() time.Time
+}
+
+type Client interface {
+	GetActiveIncidents(ctx context.Context) ([]SavedIncident, error)
+	SaveIncident(ctx context.Context, incident SavedIncident) error
+	UpdateStatus(ctx context.Context, incident SavedIncident) error
+}
+
+type SavedIncident struct {
+	ID              string    `dynamodbav:"id,omitempty"`
+	Jurisdiction    string    `dynamodbav:"jurisdiction,omitempty"`
+	Incident        string    `dynamodbav:"incident,omitempty"`
+	IncidentType    string    `dynamodbav:"incidentType,omitempty"`
+	Location        string    `dynamodbav:"location,omitempty"`
+	Direction       string    `dynamodbav:"direction,omitempty"`
+	LastKnownStatus string    `dynamodbav:"lastKnownStatus,omitempty"`
+	Latitude        float64   `dynamodbav:"latitude"`
+	Longitude       float64   `dynamodbav:"longitude"`
+	FirstSeen       time.Time `dynamodbav:"firstSeen,omitempty"`
+	Resolved        time.Time `dynamodbav:"resolved,omitempty"`
+	IsActive        string    `dynamodbav:"isActive,omitempty"`
+}
+
+// the traffic feeds don't carry an identifier, so one is derived from the
+// fields that stay fixed for the life of an incident
+func IncidentID(jurisdiction string, incident string, incidentType string, location string, direction string) string {
+	hash := sha1.Sum([]byte(strings.Join(
+		[]string{jurisdiction, incident, incidentType, location, direction}, "#",
+	)))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+func normalizeIncident(savedIncident *SavedIncident) {
+	savedIncident.LastKnownStatus = strings.ToLower(savedIncident.LastKnownStatus)
+	if savedIncident.ID == "" {
+		savedIncident.ID = IncidentID(
+			savedIncident.Jurisdiction,
+			savedIncident.Incident,
+			savedIncident.IncidentType,
+			savedIncident.Location,
+			savedIncident.Direction,
+		)
+	}
+
+	if savedIncident.LastKnownStatus != "resolved" {
+		savedIncident.IsActive = isActiveString
+	} else {
+		savedIncident.IsActive = ""
+	}
+
+	savedIncident.FirstSeen = savedIncident.FirstSeen.UTC()
+	savedIncident.Resolved = savedIncident.Resolved.UTC()
+}
+
+// applies an UpdateStatus to a stored incident the same way the dynamo
+// update expression does, for the stores that hold incidents in memory
+func applyStatusUpdate(existingIncident SavedIncident, incident SavedIncident, now time.Time) SavedIncident {
+	existingIncident.Jurisdiction = incident.Jurisdiction
+	existingIncident.ID = incident.ID
+	existingIncident.LastKnownStatus = incident.LastKnownStatus
+
+	if incident.IsActive == "" {
+		existingIncident.Resolved = now
+		existingIncident.IsActive = ""
+	}
+
+	return existingIncident
+}
+
+// mirrors a query against the ActiveIndex GSI
+func filterActiveIncidents(incidents []SavedIncident) []SavedIncident {
+	result := []SavedIncident{}
+	for _, incident := range incidents {
+		if incident.IsActive == isActiveString {
+			result = append(result, incident)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].FirstSeen.Before(result[j].FirstSeen)
+	})
+	return result
+}
+
+func New(config aws.Config) *SavedIncidentDataAccess {
+	service := dynamodb.NewFromConfig(config)
+	clock := func() time.Time { return time.Now().UTC() }
+
+	return &SavedIncidentDataAccess{
+		Service: service,
+		clock:   clock,
+	}
+}
+
+func NewWithClient(dynamoDB DynamoDB, clock func() time.Time) *SavedIncidentDataAccess {
+	return &SavedIncidentDataAccess{
+		Service: dynamoDB,
+		clock:   clock,
+	}
+}
+
+func (dao *SavedIncidentDataAccess) GetActiveIncidents(ctx context.Context) ([]SavedIncident, error) {
+	keyExpression := expression.Key("isActive").Equal(expression.Value(isActiveString))
+	expr, err := expression.
+		NewBuilder().
+		WithKeyCondition(keyExpression).
+		Build()
+
+	if err != nil {
+		return nil, err
+	}
+
+	params := &dynamodb.QueryInput{
+		TableName:                 aws.String(savedIncidentsTableName),
+		IndexName:                 aws.String(secondaryIndexName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var result []SavedIncident
+
+	paginator := dynamodb.NewQueryPaginator(dao.Service, params, func(qpo *dynamodb.QueryPaginatorOptions) {})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		records := []SavedIncident{}
+		err = attributevalue.UnmarshalListOfMaps(page.Items, &records)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, records...)
+	}
+
+	return result, err
+}
+
+func (dao *SavedIncidentDataAccess) SaveIncident(ctx context.Context, incident SavedIncident) error {
+	normalizeIncident(&incident)
+	if incident.FirstSeen.IsZero() {
+		incident.FirstSeen = dao.clock()
+	}
+
+	item, err := attributevalue.MarshalMap(incident)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(savedIncidentsTableName),
+		Item:      item,
+	})
+
+	return err
+}
+
+func (dao *SavedIncidentDataAccess) UpdateStatus(ctx context.Context, incident SavedIncident) error {
+	normalizeIncident(&incident)
+
+	setExpression := expression.
+		Set(expression.Name("lastKnownStatus"), expression.Value(incident.LastKnownStatus))
+
+	if incident.IsActive == "" {
+		setExpression = setExpression.
+			Set(expression.Name("resolved"), expression.Value(dao.clock())).
+			Remove(expression.Name("isActive"))
+	}
+
+	expr, err := expression.
+		NewBuilder().
+		WithUpdate(setExpression).
+		Build()
+
+	if err != nil {
+		return err
+	}
+
+	jurisdiction, err := attributevalue.Marshal(incident.Jurisdiction)
+	if err != nil {
+		return err
+	}
+	id, err := attributevalue.Marshal(incident.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(savedIncidentsTableName),
+		Key: map[string]types.AttributeValue{
+			"jurisdiction": jurisdiction,
+			"id":           id,
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+	})
+
+	return err
+}
@@ -0,0 +1,123 @@
+package saved_incidents_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ = Describe("Saved Incidents DAO", func() {
+	var ctx context.Context
+	var incident saved_incidents.SavedIncident
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		incident = saved_incidents.SavedIncident{
+			Jurisdiction:    "Chesterfield",
+			Incident:        "Vehicle Crash",
+			IncidentType:    "Crash",
+			Location:        "HULL ST RD AT COALFIELD RD",
+			Direction:       "EB",
+			LastKnownStatus: "Active",
+			Latitude:        37.4521,
+			Longitude:       -77.6512,
+		}
+	})
+
+	Describe("GetActiveIncidents()", func() {
+		It("returns a list of active incidents", func() {
+			queryOutput := &dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{
+					{
+						"id":              &types.AttributeValueMemberS{Value: "abc123"},
+						"jurisdiction":    &types.AttributeValueMemberS{Value: "Chesterfield"},
+						"incident":        &types.AttributeValueMemberS{Value: "Vehicle Crash"},
+						"incidentType":    &types.AttributeValueMemberS{Value: "Crash"},
+						"location":        &types.AttributeValueMemberS{Value: "HULL ST RD AT COALFIELD RD"},
+						"direction":       &types.AttributeValueMemberS{Value: "EB"},
+						"lastKnownStatus": &types.AttributeValueMemberS{Value: "active"},
+						"latitude":        &types.AttributeValueMemberN{Value: "37.4521"},
+						"longitude":       &types.AttributeValueMemberN{Value: "-77.6512"},
+						"firstSeen":       &types.AttributeValueMemberS{Value: "2030-01-01T06:00:00Z"},
+						"isActive":        &types.AttributeValueMemberS{Value: "-"},
+					},
+				},
+				Count: 1,
+			}
+
+			dynamoDBMock.On("Query", ctx, mock.MatchedBy(func(queryInput *dynamodb.QueryInput) bool {
+				Expect(*queryInput.TableName).To(Equal("SavedIncidents"))
+				Expect(*queryInput.IndexName).To(Equal("ActiveIndex"))
+				Expect(queryInput.ExpressionAttributeNames).To(Equal(map[string]string{
+					"#0": "isActive",
+				}))
+				return true
+			}), mock.Anything).Return(queryOutput, nil)
+
+			result, err := subject.GetActiveIncidents(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].ID).To(Equal("abc123"))
+			Expect(result[0].Jurisdiction).To(Equal("Chesterfield"))
+			Expect(result[0].LastKnownStatus).To(Equal("active"))
+			Expect(result[0].Latitude).To(Equal(37.4521))
+			Expect(result[0].Longitude).To(Equal(-77.6512))
+			Expect(result[0].FirstSeen.Equal(time.Date(2030, 1, 1, 6, 0, 0, 0, time.UTC))).To(BeTrue())
+		})
+	})
+
+	Describe("SaveIncident()", func() {
+		It("stores an object in dynamo", func() {
+			expectedID := saved_incidents.IncidentID("Chesterfield", "Vehicle Crash", "Crash", "HULL ST RD AT COALFIELD RD", "EB")
+
+			dynamoDBMock.On("PutItem", ctx, mock.MatchedBy(func(putInput *dynamodb.PutItemInput) bool {
+				Expect(*putInput.TableName).To(Equal("SavedIncidents"))
+				Expect(putInput.Item["id"]).To(Equal(&types.AttributeValueMemberS{Value: expectedID}))
+				Expect(putInput.Item["jurisdiction"]).To(Equal(&types.AttributeValueMemberS{Value: "Chesterfield"}))
+				Expect(putInput.Item["lastKnownStatus"]).To(Equal(&types.AttributeValueMemberS{Value: "active"}))
+				Expect(putInput.Item["latitude"]).To(Equal(&types.AttributeValueMemberN{Value: "37.4521"}))
+				Expect(putInput.Item["longitude"]).To(Equal(&types.AttributeValueMemberN{Value: "-77.6512"}))
+				Expect(putInput.Item["firstSeen"]).To(Equal(&types.AttributeValueMemberS{Value: "2030-01-01T06:30:00Z"}))
+				Expect(putInput.Item["isActive"]).To(Equal(&types.AttributeValueMemberS{Value: "-"}))
+				return true
+			}), mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+			err := subject.SaveIncident(ctx, incident)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Describe("UpdateStatus()", func() {
+		It("resolves an incident", func() {
+			incident.ID = "abc123"
+			incident.LastKnownStatus = "resolved"
+
+			dynamoDBMock.On("UpdateItem", ctx, mock.MatchedBy(func(updateInput *dynamodb.UpdateItemInput) bool {
+				Expect(*updateInput.TableName).To(Equal("SavedIncidents"))
+				Expect(*updateInput.UpdateExpression).To(Equal("REMOVE #0\nSET #1 = :0, #2 = :1\n"))
+				Expect(updateInput.Key["jurisdiction"]).To(Equal(&types.AttributeValueMemberS{Value: "Chesterfield"}))
+				Expect(updateInput.Key["id"]).To(Equal(&types.AttributeValueMemberS{Value: "abc123"}))
+				Expect(updateInput.ExpressionAttributeNames).To(Equal(map[string]string{
+					"#0": "isActive",
+					"#1": "lastKnownStatus",
+					"#2": "resolved",
+				}))
+				Expect(updateInput.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
+					":0": &types.AttributeValueMemberS{Value: "resolved"},
+					":1": &types.AttributeValueMemberS{Value: "2030-01-01T06:30:00Z"},
+				}))
+				return true
+			}), mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+			err := subject.UpdateStatus(ctx, incident)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
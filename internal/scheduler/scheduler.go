@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+type Task func(ctx context.Context) error
+
+type Config struct {
+	// time between the end of one run and the start of the next
+	Interval time.Duration
+	// up to this much random delay is added to every wait, so a fleet of
+	// pollers doesn't hit the county API in lockstep
+	Jitter time.Duration
+	// consecutive failures double the wait, up to this limit
+	MaxBackoff time.Duration
+}
+
+type Scheduler struct {
+	config Config
+	task   Task
+	after  func(time.Duration) <-chan time.Time
+	random func() float64
+}
+
+func New(config Config, task Task) *Scheduler {
+	return NewWithTimer(config, task, time.After, rand.Float64)
+}
+
+func NewWithTimer(config Config, task Task, after func(time.Duration) <-chan time.Time, random func() float64) *Scheduler {
+	return &Scheduler{
+		config: config,
+		task:   task,
+		after:  after,
+		random: random,
+	}
+}
+
+func (scheduler *Scheduler) nextDelay(failures int) time.Duration {
+	// a limit below the interval would make failures poll faster
+	limit := max(scheduler.config.Interval, scheduler.config.MaxBackoff)
+	delay := scheduler.config.Interval
+	for i := 0; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+
+	return delay + time.Duration(scheduler.random()*float64(scheduler.config.Jitter))
+}
+
+func (scheduler *Scheduler) runTask(ctx context.Context) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("task panicked: %v", recovered)
+		}
+	}()
+	return scheduler.task(ctx)
+}
+
+// Run calls the task until ctx is canceled. A run that is already in
+// progress when ctx is canceled is allowed to finish, so a shutdown never
+// leaves a harvest half written.
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	failures := 0
+	for ctx.Err() == nil {
+		err := scheduler.runTask(context.WithoutCancel(ctx))
+		if err != nil {
+			failures++
+			log.Printf("Scheduled run failed (%d in a row), %+v\n", failures, err)
+		} else {
+			failures = 0
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		delay := scheduler.nextDelay(failures)
+		log.Printf("Next run in %v\n", delay)
+
+		select {
+		case <-ctx.Done():
+		case <-scheduler.after(delay):
+		}
+	}
+	log.Println("Scheduler stopped")
+}
@@ -0,0 +1,24 @@
+package scheduler_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = BeforeSuite(func() {
+	log.SetOutput(io.Discard)
+})
+
+var _ = AfterSuite(func() {
+	log.SetOutput(os.Stdout)
+})
+
+func TestScheduler(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Scheduler Suite")
+}
@@ -0,0 +1,133 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/scheduler"
+)
+
+var _ = Describe("Scheduler", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var waits []time.Duration
+	var results []error
+	var runs int
+	var config scheduler.Config
+
+	// stops the scheduler once every queued result has been handed out
+	task := func(ctx context.Context) error {
+		Expect(ctx.Err()).ShouldNot(HaveOccurred())
+		result := results[runs]
+		runs++
+		if runs == len(results) {
+			cancel()
+		}
+		if result != nil && result.Error() == "panic" {
+			panic("boom")
+		}
+		return result
+	}
+
+	after := func(delay time.Duration) <-chan time.Time {
+		waits = append(waits, delay)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.TODO())
+		waits = nil
+		results = nil
+		runs = 0
+		config = scheduler.Config{
+			Interval:   5 * time.Minute,
+			Jitter:     time.Minute,
+			MaxBackoff: 30 * time.Minute,
+		}
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	run := func(random float64) {
+		subject := scheduler.NewWithTimer(config, task, after, func() float64 { return random })
+		subject.Run(ctx)
+	}
+
+	It("waits the interval between successful runs", func() {
+		results = []error{nil, nil, nil}
+
+		run(0)
+
+		Expect(runs).To(Equal(3))
+		Expect(waits).To(Equal([]time.Duration{5 * time.Minute, 5 * time.Minute}))
+	})
+
+	It("adds jitter to every wait", func() {
+		results = []error{nil, nil}
+
+		run(0.5)
+
+		Expect(waits).To(Equal([]time.Duration{5*time.Minute + 30*time.Second}))
+	})
+
+	It("backs off on consecutive failures and resets on success", func() {
+		failure := errors.New("county api is down")
+		results = []error{failure, failure, failure, failure, nil, nil}
+
+		run(0)
+
+		Expect(runs).To(Equal(6))
+		Expect(waits).To(Equal([]time.Duration{
+			10 * time.Minute,
+			20 * time.Minute,
+			30 * time.Minute,
+			30 * time.Minute,
+			5 * time.Minute,
+		}))
+	})
+
+	It("never waits less than the interval after a failure", func() {
+		config.MaxBackoff = time.Minute
+		failure := errors.New("county api is down")
+		results = []error{failure, failure, nil}
+
+		run(0)
+
+		Expect(waits).To(Equal([]time.Duration{5 * time.Minute, 5 * time.Minute}))
+	})
+
+	It("keeps running after a panic", func() {
+		results = []error{errors.New("panic"), nil}
+
+		run(0)
+
+		Expect(runs).To(Equal(2))
+		Expect(waits).To(Equal([]time.Duration{10 * time.Minute}))
+	})
+
+	It("does not run once canceled", func() {
+		results = []error{nil}
+		cancel()
+
+		run(0)
+
+		Expect(runs).To(Equal(0))
+	})
+
+	It("lets an in-flight run finish after cancellation", func() {
+		results = []error{nil}
+
+		run(0)
+
+		Expect(runs).To(Equal(1))
+		Expect(waits).To(BeEmpty())
+	})
+})
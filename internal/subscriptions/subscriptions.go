@@ -0,0 +1,393 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geofence"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/rules"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+)
+
+const (
+	subscriptionsTableName = "Subscriptions"
+	clockLayout            = "15:04"
+)
+
+type DynamoDB interface {
+	PutItem(ctx context.Context,
+		params *dynamodb.PutItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context,
+		params *dynamodb.QueryInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context,
+		params *dynamodb.ScanInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	DeleteItem(ctx context.Context,
+		params *dynamodb.DeleteItemInput,
+		optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+type SubscriptionDataAccess struct {
+	Service DynamoDB
+}
+
+type Client interface {
+	GetAllSubscriptions(ctx context.Context) ([]Subscription, error)
+	GetSubscriptions(ctx context.Context, userID string) ([]Subscription, error)
+	SaveSubscription(ctx context.Context, subscription Subscription) error
+	DeleteSubscription(ctx context.Context, userID string, subscriptionID string) error
+}
+
+type Channel struct {
+	Type   string `dynamodbav:"type" json:"type"`
+	Target string `dynamodbav:"target" json:"target"`
+}
+
+// QuietHours is a daily window, in the county's local time, during which a
+// subscriber doesn't want to be notified. The window may wrap past midnight.
+type QuietHours struct {
+	Start string `dynamodbav:"start" json:"start"`
+	End   string `dynamodbav:"end" json:"end"`
+}
+
+// RateLimit caps how many messages each of a subscription's recipients gets
+// in a window, e.g. 3 per "1h". Messages over the limit are held and sent
+// together once the window is over.
+type RateLimit struct {
+	Count  int    `dynamodbav:"count" json:"count"`
+	Window string `dynamodbav:"window" json:"window"`
+}
+
+// Digest collects a subscription's messages over a window, e.g. "30m", and
+// sends them as one.
+type Digest struct {
+	Window string `dynamodbav:"window" json:"window"`
+}
+
+// how often a subscription gets an activity report, none when empty
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+)
+
+type Subscription struct {
+	UserID         string          `dynamodbav:"userId"`
+	SubscriptionID string          `dynamodbav:"subscriptionId"`
+	Filters        rules.RuleSet   `dynamodbav:"filters,omitempty"`
+	Channels       []Channel       `dynamodbav:"channels,omitempty"`
+	QuietHours     *QuietHours     `dynamodbav:"quietHours,omitempty"`
+	RateLimit      *RateLimit      `dynamodbav:"rateLimit,omitempty"`
+	Digest         *Digest         `dynamodbav:"digest,omitempty"`
+	Near           *geofence.Fence `dynamodbav:"near,omitempty"`
+	Report         string          `dynamodbav:"report,omitempty"`
+	Disabled       bool            `dynamodbav:"disabled,omitempty"`
+}
+
+func parseWindow(value string) (time.Duration, error) {
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q, expected a duration such as 30m", value)
+	}
+	return window, nil
+}
+
+func minuteOfDay(value string) (int, error) {
+	parsed, err := time.Parse(clockLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+func (quietHours *QuietHours) Validate() error {
+	if _, err := minuteOfDay(quietHours.Start); err != nil {
+		return err
+	}
+	_, err := minuteOfDay(quietHours.End)
+	return err
+}
+
+func (rateLimit *RateLimit) Validate() error {
+	if rateLimit.Count <= 0 {
+		return fmt.Errorf("a rate limit needs a positive count")
+	}
+	_, err := parseWindow(rateLimit.Window)
+	return err
+}
+
+func (rateLimit *RateLimit) WindowDuration() time.Duration {
+	window, _ := parseWindow(rateLimit.Window)
+	return window
+}
+
+func (digest *Digest) Validate() error {
+	_, err := parseWindow(digest.Window)
+	return err
+}
+
+func (digest *Digest) WindowDuration() time.Duration {
+	window, _ := parseWindow(digest.Window)
+	return window
+}
+
+func ValidateReport(report string) error {
+	switch report {
+	case "", ReportDaily, ReportWeekly:
+		return nil
+	default:
+		return fmt.Errorf("invalid report %q, expected %s or %s", report, ReportDaily, ReportWeekly)
+	}
+}
+
+// WindowEnd is when the window that now falls in is over, windows are lined
+// up on the county's local midnight
+func WindowEnd(now time.Time, window time.Duration) time.Time {
+	if window <= 0 {
+		return now
+	}
+	local := now.In(chesterfield.LocalTime)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, chesterfield.LocalTime)
+	elapsed := local.Sub(midnight)
+	return midnight.Add((elapsed/window + 1) * window)
+}
+
+func (quietHours *QuietHours) Contains(now time.Time) bool {
+	if quietHours == nil {
+		return false
+	}
+	start, err := minuteOfDay(quietHours.Start)
+	if err != nil {
+		return false
+	}
+	end, err := minuteOfDay(quietHours.End)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(chesterfield.LocalTime)
+	minute := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// Ends is when the quiet hours that now falls in are over
+func (quietHours *QuietHours) Ends(now time.Time) time.Time {
+	end, err := minuteOfDay(quietHours.End)
+	if err != nil {
+		return now
+	}
+	local := now.In(chesterfield.LocalTime)
+	ends := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, chesterfield.LocalTime)
+	if !ends.After(local) {
+		ends = ends.AddDate(0, 0, 1)
+	}
+	return ends
+}
+
+// Matches reports whether the subscriber wants to hear about the event.
+// Filters behave like a rules file, with no filters every event matches. A
+// subscription with a fence only hears about calls placed inside it. Quiet
+// hours don't stop a match, they only hold the message back.
+func (subscription *Subscription) Matches(event lifecycle.Event) bool {
+	if subscription.Disabled {
+		return false
+	}
+	if subscription.Near != nil {
+		location := geofence.Point{Latitude: event.NewCall.Latitude, Longitude: event.NewCall.Longitude}
+		if !subscription.Near.Contains(location) {
+			return false
+		}
+	}
+	return len(subscription.Filters.Match(event)) > 0
+}
+
+// Covers reports whether a call happened where the subscriber is watching,
+// inside their fence and on the streets and areas of their filters. It's
+// what the activity reports are about, whatever else the filters ask for.
+func (subscription *Subscription) Covers(call saved_calls.SavedCall) bool {
+	if subscription.Disabled {
+		return false
+	}
+	if subscription.Near != nil {
+		location := geofence.Point{Latitude: call.Latitude, Longitude: call.Longitude}
+		if !subscription.Near.Contains(location) {
+			return false
+		}
+	}
+	return subscription.Filters.Covers(call)
+}
+
+func New(config aws.Config) *SubscriptionDataAccess {
+	return &SubscriptionDataAccess{
+		Service: dynamodb.NewFromConfig(config),
+	}
+}
+
+func NewWithClient(dynamoDB DynamoDB) *SubscriptionDataAccess {
+	return &SubscriptionDataAccess{
+		Service: dynamoDB,
+	}
+}
+
+// filters are stored without their compiled patterns, so they are parsed
+// again on the way out. A fence written around SaveSubscription could break
+// matching, so a subscription with an invalid one is left out.
+func unmarshalSubscriptions(items []map[string]types.AttributeValue) ([]Subscription, error) {
+	records := []Subscription{}
+	err := attributevalue.UnmarshalListOfMaps(items, &records)
+	if err != nil {
+		return nil, err
+	}
+	valid := make([]Subscription, 0, len(records))
+	for _, record := range records {
+		if record.Near != nil {
+			if err := record.Near.Validate(); err != nil {
+				log.Printf("Skipping subscription %s/%s, invalid fence: %v\n", record.UserID, record.SubscriptionID, err)
+				continue
+			}
+		}
+		record.Filters, err = rules.Compile(record.Filters)
+		if err != nil {
+			return nil, err
+		}
+		valid = append(valid, record)
+	}
+	return valid, nil
+}
+
+func (dao *SubscriptionDataAccess) GetAllSubscriptions(ctx context.Context) ([]Subscription, error) {
+	params := &dynamodb.ScanInput{
+		TableName: aws.String(subscriptionsTableName),
+	}
+
+	var result []Subscription
+
+	paginator := dynamodb.NewScanPaginator(dao.Service, params, func(spo *dynamodb.ScanPaginatorOptions) {})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := unmarshalSubscriptions(page.Items)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, records...)
+	}
+
+	return result, nil
+}
+
+func (dao *SubscriptionDataAccess) GetSubscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	keyExpression := expression.Key("userId").Equal(expression.Value(userID))
+	expr, err := expression.
+		NewBuilder().
+		WithKeyCondition(keyExpression).
+		Build()
+
+	if err != nil {
+		return nil, err
+	}
+
+	params := &dynamodb.QueryInput{
+		TableName:                 aws.String(subscriptionsTableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	var result []Subscription
+
+	paginator := dynamodb.NewQueryPaginator(dao.Service, params, func(qpo *dynamodb.QueryPaginatorOptions) {})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := unmarshalSubscriptions(page.Items)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, records...)
+	}
+
+	return result, nil
+}
+
+func (dao *SubscriptionDataAccess) SaveSubscription(ctx context.Context, subscription Subscription) error {
+	if subscription.UserID == "" || subscription.SubscriptionID == "" {
+		return fmt.Errorf("subscription requires a user and subscription id")
+	}
+	if subscription.QuietHours != nil {
+		err := subscription.QuietHours.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if subscription.RateLimit != nil {
+		err := subscription.RateLimit.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if subscription.Digest != nil {
+		err := subscription.Digest.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if subscription.Near != nil {
+		err := subscription.Near.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	err := ValidateReport(subscription.Report)
+	if err != nil {
+		return err
+	}
+	_, err = rules.Compile(subscription.Filters)
+	if err != nil {
+		return err
+	}
+
+	item, err := attributevalue.MarshalMap(subscription)
+	if err != nil {
+		return err
+	}
+
+	_, err = dao.Service.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(subscriptionsTableName),
+		Item:      item,
+	})
+
+	return err
+}
+
+func (dao *SubscriptionDataAccess) DeleteSubscription(ctx context.Context, userID string, subscriptionID string) error {
+	_, err := dao.Service.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(subscriptionsTableName),
+		Key: map[string]types.AttributeValue{
+			"userId":         &types.AttributeValueMemberS{Value: userID},
+			"subscriptionId": &types.AttributeValueMemberS{Value: subscriptionID},
+		},
+	})
+
+	return err
+}
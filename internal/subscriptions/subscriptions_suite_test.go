@@ -0,0 +1,46 @@
+package subscriptions_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBMock struct {
+	mock.Mock
+}
+
+func (dynamoDBMock *DynamoDBMock) PutItem(ctx context.Context, input *dynamodb.PutItemInput, options ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) Query(ctx context.Context, input *dynamodb.QueryInput, options ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) Scan(ctx context.Context, input *dynamodb.ScanInput, options ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
+func (dynamoDBMock *DynamoDBMock) DeleteItem(ctx context.Context, input *dynamodb.DeleteItemInput, options ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	args := dynamoDBMock.Called(ctx, input, options)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+
+var subject *subscriptions.SubscriptionDataAccess
+var dynamoDBMock *DynamoDBMock
+
+var _ = BeforeEach(func() {
+	dynamoDBMock = new(DynamoDBMock)
+	subject = subscriptions.NewWithClient(dynamoDBMock)
+})
+
+func TestSubscriptions(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Subscriptions Suite")
+}
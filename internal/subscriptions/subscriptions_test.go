@@ -0,0 +1,446 @@
+package subscriptions_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geofence"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/rules"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+var localLocation, _ = time.LoadLocation("America/New_York")
+
+var _ = Describe("Subscriptions", func() {
+	var ctx context.Context
+	var subscriptionItem map[string]types.AttributeValue
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		subscriptionItem = map[string]types.AttributeValue{
+			"userId":         &types.AttributeValueMemberS{Value: "kevin"},
+			"subscriptionId": &types.AttributeValueMemberS{Value: "home"},
+			"filters": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"name":              &types.AttributeValueMemberS{Value: "burglaries"},
+					"streetNames":       &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "FAKE RD"}}},
+					"callReasonPattern": &types.AttributeValueMemberS{Value: "burglary"},
+				}},
+			}},
+			"channels": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+				&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"type":   &types.AttributeValueMemberS{Value: "sms"},
+					"target": &types.AttributeValueMemberS{Value: "+18045550100"},
+				}},
+			}},
+			"quietHours": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"start": &types.AttributeValueMemberS{Value: "22:00"},
+				"end":   &types.AttributeValueMemberS{Value: "07:00"},
+			}},
+			"rateLimit": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"count":  &types.AttributeValueMemberN{Value: "3"},
+				"window": &types.AttributeValueMemberS{Value: "1h"},
+			}},
+		}
+	})
+
+	Describe("GetAllSubscriptions()", func() {
+		It("scans every subscription", func() {
+			dynamoDBMock.On("Scan", ctx, mock.MatchedBy(func(input *dynamodb.ScanInput) bool {
+				Expect(*input.TableName).To(Equal("Subscriptions"))
+				return true
+			}), mock.Anything).Return(&dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{subscriptionItem},
+				Count: 1,
+			}, nil)
+
+			result, err := subject.GetAllSubscriptions(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].UserID).To(Equal("kevin"))
+			Expect(result[0].SubscriptionID).To(Equal("home"))
+			Expect(result[0].Channels).To(Equal([]subscriptions.Channel{{Type: "sms", Target: "+18045550100"}}))
+			Expect(result[0].QuietHours).To(Equal(&subscriptions.QuietHours{Start: "22:00", End: "07:00"}))
+			Expect(result[0].RateLimit).To(Equal(&subscriptions.RateLimit{Count: 3, Window: "1h"}))
+			Expect(len(result[0].Filters)).To(Equal(1))
+			Expect(result[0].Filters[0].StreetNames).To(Equal([]string{"FAKE RD"}))
+
+			// the stored pattern must be usable for matching
+			Expect(result[0].Filters[0].Matches(lifecycle.Event{
+				Type:    lifecycle.NewCall,
+				NewCall: saved_calls.SavedCall{StreetName: "FAKE RD", CallReason: "BURGLARY", LastKnownStatus: "dispatched"},
+			})).To(BeTrue())
+			Expect(result[0].Filters[0].Matches(lifecycle.Event{
+				Type:    lifecycle.NewCall,
+				NewCall: saved_calls.SavedCall{StreetName: "FAKE RD", CallReason: "DOMESTIC", LastKnownStatus: "dispatched"},
+			})).To(BeFalse())
+		})
+
+		It("reads fences", func() {
+			subscriptionItem["near"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"center": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"latitude":  &types.AttributeValueMemberN{Value: "37.3771"},
+					"longitude": &types.AttributeValueMemberN{Value: "-77.505"},
+				}},
+				"radiusMeters": &types.AttributeValueMemberN{Value: "500"},
+			}}
+			dynamoDBMock.On("Scan", ctx, mock.Anything, mock.Anything).Return(&dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{subscriptionItem},
+				Count: 1,
+			}, nil)
+
+			result, err := subject.GetAllSubscriptions(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result[0].Near).To(Equal(&geofence.Fence{
+				Center:       &geofence.Point{Latitude: 37.3771, Longitude: -77.505},
+				RadiusMeters: 500,
+			}))
+		})
+
+		It("leaves out subscriptions with an invalid fence", func() {
+			broken := map[string]types.AttributeValue{}
+			for key, value := range subscriptionItem {
+				broken[key] = value
+			}
+			broken["subscriptionId"] = &types.AttributeValueMemberS{Value: "work"}
+			broken["near"] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"polygon": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"type": &types.AttributeValueMemberS{Value: "Polygon"},
+					"coordinates": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+						&types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "-77.5"}}},
+						}},
+					}},
+				}},
+			}}
+			dynamoDBMock.On("Scan", ctx, mock.Anything, mock.Anything).Return(&dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{broken, subscriptionItem},
+				Count: 2,
+			}, nil)
+
+			result, err := subject.GetAllSubscriptions(ctx)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+			Expect(result[0].SubscriptionID).To(Equal("home"))
+		})
+	})
+
+	Describe("GetSubscriptions()", func() {
+		It("queries a single user", func() {
+			dynamoDBMock.On("Query", ctx, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+				Expect(*input.TableName).To(Equal("Subscriptions"))
+				Expect(*input.KeyConditionExpression).To(Equal("#0 = :0"))
+				Expect(input.ExpressionAttributeNames).To(Equal(map[string]string{"#0": "userId"}))
+				Expect(input.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
+					":0": &types.AttributeValueMemberS{Value: "kevin"},
+				}))
+				return true
+			}), mock.Anything).Return(&dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{subscriptionItem},
+				Count: 1,
+			}, nil)
+
+			result, err := subject.GetSubscriptions(ctx, "kevin")
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(result)).To(Equal(1))
+			Expect(result[0].SubscriptionID).To(Equal("home"))
+		})
+	})
+
+	Describe("SaveSubscription()", func() {
+		var subscription subscriptions.Subscription
+
+		BeforeEach(func() {
+			subscription = subscriptions.Subscription{
+				UserID:         "kevin",
+				SubscriptionID: "home",
+				Filters: rules.RuleSet{
+					{Name: "burglaries", StreetNames: []string{"FAKE RD"}, CallReasonPattern: "burglary"},
+				},
+				Channels:   []subscriptions.Channel{{Type: "sms", Target: "+18045550100"}},
+				QuietHours: &subscriptions.QuietHours{Start: "22:00", End: "07:00"},
+				RateLimit:  &subscriptions.RateLimit{Count: 3, Window: "1h"},
+			}
+		})
+
+		It("stores a subscription", func() {
+			dynamoDBMock.On("PutItem", ctx, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+				Expect(*input.TableName).To(Equal("Subscriptions"))
+				Expect(input.Item).To(Equal(subscriptionItem))
+				return true
+			}), mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("requires keys", func() {
+			subscription.UserID = ""
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(HaveOccurred())
+			Expect(dynamoDBMock.Calls).To(BeEmpty())
+		})
+
+		It("validates quiet hours", func() {
+			subscription.QuietHours.Start = "10pm"
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal(`invalid time of day "10pm", expected HH:MM`))
+		})
+
+		It("validates rate limits", func() {
+			subscription.RateLimit = &subscriptions.RateLimit{Count: 3, Window: "an hour"}
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(MatchError(`invalid window "an hour", expected a duration such as 30m`))
+			Expect(dynamoDBMock.Calls).To(BeEmpty())
+		})
+
+		It("requires a rate limit count", func() {
+			subscription.RateLimit = &subscriptions.RateLimit{Window: "1h"}
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(MatchError("a rate limit needs a positive count"))
+		})
+
+		It("validates digests", func() {
+			subscription.Digest = &subscriptions.Digest{Window: "0s"}
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(MatchError(`invalid window "0s", expected a duration such as 30m`))
+		})
+
+		It("validates fences", func() {
+			subscription.Near = &geofence.Fence{Center: &geofence.Point{Latitude: 37.3771, Longitude: -77.5050}}
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(MatchError("a fence around a point needs a positive radius"))
+			Expect(dynamoDBMock.Calls).To(BeEmpty())
+		})
+
+		It("validates reports", func() {
+			subscription.Report = "hourly"
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(MatchError(`invalid report "hourly", expected daily or weekly`))
+		})
+
+		It("validates filters", func() {
+			subscription.Filters[0].CallReasonPattern = "("
+
+			err := subject.SaveSubscription(ctx, subscription)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("DeleteSubscription()", func() {
+		It("deletes by key", func() {
+			dynamoDBMock.On("DeleteItem", ctx, mock.MatchedBy(func(input *dynamodb.DeleteItemInput) bool {
+				Expect(*input.TableName).To(Equal("Subscriptions"))
+				Expect(input.Key).To(Equal(map[string]types.AttributeValue{
+					"userId":         &types.AttributeValueMemberS{Value: "kevin"},
+					"subscriptionId": &types.AttributeValueMemberS{Value: "home"},
+				}))
+				return true
+			}), mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+			Expect(subject.DeleteSubscription(ctx, "kevin", "home")).To(Succeed())
+		})
+	})
+
+	Describe("QuietHours", func() {
+		DescribeTable("Contains()",
+			func(start string, end string, hour int, minute int, expected bool) {
+				quietHours := &subscriptions.QuietHours{Start: start, End: end}
+				now := time.Date(2022, 3, 23, hour, minute, 0, 0, localLocation)
+
+				Expect(quietHours.Contains(now)).To(Equal(expected))
+			},
+			Entry("before an overnight window", "22:00", "07:00", 21, 59, false),
+			Entry("at the start of an overnight window", "22:00", "07:00", 22, 0, true),
+			Entry("after midnight in an overnight window", "22:00", "07:00", 3, 0, true),
+			Entry("at the end of an overnight window", "22:00", "07:00", 7, 0, false),
+			Entry("inside a daytime window", "09:00", "17:00", 12, 30, true),
+			Entry("outside a daytime window", "09:00", "17:00", 18, 0, false),
+		)
+
+		It("uses the county's local time", func() {
+			quietHours := &subscriptions.QuietHours{Start: "22:00", End: "07:00"}
+
+			// 03:00 UTC is 23:00 in Chesterfield during daylight saving time
+			Expect(quietHours.Contains(time.Date(2022, 7, 1, 3, 0, 0, 0, time.UTC))).To(BeTrue())
+		})
+
+		DescribeTable("Ends()",
+			func(start string, end string, hour int, expectedDay int, expectedHour int) {
+				quietHours := &subscriptions.QuietHours{Start: start, End: end}
+				now := time.Date(2022, 3, 23, hour, 0, 0, 0, localLocation)
+
+				Expect(quietHours.Ends(now)).To(BeTemporally("==", time.Date(2022, 3, expectedDay, expectedHour, 0, 0, 0, localLocation)))
+			},
+			Entry("before midnight in an overnight window", "22:00", "07:00", 23, 24, 7),
+			Entry("after midnight in an overnight window", "22:00", "07:00", 3, 23, 7),
+			Entry("inside a daytime window", "09:00", "17:00", 12, 23, 17),
+		)
+
+		It("is never quiet when unset", func() {
+			var quietHours *subscriptions.QuietHours
+
+			Expect(quietHours.Contains(time.Now())).To(BeFalse())
+		})
+	})
+
+	DescribeTable("WindowEnd()",
+		func(hour int, minute int, window time.Duration, expectedHour int, expectedMinute int) {
+			now := time.Date(2022, 3, 23, hour, minute, 0, 0, localLocation)
+
+			Expect(subscriptions.WindowEnd(now, window)).To(BeTemporally("==", time.Date(2022, 3, 23, expectedHour, expectedMinute, 0, 0, localLocation)))
+		},
+		Entry("inside a window", 12, 10, 30*time.Minute, 12, 30),
+		Entry("at the start of a window", 12, 30, 30*time.Minute, 13, 0),
+		Entry("in an hour long window", 9, 59, time.Hour, 10, 0),
+	)
+
+	Describe("Matches()", func() {
+		event := lifecycle.Event{
+			Type:    lifecycle.OnScene,
+			OldCall: saved_calls.SavedCall{LastKnownStatus: "dispatched"},
+			NewCall: saved_calls.SavedCall{LastKnownStatus: "on scene", StreetName: "FAKE RD"},
+		}
+
+		It("matches every event without filters", func() {
+			subscription := subscriptions.Subscription{}
+
+			Expect(subscription.Matches(event)).To(BeTrue())
+		})
+
+		It("applies filters", func() {
+			subscription := subscriptions.Subscription{
+				Filters: rules.RuleSet{{StreetNames: []string{"EXAMPLE CT"}}},
+			}
+
+			Expect(subscription.Matches(event)).To(BeFalse())
+		})
+
+		It("filters by event", func() {
+			subscription := subscriptions.Subscription{
+				Filters: rules.RuleSet{{Events: []string{"new"}}},
+			}
+
+			Expect(subscription.Matches(event)).To(BeFalse())
+		})
+
+		It("still matches during quiet hours", func() {
+			subscription := subscriptions.Subscription{
+				QuietHours: &subscriptions.QuietHours{Start: "11:00", End: "13:00"},
+			}
+
+			Expect(subscription.Matches(event)).To(BeTrue())
+		})
+
+		It("is silent when disabled", func() {
+			subscription := subscriptions.Subscription{Disabled: true}
+
+			Expect(subscription.Matches(event)).To(BeFalse())
+		})
+
+		Describe("near a point", func() {
+			home := geofence.Point{Latitude: 37.3771, Longitude: -77.5050}
+			subscription := subscriptions.Subscription{
+				Near: &geofence.Fence{Center: &home, RadiusMeters: 500},
+			}
+
+			DescribeTable("matches calls inside the radius",
+				func(latitude float64, longitude float64, expected bool) {
+					nearbyEvent := event
+					nearbyEvent.NewCall.Latitude = latitude
+					nearbyEvent.NewCall.Longitude = longitude
+
+					Expect(subscription.Matches(nearbyEvent)).To(Equal(expected))
+				},
+				Entry("around the corner", 37.3790, -77.5030, true),
+				Entry("across the county", 37.4500, -77.6500, false),
+				Entry("not geocoded", 0.0, 0.0, false),
+			)
+
+			It("still applies filters", func() {
+				filtered := subscription
+				filtered.Filters = rules.RuleSet{{Events: []string{"new"}}}
+				nearbyEvent := event
+				nearbyEvent.NewCall.Latitude = home.Latitude
+				nearbyEvent.NewCall.Longitude = home.Longitude
+
+				Expect(filtered.Matches(nearbyEvent)).To(BeFalse())
+			})
+		})
+
+		It("matches calls inside a polygon", func() {
+			subscription := subscriptions.Subscription{
+				Near: &geofence.Fence{Polygon: &geofence.Polygon{
+					Type: "Polygon",
+					Coordinates: [][][]float64{
+						{{-77.53, 37.37}, {-77.51, 37.37}, {-77.51, 37.39}, {-77.53, 37.39}, {-77.53, 37.37}},
+					},
+				}},
+			}
+			inside := event
+			inside.NewCall.Latitude, inside.NewCall.Longitude = 37.375, -77.525
+			outside := event
+			outside.NewCall.Latitude, outside.NewCall.Longitude = 37.40, -77.525
+
+			Expect(subscription.Matches(inside)).To(BeTrue())
+			Expect(subscription.Matches(outside)).To(BeFalse())
+		})
+	})
+
+	Describe("Covers()", func() {
+		call := saved_calls.SavedCall{StreetName: "FAKE RD", Area: "11", Latitude: 37.3771, Longitude: -77.5050}
+
+		It("covers the streets of the filters, whatever else they ask for", func() {
+			subscription := subscriptions.Subscription{
+				Filters: rules.RuleSet{{StreetNames: []string{"FAKE RD"}, Events: []string{"new"}, Priorities: []string{"1"}}},
+			}
+
+			Expect(subscription.Covers(call)).To(BeTrue())
+			Expect(subscription.Covers(saved_calls.SavedCall{StreetName: "EXAMPLE CT"})).To(BeFalse())
+		})
+
+		It("covers the fence", func() {
+			subscription := subscriptions.Subscription{
+				Near: &geofence.Fence{Center: &geofence.Point{Latitude: 37.3771, Longitude: -77.5050}, RadiusMeters: 500},
+			}
+
+			Expect(subscription.Covers(call)).To(BeTrue())
+			Expect(subscription.Covers(saved_calls.SavedCall{StreetName: "FAKE RD"})).To(BeFalse())
+		})
+
+		It("covers nothing when disabled", func() {
+			subscription := subscriptions.Subscription{Disabled: true}
+
+			Expect(subscription.Covers(call)).To(BeFalse())
+		})
+	})
+})
@@ -2,91 +2,126 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
 
-	"github.com/twilio/twilio-go"
-	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/ledger"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/lifecycle"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
 )
 
-var twilioClient *twilio.RestClient
-var toNumber string
-var fromNumber string
+var scheduler *notifier.Scheduler
+var templates *notifier.Templates
+var subscriptionDao subscriptions.Client
+
+// the subscriber set up through the environment, if any
+var defaultSubscription *subscriptions.Subscription
 
 func init() {
-	toNumber = os.Getenv("SMS_TO")
-	fromNumber = os.Getenv("SMS_FROM")
-	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
-	apiKey := os.Getenv("TWILIO_API_KEY")
-	apiSecret := os.Getenv("TWILIO_API_SECRET")
-
-	twilioClient = twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username:   apiKey,
-		Password:   apiSecret,
-		AccountSid: accountSid,
-	})
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic("unable to load aws config")
+	}
+	dispatcher := notifier.NewDispatcher(notifier.FromEnv()).WithLedger(ledger.New(cfg))
+	scheduler = notifier.NewScheduler(dispatcher, outbox.New(cfg), time.Now)
+	subscriptionDao = subscriptions.New(cfg)
+
+	templates = notifier.DefaultTemplates()
+	if templatesFile := os.Getenv("TEMPLATES_FILE"); templatesFile != "" {
+		templates, err = notifier.LoadTemplates(templatesFile)
+		if err != nil {
+			panic(fmt.Sprintf("unable to load templates: %v", err))
+		}
+	}
+
+	defaultSubscription, err = notifier.DefaultSubscription()
+	if err != nil {
+		panic(err.Error())
+	}
 }
 
-type StreamRecord struct {
-	Records []struct {
-		EventID      string `json:"eventID"`
-		EventName    string `json:"eventName"`
-		EventVersion string `json:"eventVersion"`
-		EventSource  string `json:"eventSource"`
-		AwsRegion    string `json:"awsRegion"`
-		Dynamodb     struct {
-			OldImage struct {
-				LastKnownStatus struct {
-					S string `json:"S"`
-				} `json:"LastKnownStatus"`
-			} `json:"OldImage"`
-			NewImage struct {
-				LastKnownStatus struct {
-					S string `json:"S"`
-				} `json:"LastKnownStatus"`
-				Location struct {
-					S string `json:"S"`
-				} `json:"Location"`
-				CallReason struct {
-					S string `json:"S"`
-				} `json:"CallReason"`
-			} `json:"NewImage"`
-		} `json:"dynamodb"`
-	} `json:"Records"`
+func loadSubscriptions(ctx context.Context) ([]subscriptions.Subscription, error) {
+	stored, err := subscriptionDao.GetAllSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if defaultSubscription != nil {
+		stored = append(stored, *defaultSubscription)
+	}
+	return stored, nil
 }
 
-func SendSms(message string) error {
-	params := &openapi.CreateMessageParams{}
-	params.SetTo(toNumber)
-	params.SetFrom(fromNumber)
-	params.SetBody(message)
+// handleRecord sends a stream record's event to every matching channel. A
+// failed channel doesn't stop the others, the ledger keeps a retry of the
+// record from sending to the ones that worked.
+func handleRecord(ctx context.Context, record events.DynamoDBEventRecord, subscribers []subscriptions.Subscription) error {
+	oldCall, err := saved_calls.UnmarshalStreamImage(record.Change.OldImage)
+	if err != nil {
+		return err
+	}
+	newCall, err := saved_calls.UnmarshalStreamImage(record.Change.NewImage)
+	if err != nil {
+		return err
+	}
 
-	_, err := twilioClient.Api.CreateMessage(params)
-	return err
-}
+	callEvent, ok := lifecycle.Classify(record.EventName, oldCall, newCall)
+	if !ok {
+		return nil
+	}
 
-func HandleRequest(ctx context.Context, event StreamRecord) error {
-	for _, record := range event.Records {
-		newImage := record.Dynamodb.NewImage
-		oldImage := record.Dynamodb.OldImage
-
-		if oldImage.LastKnownStatus.S != newImage.LastKnownStatus.S {
-			message := fmt.Sprintf(
-				"Active call alert at %v: %v, %v",
-				newImage.Location.S,
-				newImage.CallReason.S,
-				newImage.LastKnownStatus.S,
-			)
-			err := SendSms(message)
+	errs := []error{}
+	for _, subscriber := range subscribers {
+		if !subscriber.Matches(callEvent) {
+			continue
+		}
+		log.Printf("Call %s %s matched subscription %s/%s\n", callEvent.NewCall.ID, callEvent.Type, subscriber.UserID, subscriber.SubscriptionID)
+
+		for _, channel := range subscriber.Channels {
+			message, err := templates.Render(channel.Type, callEvent)
+			if err == nil {
+				err = scheduler.Notify(ctx, subscriber, callEvent, channel, message)
+			}
 			if err != nil {
-				return err
+				errs = append(errs, fmt.Errorf("sending to %s/%s %s: %w", subscriber.UserID, subscriber.SubscriptionID, channel.Type, err))
 			}
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// Records that fail are reported back instead of failing the invocation.
+// The stream is retried from the first failed record, so the ones after it
+// are left for the retry.
+func HandleRequest(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	response := events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{}}
+
+	subscribers, err := loadSubscriptions(ctx)
+	if err != nil {
+		return response, err
+	}
+
+	for _, record := range event.Records {
+		err := handleRecord(ctx, record, subscribers)
+		if err != nil {
+			log.Printf("Error handling record %s: %v\n", record.Change.SequenceNumber, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+			break
+		}
+	}
 
-	return nil
+	return response, nil
 }
 
 func main() {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/ledger"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/reports"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/subscriptions"
+)
+
+var reporter *reports.Reporter
+var subscriptionDao subscriptions.Client
+
+// the subscriber set up through the environment, if any
+var defaultSubscription *subscriptions.Subscription
+
+type ReportEvent struct {
+	// daily or weekly, daily when it's left out
+	Period string `json:"period"`
+}
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic("unable to load aws config")
+	}
+	// reports aren't held for quiet hours or digests, they go out when the
+	// schedule runs
+	dispatcher := notifier.NewDispatcher(notifier.FromEnv()).WithLedger(ledger.New(cfg))
+	reporter = reports.New(saved_calls.New(cfg), dispatcher, time.Now)
+	subscriptionDao = subscriptions.New(cfg)
+
+	defaultSubscription, err = notifier.DefaultSubscription()
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// runs each morning for the daily reports and on mondays for the weekly ones
+func HandleRequest(ctx context.Context, event ReportEvent) error {
+	period := event.Period
+	if period == "" {
+		period = subscriptions.ReportDaily
+	}
+	if err := subscriptions.ValidateReport(period); err != nil {
+		return err
+	}
+
+	subscribers, err := subscriptionDao.GetAllSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if defaultSubscription != nil {
+		subscribers = append(subscribers, *defaultSubscription)
+	}
+
+	built, err := reporter.Send(ctx, subscribers, period)
+	log.Printf("Sent %d %s activity reports, %+v\n", built, period, err)
+	return err
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/api"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/dashboard"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/graph"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
+)
+
+var server *api.Server
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic("unable to load aws config")
+	}
+	calls := saved_calls.New(cfg)
+	schema, err := graph.NewSchema(calls, saved_incidents.New(cfg))
+	if err != nil {
+		panic(err)
+	}
+	server = api.New(calls)
+	server.Mount("/graphql", graph.NewHandler(schema))
+	server.Mount("/", dashboard.Handler())
+}
+
+func HandleRequest(ctx context.Context, request events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	return api.HandleFunctionURL(ctx, server, request)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
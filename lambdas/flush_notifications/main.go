@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/notifier"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/outbox"
+)
+
+var scheduler *notifier.Scheduler
+
+func init() {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		panic("unable to load aws config")
+	}
+
+	// held messages were already claimed in the ledger when they were held,
+	// so the dispatcher doesn't need it
+	scheduler = notifier.NewScheduler(notifier.NewDispatcher(notifier.FromEnv()), outbox.New(cfg), time.Now)
+}
+
+// runs on a schedule and sends whatever quiet hours, digests and rate limits
+// held back
+func HandleRequest(ctx context.Context) error {
+	sent, err := scheduler.Flush(ctx)
+	log.Printf("Flushed %d held notifications, %+v\n", sent, err)
+	return err
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
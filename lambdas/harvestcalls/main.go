@@ -2,12 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
+	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
 
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/chesterfield"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/geocode"
 	"github.com/kevin-secrist/cfactivecallmonitor/internal/harvester"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/metrics"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_calls"
+	"github.com/kevin-secrist/cfactivecallmonitor/internal/saved_incidents"
 )
 
 var harvesterInstance *harvester.Harvester
@@ -20,11 +29,48 @@ func init() {
 	if err != nil {
 		panic("unable to load aws config")
 	}
-	harvesterInstance = harvester.New(policeApiKey, fireApiKey, cfg)
+
+	// the address ranges are optional, without them calls have no coordinates
+	var geocoder geocode.Provider
+	if addressRanges := os.Getenv("ADDRESS_RANGES_FILE"); addressRanges != "" {
+		ranges, err := geocode.LoadRanges(addressRanges)
+		if err != nil {
+			log.Printf("Geocoding disabled: %v\n", err)
+		} else {
+			geocoder = geocode.NewCached(ranges)
+		}
+	}
+
+	harvesterInstance = harvester.NewWithClients(
+		chesterfield.New(policeApiKey, fireApiKey),
+		saved_calls.New(cfg),
+		saved_incidents.New(cfg),
+		geocoder,
+	).WithGuard(guardFromEnv(), metrics.NewEmbedded("ActiveCallMonitor", os.Stdout, time.Now))
+}
+
+// the defaults can be overridden without a deploy, e.g. to let a real mass
+// resolution through
+func guardFromEnv() harvester.Guard {
+	guard := harvester.DefaultGuard
+	if value, err := strconv.Atoi(os.Getenv("RESOLUTION_GRACE_HARVESTS")); err == nil {
+		guard.GraceHarvests = value
+	}
+	if value, err := strconv.ParseFloat(os.Getenv("MAX_RESOLVED_SHARE"), 64); err == nil {
+		guard.MaxResolvedShare = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("RESOLUTION_GUARD_MIN_CALLS")); err == nil {
+		guard.MinCalls = value
+	}
+	return guard
 }
 
-func HandleRequest(ctx context.Context) error {
-	return harvesterInstance.Harvest(ctx)
+// the report is the invocation's result, it shows up in test invocations
+// and on-success destinations
+func HandleRequest(ctx context.Context) (harvester.HarvestReport, error) {
+	report, callErr := harvesterInstance.Harvest(ctx)
+	incidentErr := harvesterInstance.HarvestIncidents(ctx)
+	return report, errors.Join(callErr, incidentErr)
 }
 
 func main() {
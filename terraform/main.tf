@@ -55,11 +55,14 @@ resource "aws_sns_topic_subscription" "email_alerts" {
   endpoint  = var.OPS_EMAIL
 }
 
+# calls are written in transactions, which cost 2 WCU per item. A harvest
+# writes in bursts of up to 25 items, which the table's burst capacity
+# absorbs between harvests.
 resource "aws_dynamodb_table" "savedcalls" {
   name           = "SavedCalls"
   billing_mode   = "PROVISIONED"
   read_capacity  = 1
-  write_capacity = 1
+  write_capacity = 5
   hash_key       = "streetName"
   range_key      = "sortKey"
 
@@ -86,6 +89,11 @@ resource "aws_dynamodb_table" "savedcalls" {
     type = "S"
   }
 
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
   global_secondary_index {
     name            = "ActiveIndex"
     hash_key        = "isActive"
@@ -95,21 +103,144 @@ resource "aws_dynamodb_table" "savedcalls" {
     projection_type = "ALL"
   }
 
+  # GET /calls/{id} looks calls up here instead of scanning the table
+  global_secondary_index {
+    name            = "IdIndex"
+    hash_key        = "id"
+    range_key       = "sortKey"
+    write_capacity  = 1
+    read_capacity   = 1
+    projection_type = "ALL"
+  }
+
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "aws_dynamodb_table" "savedincidents" {
+  name           = "SavedIncidents"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 1
+  write_capacity = 1
+  hash_key       = "jurisdiction"
+  range_key      = "id"
+
+  attribute {
+    name = "jurisdiction"
+    type = "S"
+  }
+
+  attribute {
+    name = "id"
+    type = "S"
+  }
+
+  attribute {
+    name = "isActive"
+    type = "S"
+  }
+
+  attribute {
+    name = "firstSeen"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "ActiveIndex"
+    hash_key        = "isActive"
+    range_key       = "firstSeen"
+    write_capacity  = 1
+    read_capacity   = 1
+    projection_type = "ALL"
+  }
+
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "aws_dynamodb_table" "subscriptions" {
+  name           = "Subscriptions"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 1
+  write_capacity = 1
+  hash_key       = "userId"
+  range_key      = "subscriptionId"
+
+  attribute {
+    name = "userId"
+    type = "S"
+  }
+
+  attribute {
+    name = "subscriptionId"
+    type = "S"
+  }
+
   lifecycle {
     prevent_destroy = true
   }
 }
 
+# one item per notification sent, so a retried stream record doesn't send it
+# twice
+resource "aws_dynamodb_table" "notificationledger" {
+  name           = "NotificationLedger"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 1
+  write_capacity = 1
+  hash_key       = "notificationId"
+
+  attribute {
+    name = "notificationId"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+}
+
+# messages held back by quiet hours, digests and rate limits until the flush
+# lambda sends them, along with the rate limit counters
+resource "aws_dynamodb_table" "outbox" {
+  name           = "Outbox"
+  billing_mode   = "PROVISIONED"
+  read_capacity  = 1
+  write_capacity = 1
+  hash_key       = "recipient"
+  range_key      = "notificationId"
+
+  attribute {
+    name = "recipient"
+    type = "S"
+  }
+
+  attribute {
+    name = "notificationId"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+}
+
+# the directory holds the bootstrap binary and, when CI could download
+# it, the address_ranges.csv used for geocoding
 data "archive_file" "harvestcalls" {
   type             = "zip"
-  source_file      = "../build/bin/harvestcalls/bootstrap"
+  source_dir       = "../build/bin/harvestcalls"
   output_file_mode = "0666"
   output_path      = "../build/bin/harvestcalls.zip"
 }
 
 resource "aws_lambda_function" "harvestcalls" {
   function_name    = "HarvestCalls"
-  description      = "Pulls active Police/Fire calls from chesterfield.gov and stores them"
+  description      = "Pulls active Police/Fire calls and traffic incidents from chesterfield.gov and stores them"
   filename         = data.archive_file.harvestcalls.output_path
   memory_size      = 128
   runtime          = "provided.al2023"
@@ -120,8 +251,9 @@ resource "aws_lambda_function" "harvestcalls" {
 
   environment {
     variables = {
-      CPD_API_KEY = var.CPD_API_KEY
-      CFD_API_KEY = var.CFD_API_KEY
+      ADDRESS_RANGES_FILE = "address_ranges.csv"
+      CPD_API_KEY         = var.CPD_API_KEY
+      CFD_API_KEY         = var.CFD_API_KEY
     }
   }
 }
@@ -148,6 +280,30 @@ resource "aws_cloudwatch_metric_alarm" "harvest_lambda_errors" {
   }
 }
 
+# the harvester holds back resolving most of an agency's calls at once, if
+# this keeps firing the calls may really have ended, see MAX_RESOLVED_SHARE
+resource "aws_cloudwatch_metric_alarm" "resolution_guard_tripped" {
+  for_each = toset(["police", "fire"])
+
+  alarm_name          = "resolution-guard-tripped-${each.key}"
+  comparison_operator = "GreaterThanOrEqualToThreshold"
+  evaluation_periods  = 1
+  metric_name         = "ResolutionGuardTripped"
+  namespace           = "ActiveCallMonitor"
+  period              = 3600
+  statistic           = "Sum"
+  threshold           = 3
+  treat_missing_data  = "notBreaching"
+  alarm_description   = "Monitors for the harvester holding back a mass resolution of calls"
+  alarm_actions = [
+    aws_sns_topic.ops_critical.arn
+  ]
+
+  dimensions = {
+    Agency = each.key
+  }
+}
+
 resource "aws_cloudwatch_log_group" "harvestcalls" {
   name              = "/aws/lambda/${aws_lambda_function.harvestcalls.function_name}"
   retention_in_days = 7
@@ -169,7 +325,9 @@ resource "aws_iam_policy" "harvester_data_access_policy" {
         Effect = "Allow",
         Resource = [
           aws_dynamodb_table.savedcalls.arn,
-          "${aws_dynamodb_table.savedcalls.arn}/*"
+          "${aws_dynamodb_table.savedcalls.arn}/*",
+          aws_dynamodb_table.savedincidents.arn,
+          "${aws_dynamodb_table.savedincidents.arn}/*"
         ]
       }
     ]
@@ -219,9 +377,95 @@ resource "aws_lambda_permission" "trigger_harvestcalls_permission" {
   source_arn    = aws_cloudwatch_event_rule.every_five_minutes.arn
 }
 
+data "archive_file" "call_api" {
+  type             = "zip"
+  source_file      = "../build/bin/call_api/bootstrap"
+  output_file_mode = "0666"
+  output_path      = "../build/bin/call_api.zip"
+}
+
+resource "aws_lambda_function" "call_api" {
+  function_name    = "CallApi"
+  description      = "Serves saved calls and traffic incidents over REST and GraphQL"
+  filename         = data.archive_file.call_api.output_path
+  memory_size      = 128
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  role             = aws_iam_role.call_api.arn
+  source_code_hash = data.archive_file.call_api.output_base64sha256
+  timeout          = 15
+
+  # the url is open to anyone and a street-less query scans the table, so a
+  # single client can't run enough of them at once to starve the harvester
+  reserved_concurrent_executions = 2
+}
+
+# the calls are already public on chesterfield.gov, so the url is too
+resource "aws_lambda_function_url" "call_api" {
+  function_name      = aws_lambda_function.call_api.function_name
+  authorization_type = "NONE"
+}
+
+resource "aws_cloudwatch_log_group" "call_api" {
+  name              = "/aws/lambda/${aws_lambda_function.call_api.function_name}"
+  retention_in_days = 7
+}
+
+resource "aws_iam_policy" "call_api" {
+  name = "CallApi"
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action = [
+          "dynamodb:Query",
+          "dynamodb:Scan"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.savedcalls.arn,
+          "${aws_dynamodb_table.savedcalls.arn}/*",
+          aws_dynamodb_table.savedincidents.arn,
+          "${aws_dynamodb_table.savedincidents.arn}/*"
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role" "call_api" {
+  name = "CallApi"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Action = "sts:AssumeRole"
+      Effect = "Allow"
+      Principal = {
+        Service = "lambda.amazonaws.com"
+      }
+    }]
+  })
+}
+
+resource "aws_iam_role_policy_attachments_exclusive" "call_api" {
+  role_name = aws_iam_role.call_api.name
+  policy_arns = [
+    local.lambda_default_role_arn,
+    aws_iam_policy.call_api.arn
+  ]
+}
+
+output "call_api_url" {
+  value = aws_lambda_function_url.call_api.function_url
+}
+
+# the directory holds the bootstrap binary and the rules.json and
+# templates.tmpl written by CI
 data "archive_file" "active_call_notifier" {
   type             = "zip"
-  source_file      = "../build/bin/active_call_notifier/bootstrap"
+  source_dir       = "../build/bin/active_call_notifier"
   output_file_mode = "0666"
   output_path      = "../build/bin/active_call_notifier.zip"
 }
@@ -239,8 +483,10 @@ resource "aws_lambda_function" "active_call_notifier" {
 
   environment {
     variables = {
+      RULES_FILE         = "rules.json"
       SMS_FROM           = var.SMS_FROM
       SMS_TO             = var.SMS_TO
+      TEMPLATES_FILE     = "templates.tmpl"
       TWILIO_ACCOUNT_SID = var.TWILIO_ACCOUNT_SID
       TWILIO_API_KEY     = var.TWILIO_API_KEY
       TWILIO_API_SECRET  = var.TWILIO_API_SECRET
@@ -291,6 +537,37 @@ resource "aws_iam_policy" "active_call_notifier" {
         Resource = [
           "${aws_dynamodb_table.savedcalls.arn}/stream/*"
         ]
+      },
+      {
+        Action = [
+          "dynamodb:Query",
+          "dynamodb:Scan"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.subscriptions.arn
+        ]
+      },
+      {
+        Action = [
+          "dynamodb:DeleteItem",
+          "dynamodb:PutItem",
+          "dynamodb:UpdateItem"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.notificationledger.arn
+        ]
+      },
+      {
+        Action = [
+          "dynamodb:PutItem",
+          "dynamodb:UpdateItem"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.outbox.arn
+        ]
       }
     ]
   })
@@ -327,18 +604,273 @@ resource "aws_lambda_event_source_mapping" "active_call_notifier_trigger" {
   maximum_batching_window_in_seconds = 10
   maximum_record_age_in_seconds      = 3600
   maximum_retry_attempts             = 5
+  function_response_types            = ["ReportBatchItemFailures"]
+}
+
+data "archive_file" "flush_notifications" {
+  type             = "zip"
+  source_file      = "../build/bin/flush_notifications/bootstrap"
+  output_file_mode = "0666"
+  output_path      = "../build/bin/flush_notifications.zip"
+}
+
+resource "aws_lambda_function" "flush_notifications" {
+  function_name    = "FlushNotifications"
+  description      = "Sends notifications that were held back by quiet hours, digests and rate limits"
+  filename         = data.archive_file.flush_notifications.output_path
+  memory_size      = 128
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  role             = aws_iam_role.flush_notifications.arn
+  source_code_hash = data.archive_file.flush_notifications.output_base64sha256
+  timeout          = 60
 
-  filter_criteria {
-    filter {
-      pattern = jsonencode({
-        "dynamodb" : {
-          "NewImage" : {
-            "streetName" : {
-              "S" : var.STREET_NAMES
-            }
-          }
-        }
-      })
+  environment {
+    variables = {
+      SMS_FROM           = var.SMS_FROM
+      TWILIO_ACCOUNT_SID = var.TWILIO_ACCOUNT_SID
+      TWILIO_API_KEY     = var.TWILIO_API_KEY
+      TWILIO_API_SECRET  = var.TWILIO_API_SECRET
     }
   }
 }
+
+resource "aws_cloudwatch_metric_alarm" "flush_lambda_errors" {
+  alarm_name          = "flush-notifications-lambda-errors"
+  comparison_operator = "GreaterThanOrEqualToThreshold"
+  evaluation_periods  = 1
+  metric_name         = "Errors"
+  namespace           = "AWS/Lambda"
+  period              = 3600
+  statistic           = "Sum"
+  threshold           = 6
+  treat_missing_data  = "notBreaching"
+  alarm_description   = "Monitors for held notifications that can't be sent"
+  alarm_actions = [
+    aws_sns_topic.ops_critical.arn
+  ]
+
+  dimensions = {
+    FunctionName = aws_lambda_function.flush_notifications.function_name
+  }
+}
+
+resource "aws_cloudwatch_log_group" "flush_notifications" {
+  name              = "/aws/lambda/${aws_lambda_function.flush_notifications.function_name}"
+  retention_in_days = 7
+}
+
+resource "aws_iam_policy" "flush_notifications" {
+  name = "FlushNotifications"
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action = [
+          "dynamodb:DeleteItem",
+          "dynamodb:Scan"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.outbox.arn
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role" "flush_notifications" {
+  name = "FlushNotifications"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Action = "sts:AssumeRole"
+      Effect = "Allow"
+      Principal = {
+        Service = "lambda.amazonaws.com"
+      }
+    }]
+  })
+}
+
+resource "aws_iam_role_policy_attachments_exclusive" "flush_notifications" {
+  role_name = aws_iam_role.flush_notifications.name
+  policy_arns = [
+    local.lambda_default_role_arn,
+    aws_iam_policy.flush_notifications.arn
+  ]
+}
+
+resource "aws_cloudwatch_event_target" "trigger_flush_notifications" {
+  rule      = aws_cloudwatch_event_rule.every_five_minutes.name
+  target_id = "flush_notifications"
+  arn       = aws_lambda_function.flush_notifications.arn
+}
+
+resource "aws_lambda_permission" "trigger_flush_notifications_permission" {
+  statement_id  = "AllowExecutionFromCloudWatch"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.flush_notifications.arn
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.every_five_minutes.arn
+}
+
+# the directory holds the bootstrap binary and the rules.json written by CI,
+# the default subscriber's report covers the same streets as their alerts
+data "archive_file" "activity_report" {
+  type             = "zip"
+  source_dir       = "../build/bin/activity_report"
+  output_file_mode = "0666"
+  output_path      = "../build/bin/activity_report.zip"
+}
+
+resource "aws_lambda_function" "activity_report" {
+  function_name    = "ActivityReport"
+  description      = "Sends daily and weekly summaries of the calls subscribers watch"
+  filename         = data.archive_file.activity_report.output_path
+  memory_size      = 128
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  role             = aws_iam_role.activity_report.arn
+  source_code_hash = data.archive_file.activity_report.output_base64sha256
+  timeout          = 300
+
+  environment {
+    variables = {
+      ACTIVITY_REPORT    = var.ACTIVITY_REPORT
+      RULES_FILE         = "rules.json"
+      SMS_FROM           = var.SMS_FROM
+      SMS_TO             = var.SMS_TO
+      TWILIO_ACCOUNT_SID = var.TWILIO_ACCOUNT_SID
+      TWILIO_API_KEY     = var.TWILIO_API_KEY
+      TWILIO_API_SECRET  = var.TWILIO_API_SECRET
+    }
+  }
+}
+
+# it runs once a day, twice on mondays, so any error is a missed report
+resource "aws_cloudwatch_metric_alarm" "activity_report_lambda_errors" {
+  alarm_name          = "activity-report-lambda-errors"
+  comparison_operator = "GreaterThanOrEqualToThreshold"
+  evaluation_periods  = 1
+  metric_name         = "Errors"
+  namespace           = "AWS/Lambda"
+  period              = 3600
+  statistic           = "Sum"
+  threshold           = 1
+  treat_missing_data  = "notBreaching"
+  alarm_description   = "Monitors for activity reports that couldn't be sent"
+  alarm_actions = [
+    aws_sns_topic.ops_critical.arn
+  ]
+
+  dimensions = {
+    FunctionName = aws_lambda_function.activity_report.function_name
+  }
+}
+
+resource "aws_cloudwatch_log_group" "activity_report" {
+  name              = "/aws/lambda/${aws_lambda_function.activity_report.function_name}"
+  retention_in_days = 7
+}
+
+resource "aws_iam_policy" "activity_report" {
+  name = "ActivityReport"
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Action = [
+          "dynamodb:Query",
+          "dynamodb:Scan"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.savedcalls.arn,
+          "${aws_dynamodb_table.savedcalls.arn}/*",
+          aws_dynamodb_table.subscriptions.arn
+        ]
+      },
+      {
+        Action = [
+          "dynamodb:DeleteItem",
+          "dynamodb:PutItem",
+          "dynamodb:UpdateItem"
+        ],
+        Effect = "Allow",
+        Resource = [
+          aws_dynamodb_table.notificationledger.arn
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role" "activity_report" {
+  name = "ActivityReport"
+
+  assume_role_policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Action = "sts:AssumeRole"
+      Effect = "Allow"
+      Principal = {
+        Service = "lambda.amazonaws.com"
+      }
+    }]
+  })
+}
+
+resource "aws_iam_role_policy_attachments_exclusive" "activity_report" {
+  role_name = aws_iam_role.activity_report.name
+  policy_arns = [
+    local.lambda_default_role_arn,
+    aws_iam_policy.activity_report.arn
+  ]
+}
+
+# 7 AM in the summer and 6 AM in the winter, after the last day is over
+resource "aws_cloudwatch_event_rule" "daily_report" {
+  name                = "daily-activity-report"
+  description         = "Fires every morning for the daily activity reports"
+  schedule_expression = "cron(0 11 * * ? *)"
+}
+
+resource "aws_cloudwatch_event_rule" "weekly_report" {
+  name                = "weekly-activity-report"
+  description         = "Fires monday mornings for the weekly activity reports"
+  schedule_expression = "cron(0 11 ? * MON *)"
+}
+
+resource "aws_cloudwatch_event_target" "trigger_daily_report" {
+  rule      = aws_cloudwatch_event_rule.daily_report.name
+  target_id = "activity_report"
+  arn       = aws_lambda_function.activity_report.arn
+  input     = jsonencode({ period = "daily" })
+}
+
+resource "aws_cloudwatch_event_target" "trigger_weekly_report" {
+  rule      = aws_cloudwatch_event_rule.weekly_report.name
+  target_id = "activity_report"
+  arn       = aws_lambda_function.activity_report.arn
+  input     = jsonencode({ period = "weekly" })
+}
+
+resource "aws_lambda_permission" "trigger_daily_report_permission" {
+  statement_id  = "AllowExecutionFromCloudWatchDaily"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.activity_report.arn
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.daily_report.arn
+}
+
+resource "aws_lambda_permission" "trigger_weekly_report_permission" {
+  statement_id  = "AllowExecutionFromCloudWatchWeekly"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.activity_report.arn
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.weekly_report.arn
+}
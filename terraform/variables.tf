@@ -33,12 +33,13 @@ variable "TWILIO_API_SECRET" {
   sensitive = true
 }
 
-variable "STREET_NAMES" {
-  type      = list(string)
-  sensitive = true
-}
-
 variable "OPS_EMAIL" {
   type      = string
   sensitive = true
 }
+
+# daily or weekly for the SMS_TO subscriber's activity report, none when empty
+variable "ACTIVITY_REPORT" {
+  type    = string
+  default = ""
+}